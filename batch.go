@@ -0,0 +1,304 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package aubio
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// AnalysisConfig selects the analyzers AnalyzeFiles runs on each file
+// and the parameters they use. An analyzer is skipped when its mode
+// is empty.
+type AnalysisConfig struct {
+	// Samplerate to open the files with. 0 uses each file's own rate.
+	Samplerate uint
+	// BufSize is the analysis window size.
+	BufSize uint
+	// HopSize is the number of samples read per block.
+	HopSize uint
+
+	// OnsetMode selects the onset detection function.
+	OnsetMode onsetMode
+	// TempoMode selects the onset detection function used for beat
+	// tracking.
+	TempoMode onsetMode
+	// PitchMode selects the pitch detection algorithm.
+	PitchMode pitchMode
+	// PitchUnit is the unit pitches are reported in. Defaults to Hz.
+	PitchUnit pitchOutMode
+	// PitchTolerance is used by the yin based pitch detectors if set.
+	PitchTolerance float64
+
+	// Silence is the silence threshold in dB for onsets and beats.
+	// 0 leaves aubio's default.
+	Silence float64
+	// Threshold is the peak picking threshold for onsets and beats.
+	// 0 leaves aubio's default.
+	Threshold float64
+
+	// Timeout bounds the time spent on a single file. 0 means no limit.
+	Timeout time.Duration
+	// Open opens a file for reading. It defaults to OpenSource but
+	// can be set to a function calling OpenWavSource, for example,
+	// when libaubio has no decoders.
+	Open func(path string, samplerate, hopSize uint) (AudioSource, error)
+	// Progress, if set, is called once for every file as it
+	// finishes, before its result is sent. Calls are never concurrent.
+	Progress func(done, total int, result *FileResult)
+}
+
+// PitchFrame is one frame of pitch detection output.
+type PitchFrame struct {
+	// Time is the start of the frame in seconds.
+	Time float64
+	// Pitch is the detected pitch in the configured unit.
+	Pitch float64
+	// Confidence is aubio's confidence in Pitch.
+	Confidence float64
+}
+
+// FileResult holds the analysis of a single file.
+type FileResult struct {
+	Path       string
+	Samplerate uint
+	// Frames is the number of samples read from the file.
+	Frames uint
+	// Onsets holds the onset times in seconds.
+	Onsets []float64
+	// Beats holds the beat times in seconds.
+	Beats []float64
+	// Bpm and Confidence are the final tempo estimate.
+	Bpm        float64
+	Confidence float64
+	Pitches    []PitchFrame
+	// Err is set if the file could not be opened or analysed.
+	// The other fields may hold partial results.
+	Err error
+}
+
+func openSource(path string, samplerate, hopSize uint) (AudioSource, error) {
+	s, err := OpenSource(path, samplerate, hopSize)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// AnalyzeFiles analyses paths using up to workers goroutines and sends
+// one FileResult per path on the returned channel, in the order they
+// finish. The channel is closed once every file has been analysed.
+// The caller must read every result; use AnalyzeFilesContext to be
+// able to stop early.
+//
+//	config := AnalysisConfig{BufSize: 512, HopSize: 256, OnsetMode: HFC}
+//	for r := range AnalyzeFiles(paths, config, runtime.NumCPU()) {
+//		if r.Err != nil {
+//			log.Print(r.Err)
+//			continue
+//		}
+//		fmt.Println(r.Path, r.Onsets)
+//	}
+func AnalyzeFiles(paths []string, config AnalysisConfig, workers int) <-chan *FileResult {
+	return AnalyzeFilesContext(context.Background(), paths, config, workers)
+}
+
+// AnalyzeFilesContext is AnalyzeFiles stopping early when ctx is
+// done. Files that have not been started are skipped, the files being
+// analysed stop at the next block with ctx.Err() as their error, and
+// results are no longer sent. The channel is still closed once the
+// workers have stopped, so the caller may stop reading it after
+// cancelling ctx.
+//
+//	ctx, cancel := context.WithCancel(context.Background())
+//	defer cancel()
+//	for r := range AnalyzeFilesContext(ctx, paths, config, runtime.NumCPU()) {
+//		if r.Err != nil {
+//			break
+//		}
+//	}
+func AnalyzeFilesContext(ctx context.Context, paths []string, config AnalysisConfig, workers int) <-chan *FileResult {
+	if workers < 1 {
+		workers = 1
+	}
+	if config.Open == nil {
+		config.Open = openSource
+	}
+	todo := make(chan string)
+	done := make(chan *FileResult)
+	out := make(chan *FileResult)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range todo {
+				select {
+				case done <- analyzeFile(ctx, path, &config):
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+	feed:
+		for _, path := range paths {
+			select {
+			case todo <- path:
+			case <-ctx.Done():
+				break feed
+			}
+		}
+		close(todo)
+		wg.Wait()
+		close(done)
+	}()
+	go func() {
+		n := 0
+		for r := range done {
+			n++
+			if config.Progress != nil {
+				config.Progress(n, len(paths), r)
+			}
+			// Keep draining done after ctx is cancelled so the
+			// workers can finish.
+			select {
+			case out <- r:
+			case <-ctx.Done():
+			}
+		}
+		close(out)
+	}()
+	return out
+}
+
+// analyzeFile runs the analyzers selected by config over one file.
+func analyzeFile(ctx context.Context, path string, config *AnalysisConfig) *FileResult {
+	r := &FileResult{Path: path}
+	// Bad pitch settings make aubio return nil, so check them before
+	// opening the file.
+	if config.PitchMode != "" {
+		if _, err := ParsePitchMode(string(config.PitchMode)); err != nil {
+			r.Err = err
+			return r
+		}
+	}
+	if config.PitchUnit != "" {
+		if _, err := ParsePitchUnit(string(config.PitchUnit)); err != nil {
+			r.Err = err
+			return r
+		}
+	}
+	src, err := config.Open(path, config.Samplerate, config.HopSize)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	r.Samplerate = src.Samplerate()
+	p := NewSimplePipeline(src, nil, config.HopSize)
+	defer p.Close()
+
+	var fs []ProcessFunc
+	if config.OnsetMode != "" {
+		o, err := NewOnset(config.OnsetMode, config.BufSize, config.HopSize, r.Samplerate)
+		if err != nil {
+			r.Err = err
+			return r
+		}
+		defer o.Free()
+		if config.Silence != 0 {
+			o.SetSilence(config.Silence)
+		}
+		if config.Threshold != 0 {
+			o.SetThreshold(config.Threshold)
+		}
+		fs = append(fs, func(in *SimpleBuffer) {
+			o.Do(in)
+			if o.Buffer().Slice()[0] != 0 {
+				r.Onsets = append(r.Onsets, o.GetLastOnset())
+			}
+		})
+	}
+	if config.TempoMode != "" {
+		t, err := NewTempo(config.TempoMode, config.BufSize, config.HopSize, r.Samplerate)
+		if err != nil {
+			r.Err = err
+			return r
+		}
+		defer t.Free()
+		if config.Silence != 0 {
+			t.SetSilence(config.Silence)
+		}
+		if config.Threshold != 0 {
+			t.SetThreshold(config.Threshold)
+		}
+		fs = append(fs, func(in *SimpleBuffer) {
+			t.Do(in)
+			if t.Buffer().Slice()[0] != 0 {
+				r.Beats = append(r.Beats, t.GetLastBeat())
+			}
+			r.Bpm = t.GetBpm()
+			r.Confidence = t.GetConfidence()
+		})
+	}
+	if config.PitchMode != "" {
+		pitch, err := NewPitchChecked(config.PitchMode, config.BufSize, config.HopSize, r.Samplerate)
+		if err != nil {
+			r.Err = err
+			return r
+		}
+		defer pitch.Free()
+		if config.PitchUnit != "" {
+			pitch.SetUnit(config.PitchUnit)
+		}
+		if config.PitchTolerance != 0 {
+			pitch.SetTolerance(config.PitchTolerance)
+		}
+		frame := 0
+		fs = append(fs, func(in *SimpleBuffer) {
+			pitch.Do(in)
+			r.Pitches = append(r.Pitches, PitchFrame{
+				Time:       float64(frame) * float64(config.HopSize) / float64(r.Samplerate),
+				Pitch:      pitch.Buffer().Slice()[0],
+				Confidence: pitch.GetConfidence(),
+			})
+			frame++
+		})
+	}
+
+	var deadline time.Time
+	if config.Timeout > 0 {
+		deadline = time.Now().Add(config.Timeout)
+	}
+	for {
+		// aubio calls can't be interrupted so the deadline is checked
+		// between blocks.
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			r.Err = fmt.Errorf("Timed out analysing %q after %s", path, config.Timeout)
+			return r
+		}
+		if err := ctx.Err(); err != nil {
+			r.Err = err
+			return r
+		}
+		n := p.DoN(64, fs...)
+		r.Frames += n
+		if n < 64*p.BlockSize() || p.Err() != nil {
+			break
+		}
+	}
+	r.Err = p.Err()
+	return r
+}
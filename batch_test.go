@@ -0,0 +1,65 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package aubio
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// openSilence opens every path as silence lasting length samples, or
+// forever if length is 0.
+func openSilence(length uint) func(string, uint, uint) (AudioSource, error) {
+	return func(path string, samplerate, hopSize uint) (AudioSource, error) {
+		return NewGeneratorSource(func(uint) float64 { return 0 }, length, 8000, hopSize), nil
+	}
+}
+
+func TestAnalyzeFilesBadPitch(t *testing.T) {
+	for _, config := range []AnalysisConfig{
+		{PitchMode: "nope"},
+		{PitchMode: PitchYin, PitchUnit: "furlongs"},
+	} {
+		config.BufSize, config.HopSize = 512, 256
+		config.Open = openSilence(8000)
+		for r := range AnalyzeFiles([]string{"a", "b"}, config, 2) {
+			if r.Err == nil {
+				t.Errorf("%s: got no error for pitch mode %q unit %q", r.Path, config.PitchMode, config.PitchUnit)
+			}
+		}
+	}
+}
+
+func TestAnalyzeFilesContextCancel(t *testing.T) {
+	config := AnalysisConfig{BufSize: 512, HopSize: 256, OnsetMode: HFC, Open: openSilence(0)}
+	paths := []string{"a", "b", "c", "d", "e", "f"}
+	ctx, cancel := context.WithCancel(context.Background())
+	results := AnalyzeFilesContext(ctx, paths, config, 2)
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	// The endless files stop and the channel is closed without
+	// reading every result.
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case r, ok := <-results:
+			if !ok {
+				return
+			}
+			if r.Err != context.Canceled {
+				t.Errorf("%s: Err = %v, want %v", r.Path, r.Err, context.Canceled)
+			}
+		case <-timeout:
+			t.Fatal("AnalyzeFilesContext did not stop after cancel")
+		}
+	}
+}
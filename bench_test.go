@@ -0,0 +1,223 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package aubio_test
+
+import (
+	"fmt"
+	"testing"
+
+	"go.marzhillstudios.com/pkg/play/aubio"
+	"go.marzhillstudios.com/pkg/play/aubio/synth"
+)
+
+// The benchmarks measure the cost of each analyzer's Do, of copying
+// buffers across cgo and of whole pipelines at several block sizes:
+//
+//	go test -run NONE -bench 'Pitch|Pipeline'
+const (
+	benchSamplerate = 44100
+	benchBufSize    = 1024
+	benchHopSize    = 256
+	// benchSeconds is the length of the signal the pipeline
+	// benchmarks read.
+	benchSeconds = 10
+)
+
+// benchSignal is the audio every benchmark analyses: a few notes over
+// quiet noise.
+func benchSignal() synth.Signal {
+	notes := synth.Melody([]float64{57, 60, 64, 69, 72, 69, 64, 60}, 0.4, 0.1, 100)
+	return synth.Mix(synth.Notes(notes, benchSamplerate), synth.PinkNoise(0.01, 1))
+}
+
+// benchFrames runs f once per iteration on consecutive blocks of
+// benchHopSize samples of the test signal, cycling through one second
+// of it.
+func benchFrames(b *testing.B, f func(in *aubio.SimpleBuffer)) {
+	blocks := synth.Blocks(benchSignal(), benchSamplerate, benchHopSize)
+	defer func() {
+		for _, buf := range blocks {
+			buf.Free()
+		}
+	}()
+	b.ReportAllocs()
+	b.SetBytes(benchHopSize * 8)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		f(blocks[i%len(blocks)])
+	}
+}
+
+func BenchmarkBuffer(b *testing.B) {
+	for _, size := range []uint{256, 1024, 4096} {
+		data := synth.Render(benchSignal(), size)
+		b.Run(fmt.Sprintf("slice/%d", size), func(b *testing.B) {
+			buf := aubio.NewSimpleBufferData(size, data)
+			defer buf.Free()
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				buf.Slice()
+			}
+		})
+		b.Run(fmt.Sprintf("setdata/%d", size), func(b *testing.B) {
+			buf := aubio.NewSimpleBuffer(size)
+			defer buf.Free()
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				buf.SetData(data)
+			}
+		})
+		b.Run(fmt.Sprintf("new/%d", size), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				aubio.NewSimpleBufferData(size, data).Free()
+			}
+		})
+	}
+}
+
+func BenchmarkPitch(b *testing.B) {
+	for _, name := range []string{"default", "yin", "yinfft", "mcomb", "schmitt", "fcomb"} {
+		mode, _ := aubio.ParsePitchMode(name)
+		b.Run(name, func(b *testing.B) {
+			p := aubio.NewPitch(mode, benchBufSize, benchHopSize, benchSamplerate)
+			defer p.Free()
+			benchFrames(b, p.Do)
+		})
+	}
+}
+
+func BenchmarkOnset(b *testing.B) {
+	for _, mode := range aubio.OnsetModes() {
+		b.Run(string(mode), func(b *testing.B) {
+			o, err := aubio.NewOnset(mode, benchBufSize, benchHopSize, benchSamplerate)
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer o.Free()
+			benchFrames(b, o.Do)
+		})
+	}
+}
+
+func BenchmarkTempo(b *testing.B) {
+	t, err := aubio.NewTempo(aubio.SpecDiff, benchBufSize, benchHopSize, benchSamplerate)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer t.Free()
+	benchFrames(b, t.Do)
+}
+
+func BenchmarkNotes(b *testing.B) {
+	n, err := aubio.NewNotes(benchBufSize, benchHopSize, benchSamplerate)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer n.Free()
+	benchFrames(b, func(in *aubio.SimpleBuffer) { n.Do(in) })
+}
+
+func BenchmarkFilter(b *testing.B) {
+	f, err := aubio.NewFilter(3, benchHopSize)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer f.Free()
+	benchFrames(b, f.DoOutplace)
+}
+
+func BenchmarkPhaseVoc(b *testing.B) {
+	pv, err := aubio.NewPhaseVoc(benchBufSize, benchHopSize)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer pv.Free()
+	b.Run("forward", func(b *testing.B) {
+		benchFrames(b, pv.Do)
+	})
+	b.Run("reverse", func(b *testing.B) {
+		benchFrames(b, func(in *aubio.SimpleBuffer) {
+			pv.Do(in)
+			pv.ReverseDo(in)
+		})
+	})
+}
+
+func BenchmarkFilterBank(b *testing.B) {
+	pv, err := aubio.NewPhaseVoc(benchBufSize, benchHopSize)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer pv.Free()
+	fb := aubio.NewFilterBank(40, benchBufSize)
+	defer fb.Free()
+	fb.SetMelCoeffsSlaney(benchSamplerate)
+	benchFrames(b, func(in *aubio.SimpleBuffer) {
+		pv.Do(in)
+		fb.Do(pv.Grain())
+	})
+}
+
+func BenchmarkMFCC(b *testing.B) {
+	pv, err := aubio.NewPhaseVoc(benchBufSize, benchHopSize)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer pv.Free()
+	m, err := aubio.NewMFCC(benchBufSize, 40, 13, benchSamplerate)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer m.Free()
+	benchFrames(b, func(in *aubio.SimpleBuffer) {
+		pv.Do(in)
+		m.Do(pv.Grain())
+	})
+}
+
+func BenchmarkDbSpl(b *testing.B) {
+	benchFrames(b, func(in *aubio.SimpleBuffer) { aubio.DbSpl(in) })
+}
+
+// benchPipeline runs f over the whole of data with a SimplePipeline
+// reading blocks of size samples, once per iteration.
+func benchPipeline(b *testing.B, data []float64, size uint, f aubio.ProcessFunc) {
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)) * 8)
+	src := aubio.NewMemorySource(data, benchSamplerate, size)
+	for i := 0; i < b.N; i++ {
+		src.Reset()
+		p := aubio.NewSimplePipeline(src, nil, size)
+		p.DoAll(f)
+		if err := p.Err(); err != nil {
+			b.Fatal(err)
+		}
+		p.Close()
+	}
+}
+
+func BenchmarkPipeline(b *testing.B) {
+	data := synth.Render(benchSignal(), synth.Samples(benchSeconds, benchSamplerate))
+	for _, size := range []uint{64, 128, 256, 512, 1024, 2048, 4096} {
+		b.Run(fmt.Sprintf("read/%d", size), func(b *testing.B) {
+			benchPipeline(b, data, size, func(*aubio.SimpleBuffer) {})
+		})
+		b.Run(fmt.Sprintf("onset/%d", size), func(b *testing.B) {
+			o, err := aubio.NewOnset(aubio.HFC, size*2, size, benchSamplerate)
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer o.Free()
+			benchPipeline(b, data, size, o.Do)
+		})
+	}
+}
@@ -6,11 +6,17 @@ package aubio
 */
 import "C"
 
+import (
+	"runtime"
+)
+
 // SimpleBuffer is a wrapper for the aubio fvec_t type. It is used
 // as the buffer for processing audio data in an aubio pipeline.
 // It is a short sample buffer (32 or 64 bits in size).
 type SimpleBuffer struct {
 	vec *C.fvec_t
+	// pool is the BufferPool the buffer was taken from, if any.
+	pool *BufferPool
 }
 
 // NewSimpleBuffer constructs a new SimpleBuffer.
@@ -21,7 +27,13 @@ type SimpleBuffer struct {
 //     buf := NewSimpleBuffer(bufSize)
 //     defer buf.Free()
 func NewSimpleBuffer(size uint) *SimpleBuffer {
-	return &SimpleBuffer{C.new_fvec(C.uint_t(size))}
+	return newSimpleBuffer(C.new_fvec(C.uint_t(size)))
+}
+
+func newSimpleBuffer(v *C.fvec_t) *SimpleBuffer {
+	b := &SimpleBuffer{vec: v}
+	manage(b, (*SimpleBuffer).finalize)
+	return b
 }
 
 // NewSimpleBuffer constructs a new SimpleBuffer.
@@ -36,7 +48,7 @@ func NewSimpleBufferData(size uint, data []float64) *SimpleBuffer {
 	for i := uint(0); i < uint(len(data)); i++ {
 		C.fvec_set_sample(b, C.smpl_t(data[i]), C.uint_t(i))
 	}
-	return &SimpleBuffer{b}
+	return newSimpleBuffer(b)
 }
 
 
@@ -48,9 +60,31 @@ func (b *SimpleBuffer) Slice() []float64 {
 	for i := uint(0); i < b.Size(); i++ {
 		sl[int(i)] = float64(C.fvec_get_sample(b.vec, C.uint_t(i)))
 	}
+	runtime.KeepAlive(b)
 	return sl
 }
 
+// SetData copies data into this buffer. Samples past the end of
+// data are set to zero and any data past the end of the buffer
+// is ignored.
+func (b *SimpleBuffer) SetData(data []float64) {
+	for i := uint(0); i < b.Size(); i++ {
+		v := 0.0
+		if i < uint(len(data)) {
+			v = data[i]
+		}
+		C.fvec_set_sample(b.vec, C.smpl_t(v), C.uint_t(i))
+	}
+	runtime.KeepAlive(b)
+}
+
+// Zero sets every sample in this buffer to zero.
+func (b *SimpleBuffer) Zero() {
+	if b.vec != nil {
+		C.fvec_zeros(b.vec)
+	}
+}
+
 // Size returns the size of this buffer.
 func (b *SimpleBuffer) Size() uint {
 	if b.vec == nil {
@@ -60,18 +94,33 @@ func (b *SimpleBuffer) Size() uint {
 }
 
 // Free frees the memory aubio allocated for this buffer.
+// It is safe to call Free more than once.
 func (b *SimpleBuffer) Free() {
 	if b.vec == nil {
 		return
 	}
+	unmanage(b)
 	C.del_fvec(b.vec)
 	b.vec = nil
 }
 
+// Close frees the buffer. It always returns nil.
+func (b *SimpleBuffer) Close() error {
+	b.Free()
+	return nil
+}
+
+func (b *SimpleBuffer) finalize() {
+	leaked(b)
+	b.Free()
+}
+
 // ComplexBuffer is a wrapper for the aubio cvec_t type.
 // It contains complex sample data.
 type ComplexBuffer struct {
 	data *C.cvec_t
+	// pool is the BufferPool the buffer was taken from, if any.
+	pool *BufferPool
 }
 
 // NewComplexBuffer constructs a buffer.
@@ -82,7 +131,13 @@ type ComplexBuffer struct {
 //     buf := NewComplexBuffer(bufSize)
 //     defer buf.Free()
 func NewComplexBuffer(size uint) *ComplexBuffer {
-	return &ComplexBuffer{data: C.new_cvec(C.uint_t(size))}
+	return newComplexBuffer(C.new_cvec(C.uint_t(size)))
+}
+
+func newComplexBuffer(v *C.cvec_t) *ComplexBuffer {
+	cb := &ComplexBuffer{data: v}
+	manage(cb, (*ComplexBuffer).finalize)
+	return cb
 }
 
 // NewComplexBuffer constructs a buffer with data.
@@ -92,13 +147,34 @@ func NewComplexBufferData(size uint, data []float64) *ComplexBuffer {
 	for i := uint(0); i < uint(len(data)); i++ {
 		C.cvec_norm_set_sample(b, C.smpl_t(data[i]), C.uint_t(i))
 	}
-	return &ComplexBuffer{b}
+	return newComplexBuffer(b)
 }
 
 // Free frees the memory aubio has allocated for this buffer.
+// It is safe to call Free more than once.
 func (cb *ComplexBuffer) Free() {
 	if cb.data != nil {
+		unmanage(cb)
 		C.del_cvec(cb.data)
+		cb.data = nil
+	}
+}
+
+// Close frees the buffer. It always returns nil.
+func (cb *ComplexBuffer) Close() error {
+	cb.Free()
+	return nil
+}
+
+func (cb *ComplexBuffer) finalize() {
+	leaked(cb)
+	cb.Free()
+}
+
+// Zero sets the norm and phase of this buffer to zero.
+func (cb *ComplexBuffer) Zero() {
+	if cb.data != nil {
+		C.cvec_zeros(cb.data)
 	}
 }
 
@@ -118,6 +194,7 @@ func (cb *ComplexBuffer) Norm() []float64 {
 	for i := uint(0); i < cb.Size(); i++ {
 		sl[int(i)] = float64(C.cvec_norm_get_sample(cb.data, C.uint_t(i)))
 	}
+	runtime.KeepAlive(cb)
 	return sl
 }
 
@@ -129,12 +206,34 @@ func (cb *ComplexBuffer) Phase() []float64 {
 	for i := uint(0); i < cb.Size(); i++ {
 		sl[int(i)] = float64(C.cvec_phas_get_sample(cb.data, C.uint_t(i)))
 	}
+	runtime.KeepAlive(cb)
 	return sl
 }
 
+// SetNorm copies norm into the norm data of this buffer. Any data
+// past the end of the buffer is ignored.
+func (cb *ComplexBuffer) SetNorm(norm []float64) {
+	for i := uint(0); i < cb.Size() && i < uint(len(norm)); i++ {
+		C.cvec_norm_set_sample(cb.data, C.smpl_t(norm[i]), C.uint_t(i))
+	}
+	runtime.KeepAlive(cb)
+}
+
+// SetPhase copies phase into the phase data of this buffer. Any data
+// past the end of the buffer is ignored.
+func (cb *ComplexBuffer) SetPhase(phase []float64) {
+	for i := uint(0); i < cb.Size() && i < uint(len(phase)); i++ {
+		C.cvec_phas_set_sample(cb.data, C.smpl_t(phase[i]), C.uint_t(i))
+	}
+	runtime.KeepAlive(cb)
+}
+
 // Buffer for Long sample data (64 bits)
 type LongSampleBuffer struct {
 	vec *C.lvec_t
+	// owner is set when vec belongs to another object, which is
+	// kept alive for as long as this buffer is.
+	owner interface{}
 }
 
 // NewLBuffer constructs a *LongSampleBuffer.
@@ -145,23 +244,51 @@ type LongSampleBuffer struct {
 //     buf := NewLBuffer(bufSize)
 //     defer buf.Free()
 func NewLBuffer(size uint) *LongSampleBuffer {
-	return newLBufferFromVec(C.new_lvec(C.uint_t(size)))
+	lb := &LongSampleBuffer{vec: C.new_lvec(C.uint_t(size))}
+	manage(lb, (*LongSampleBuffer).finalize)
+	return lb
 }
 
-func newLBufferFromVec(v *C.lvec_t) *LongSampleBuffer {
-	return &LongSampleBuffer{vec: v}
+// newLBufferFromVec wraps a vector owned by owner. Freeing the
+// returned buffer leaves the vector alone.
+func newLBufferFromVec(v *C.lvec_t, owner interface{}) *LongSampleBuffer {
+	return &LongSampleBuffer{vec: v, owner: owner}
 }
 
 // Free frees the memory allocated by aubio for this buffer.
+// It is safe to call Free more than once.
 func (lb *LongSampleBuffer) Free() {
-	if lb.vec != nil {
+	if lb.vec != nil && lb.owner == nil {
+		unmanage(lb)
 		C.del_lvec(lb.vec)
-		lb.vec = nil
+	}
+	lb.vec = nil
+	lb.owner = nil
+}
+
+// Close frees the buffer. It always returns nil.
+func (lb *LongSampleBuffer) Close() error {
+	lb.Free()
+	return nil
+}
+
+func (lb *LongSampleBuffer) finalize() {
+	leaked(lb)
+	lb.Free()
+}
+
+// Zero sets every sample in this buffer to zero.
+func (lb *LongSampleBuffer) Zero() {
+	if lb.vec != nil {
+		C.lvec_zeros(lb.vec)
 	}
 }
 
 // Size returns this buffers size.
 func (lb *LongSampleBuffer) Size() uint {
+	if lb.vec == nil {
+		return 0
+	}
 	return uint(lb.vec.length)
 }
 
@@ -173,5 +300,6 @@ func (lb *LongSampleBuffer) Slice() []float64 {
 	for i := uint(0); i < lb.Size(); i++ {
 		sl[int(i)] = float64(C.lvec_get_sample(lb.vec, C.uint_t(i)))
 	}
+	runtime.KeepAlive(lb)
 	return sl
 }
@@ -0,0 +1,296 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package aubio
+
+import (
+	"fmt"
+	"math"
+)
+
+type chordQuality string
+
+const (
+	// Chord qualities recognised by LabelChords.
+	// Major triad
+	MajorChord chordQuality = "maj"
+	// Minor triad
+	MinorChord chordQuality = "min"
+	// Dominant seventh
+	SeventhChord chordQuality = "7"
+	// Diminished triad
+	DiminishedChord chordQuality = "dim"
+	// No chord, for silence and sounds without a clear harmony
+	NoChord chordQuality = "N"
+)
+
+// chordIntervals holds the pitch classes of each quality above its
+// root.
+var chordIntervals = map[chordQuality][]int{
+	MajorChord:      {0, 4, 7},
+	MinorChord:      {0, 3, 7},
+	SeventhChord:    {0, 4, 7, 10},
+	DiminishedChord: {0, 3, 6},
+}
+
+// ParseChordQuality returns the chord quality called name.
+func ParseChordQuality(name string) (chordQuality, error) {
+	q := chordQuality(name)
+	if _, ok := chordIntervals[q]; ok || q == NoChord {
+		return q, nil
+	}
+	return "", fmt.Errorf("Unknown chord quality %q", name)
+}
+
+// Chord is a chord label.
+type Chord struct {
+	// Root is the pitch class of the root, 0 being C. It is
+	// meaningless for NoChord.
+	Root    int
+	Quality chordQuality
+}
+
+// String returns the lead sheet name of the chord, e.g. "F#m", "G7"
+// or "N" for no chord.
+func (c Chord) String() string {
+	root := PitchClassName(c.Root)
+	switch c.Quality {
+	case MajorChord:
+		return root
+	case MinorChord:
+		return root + "m"
+	case SeventhChord:
+		return root + "7"
+	case DiminishedChord:
+		return root + "dim"
+	}
+	return string(NoChord)
+}
+
+// ChordConfig configures chord recognition.
+type ChordConfig struct {
+	// Chroma configures the chroma chords are recognised from.
+	Chroma ChromaConfig
+	// Qualities are the chord qualities to recognise. Defaults to
+	// major, minor, seventh and diminished. No chord is always
+	// recognised.
+	Qualities []chordQuality
+	// SelfTransition is the probability of the chord staying the
+	// same from one frame to the next. Higher values give fewer,
+	// longer chords. Defaults to 0.9.
+	SelfTransition float64
+	// Sharpness scales the similarity of a frame to each chord into
+	// a log probability. Higher values follow the frames more
+	// closely. Defaults to 20.
+	Sharpness float64
+}
+
+func (c *ChordConfig) defaults() {
+	c.Chroma.defaults()
+	if len(c.Qualities) == 0 {
+		c.Qualities = []chordQuality{MajorChord, MinorChord, SeventhChord, DiminishedChord}
+	}
+	if c.SelfTransition <= 0 || c.SelfTransition >= 1 {
+		c.SelfTransition = 0.9
+	}
+	if c.Sharpness <= 0 {
+		c.Sharpness = 20
+	}
+}
+
+// chordTemplate is a chord and its unit length chroma template.
+type chordTemplate struct {
+	chord    Chord
+	template [PitchClasses]float64
+}
+
+// chordTemplates returns the no chord template, which is flat,
+// followed by a template for every root of each quality.
+func chordTemplates(qualities []chordQuality) []chordTemplate {
+	var flat chordTemplate
+	flat.chord.Quality = NoChord
+	for i := range flat.template {
+		flat.template[i] = 1 / math.Sqrt(PitchClasses)
+	}
+	ts := []chordTemplate{flat}
+	for _, q := range qualities {
+		intervals, ok := chordIntervals[q]
+		if !ok {
+			continue
+		}
+		for root := 0; root < PitchClasses; root++ {
+			t := chordTemplate{chord: Chord{Root: root, Quality: q}}
+			for _, i := range intervals {
+				t.template[(root+i)%PitchClasses] = 1 / math.Sqrt(float64(len(intervals)))
+			}
+			ts = append(ts, t)
+		}
+	}
+	return ts
+}
+
+// similarity returns the cosine similarity of chroma and the unit
+// length template t. A silent chroma is only like no chord.
+func (t *chordTemplate) similarity(chroma []float64) float64 {
+	var dot, norm float64
+	for i, v := range chroma {
+		dot += v * t.template[i]
+		norm += v * v
+	}
+	if norm == 0 {
+		if t.chord.Quality == NoChord {
+			return 1
+		}
+		return 0
+	}
+	return dot / math.Sqrt(norm)
+}
+
+// LabelChords returns the chord of each chroma frame. The chords are
+// smoothed with the Viterbi algorithm over a hidden Markov model in
+// which each chord stays the same with probability
+// config.SelfTransition and the emission log probability of each
+// chord is its similarity to the frame times config.Sharpness. It
+// also returns the similarity of each frame to its chord.
+func LabelChords(frames [][]float64, config ChordConfig) ([]Chord, []float64) {
+	config.defaults()
+	ts := chordTemplates(config.Qualities)
+	n := len(ts)
+	stay := math.Log(config.SelfTransition)
+	change := math.Log((1 - config.SelfTransition) / float64(n-1))
+
+	sims := make([][]float64, len(frames))
+	score := make([]float64, n)
+	back := make([][]int, len(frames))
+	for f, chroma := range frames {
+		sims[f] = make([]float64, n)
+		for i := range ts {
+			sims[f][i] = ts[i].similarity(chroma)
+		}
+		back[f] = make([]int, n)
+		if f == 0 {
+			for i := range score {
+				score[i] = config.Sharpness * sims[f][i]
+			}
+			continue
+		}
+		// The best state to change from is the same for every
+		// state apart from that state itself, which can only change
+		// from the second best.
+		best, second := -1, -1
+		for i := range score {
+			switch {
+			case best < 0 || score[i] > score[best]:
+				best, second = i, best
+			case second < 0 || score[i] > score[second]:
+				second = i
+			}
+		}
+		next := make([]float64, n)
+		for i := range next {
+			other := best
+			if i == best {
+				other = second
+			}
+			from, s := i, score[i]+stay
+			if other >= 0 && score[other]+change > s {
+				from, s = other, score[other]+change
+			}
+			next[i] = s + config.Sharpness*sims[f][i]
+			back[f][i] = from
+		}
+		score = next
+	}
+	if len(frames) == 0 {
+		return nil, nil
+	}
+
+	chords := make([]Chord, len(frames))
+	similarities := make([]float64, len(frames))
+	state := 0
+	for i := range score {
+		if score[i] > score[state] {
+			state = i
+		}
+	}
+	for f := len(frames) - 1; f >= 0; f-- {
+		chords[f] = ts[state].chord
+		similarities[f] = sims[f][state]
+		state = back[f][state]
+	}
+	return chords, similarities
+}
+
+// ChordSegment is a chord and when it is played.
+type ChordSegment struct {
+	// Start and End of the chord in seconds.
+	Start float64
+	End   float64
+	Chord Chord
+	// Confidence is the mean similarity, between 0 and 1, of the
+	// chroma frames of the segment to the chord.
+	Confidence float64
+}
+
+// DetectChords reads src to the end and returns the chords played in
+// it, one segment per change of chord. src is closed when done.
+//
+//	chords, err := DetectChords(src, ChordConfig{})
+//	if err != nil {
+//		// handle error
+//	}
+//	for _, c := range chords {
+//		fmt.Printf("%.2f %s\n", c.Start, c.Chord)
+//	}
+func DetectChords(src AudioSource, config ChordConfig) ([]ChordSegment, error) {
+	config.defaults()
+	sr := src.Samplerate()
+	c, err := NewChroma(config.Chroma, sr)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Free()
+	hop := config.Chroma.HopSize
+	p := NewSimplePipeline(src, nil, hop)
+	defer p.Close()
+	var frames [][]float64
+	total := p.DoAll(func(in *SimpleBuffer) {
+		c.Do(in)
+		frames = append(frames, c.Buffer().Slice())
+	})
+	if err := p.Err(); err != nil {
+		return nil, err
+	}
+
+	chords, sims := LabelChords(frames, config)
+	duration := float64(total) / float64(sr)
+	var segs []ChordSegment
+	count := 0
+	for f, chord := range chords {
+		t := float64(f) * float64(hop) / float64(sr)
+		if len(segs) == 0 || segs[len(segs)-1].Chord != chord {
+			if len(segs) > 0 {
+				last := &segs[len(segs)-1]
+				last.End = t
+				last.Confidence /= float64(count)
+			}
+			segs = append(segs, ChordSegment{Start: t, Chord: chord})
+			count = 0
+		}
+		segs[len(segs)-1].Confidence += sims[f]
+		count++
+	}
+	if len(segs) > 0 {
+		last := &segs[len(segs)-1]
+		last.End = duration
+		last.Confidence /= float64(count)
+	}
+	return segs, nil
+}
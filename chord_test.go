@@ -0,0 +1,88 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package aubio
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// bestPathScore returns the log probability of the most likely chord
+// sequence for frames, trying every transition.
+func bestPathScore(frames [][]float64, config ChordConfig) float64 {
+	ts := chordTemplates(config.Qualities)
+	n := len(ts)
+	stay := math.Log(config.SelfTransition)
+	change := math.Log((1 - config.SelfTransition) / float64(n-1))
+	score := make([]float64, n)
+	for f, chroma := range frames {
+		next := make([]float64, n)
+		for i := range ts {
+			next[i] = math.Inf(-1)
+			for j := range score {
+				s := score[j] + change
+				if i == j {
+					s = score[j] + stay
+				}
+				if f == 0 {
+					s = 0
+				}
+				next[i] = math.Max(next[i], s)
+			}
+			next[i] += config.Sharpness * ts[i].similarity(chroma)
+		}
+		score = next
+	}
+	best := math.Inf(-1)
+	for _, s := range score {
+		best = math.Max(best, s)
+	}
+	return best
+}
+
+func TestLabelChordsViterbi(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	frames := make([][]float64, 40)
+	for f := range frames {
+		frames[f] = make([]float64, PitchClasses)
+		for i := range frames[f] {
+			frames[f][i] = rng.Float64()
+		}
+	}
+	// Below 1/n changing chord is more likely than staying.
+	for _, self := range []float64{0.001, 0.01, 0.5, 0.9, 0.999} {
+		config := ChordConfig{SelfTransition: self}
+		config.defaults()
+		n := float64(len(chordTemplates(config.Qualities)))
+		chords, sims := LabelChords(frames, config)
+		got := 0.0
+		for f := range chords {
+			got += config.Sharpness * sims[f]
+			switch {
+			case f == 0:
+			case chords[f] == chords[f-1]:
+				got += math.Log(self)
+			default:
+				got += math.Log((1 - self) / (n - 1))
+			}
+		}
+		if want := bestPathScore(frames, config); math.Abs(got-want) > 1e-9 {
+			t.Errorf("SelfTransition %g: path scores %g, want the best %g", self, got, want)
+		}
+	}
+}
+
+func TestLabelChordsEmpty(t *testing.T) {
+	if chords, sims := LabelChords(nil, ChordConfig{}); chords != nil || sims != nil {
+		t.Errorf("LabelChords(nil) = %v, %v, want nil", chords, sims)
+	}
+}
@@ -0,0 +1,176 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package aubio
+
+import (
+	"fmt"
+	"math"
+)
+
+// PitchClasses is the number of pitch classes in a chroma vector.
+const PitchClasses = 12
+
+var pitchClassNames = [PitchClasses]string{"C", "C#", "D", "D#", "E", "F", "F#", "G", "G#", "A", "A#", "B"}
+
+// PitchClassName returns the name of pitch class pc, where 0 is C.
+func PitchClassName(pc int) string {
+	return pitchClassNames[((pc%PitchClasses)+PitchClasses)%PitchClasses]
+}
+
+// ChromaConfig configures a Chroma.
+type ChromaConfig struct {
+	// BufSize is the analysis window size. The lowest semitones
+	// need long windows to be told apart. Defaults to 8192.
+	BufSize uint
+	// HopSize is the number of samples read per block. Defaults
+	// to 2048.
+	HopSize uint
+	// MinFreq is the frequency in Hz of the lowest semitone band.
+	// Defaults to C2, 65.4Hz.
+	MinFreq float64
+	// Octaves is the number of octaves of semitone bands above
+	// MinFreq. Defaults to 6.
+	Octaves uint
+	// Tuning is the frequency of A4 in Hz. Defaults to 440.
+	Tuning float64
+}
+
+func (c *ChromaConfig) defaults() {
+	if c.BufSize == 0 {
+		c.BufSize = 8192
+	}
+	if c.HopSize == 0 {
+		c.HopSize = 2048
+	}
+	if c.Tuning == 0 {
+		c.Tuning = 440
+	}
+	if c.MinFreq == 0 {
+		c.MinFreq = c.Tuning * math.Pow(2, -45.0/12)
+	}
+	if c.Octaves == 0 {
+		c.Octaves = 6
+	}
+}
+
+// Chroma computes the energy of each of the 12 pitch classes of a
+// block, folding every octave together. The spectrum from a PhaseVoc
+// is split into one triangular band per semitone by a FilterBank and
+// each band is added to its pitch class.
+type Chroma struct {
+	pv    *PhaseVoc
+	fb    *FilterBank
+	class []int
+	buf   *SimpleBuffer
+}
+
+// NewChroma constructs a Chroma for audio at samplerate.
+// It is the Callers responsibility to call Free on the returned
+// Chroma object or leak memory.
+//
+//	c, err := NewChroma(ChromaConfig{}, samplerate)
+//	if err != nil {
+//		// handle error
+//	}
+//	defer c.Free()
+func NewChroma(config ChromaConfig, samplerate uint) (*Chroma, error) {
+	config.defaults()
+	pv, err := NewPhaseVoc(config.BufSize, config.HopSize)
+	if err != nil {
+		return nil, err
+	}
+	bands := PitchClasses * config.Octaves
+	// Band i peaks on semitone i above MinFreq and spans the
+	// semitones either side of it.
+	nyquist := float64(samplerate) / 2
+	freqs := make([]float64, 0, bands+2)
+	for i := -1; i <= int(bands); i++ {
+		f := config.MinFreq * math.Pow(2, float64(i)/12)
+		if f >= nyquist {
+			break
+		}
+		freqs = append(freqs, f)
+	}
+	if len(freqs) < 3 {
+		pv.Free()
+		return nil, fmt.Errorf("No chroma bands between %g Hz and the Nyquist frequency %g Hz", config.MinFreq, nyquist)
+	}
+	c := &Chroma{pv: pv, buf: NewSimpleBuffer(PitchClasses)}
+	bands = uint(len(freqs) - 2)
+	c.fb = NewFilterBank(bands, config.BufSize)
+	if err := c.fb.SetTriangleBands(freqs, samplerate); err != nil {
+		c.Free()
+		return nil, err
+	}
+	c.class = make([]int, bands)
+	for i := range c.class {
+		midi := 69 + 12*math.Log2(freqs[i+1]/config.Tuning)
+		c.class[i] = ((int(math.Floor(midi+0.5)) % PitchClasses) + PitchClasses) % PitchClasses
+	}
+	return c, nil
+}
+
+// Buffer returns the chroma vector computed by the last call to Do,
+// indexed by pitch class starting at C. The vector is scaled so its
+// largest value is 1, unless the block was silent.
+func (c *Chroma) Buffer() *SimpleBuffer {
+	return c.buf
+}
+
+// Do computes the chroma vector of a block.
+func (c *Chroma) Do(in *SimpleBuffer) {
+	c.pv.Do(in)
+	c.fb.Do(c.pv.Grain())
+	var chroma [PitchClasses]float64
+	for i, e := range c.fb.Buffer().Slice() {
+		chroma[c.class[i]] += e
+	}
+	normalize(chroma[:])
+	c.buf.SetData(chroma[:])
+}
+
+// normalize scales v so that its largest value is 1. A v of zeros is
+// left alone.
+func normalize(v []float64) {
+	max := 0.0
+	for _, x := range v {
+		max = math.Max(max, x)
+	}
+	if max == 0 {
+		return
+	}
+	for i := range v {
+		v[i] /= max
+	}
+}
+
+// Free frees the objects used by this Chroma.
+// It is safe to call Free more than once.
+func (c *Chroma) Free() {
+	if c.pv != nil {
+		c.pv.Free()
+		c.pv = nil
+	}
+	if c.fb != nil {
+		c.fb.Free()
+		c.fb = nil
+	}
+	if c.buf != nil {
+		c.buf.Free()
+		c.buf = nil
+	}
+}
+
+// Close frees the Chroma. It always returns nil.
+func (c *Chroma) Close() error {
+	c.Free()
+	return nil
+}
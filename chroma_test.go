@@ -0,0 +1,63 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package aubio
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNewChromaNoBands(t *testing.T) {
+	// The lowest band needs the semitones either side of MinFreq
+	// below the Nyquist frequency.
+	for _, samplerate := range []uint{100, 2 * 440, 2 * 466} {
+		c, err := NewChroma(ChromaConfig{MinFreq: 440}, samplerate)
+		if err == nil {
+			c.Free()
+			t.Errorf("NewChroma at %d Hz succeeded", samplerate)
+		}
+	}
+	c, err := NewChroma(ChromaConfig{MinFreq: 440}, 2*467)
+	if err != nil {
+		t.Fatalf("NewChroma failed: %s", err)
+	}
+	c.Free()
+}
+
+func TestChromaBelowMidiZero(t *testing.T) {
+	// Bands start 4 semitones below C-1, MIDI note 0, so the lowest
+	// ones are G#, A and A#.
+	c, err := NewChroma(ChromaConfig{MinFreq: 440 * math.Pow(2, -73.0/12), Octaves: 8, BufSize: 4096}, 8000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Free()
+	for i, class := range c.class {
+		if want := (8 + i) % PitchClasses; class != want {
+			t.Errorf("Band %d has pitch class %d, want %d", i, class, want)
+		}
+	}
+	in := NewSimpleBuffer(4096)
+	defer in.Free()
+	data := make([]float64, 4096)
+	for i := range data {
+		data[i] = math.Sin(2 * math.Pi * 440 * float64(i) / 8000)
+	}
+	in.SetData(data)
+	c.Do(in)
+	chroma := c.Buffer().Slice()
+	for pc, v := range chroma {
+		if pc != 9 && v >= chroma[9] {
+			t.Errorf("Chroma of A4 = %v, peaks at %s", chroma, PitchClassName(pc))
+			break
+		}
+	}
+}
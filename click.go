@@ -0,0 +1,163 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package aubio
+
+import (
+	"io"
+	"math"
+	"sort"
+)
+
+// ClickConfig configures the sounds of a ClickTrack.
+type ClickConfig struct {
+	// BeatSound is played on every beat. Defaults to a 1kHz click.
+	BeatSound []float64
+	// AccentSound is played on downbeats instead of BeatSound.
+	// Defaults to a 1.5kHz click.
+	AccentSound []float64
+	// OnsetSound is played on every onset. Defaults to a quieter
+	// 3kHz click.
+	OnsetSound []float64
+	// BeatsPerBar is the number of beats in a bar. The first beat
+	// and every BeatsPerBar beats after it are accented. 0 accents
+	// none.
+	BeatsPerBar uint
+	// Gain is the level of the clicks in dB.
+	Gain float64
+	// SourceGain is the level of the source in dB.
+	SourceGain float64
+}
+
+// ClickSound returns a click of the given frequency and duration in
+// seconds: a sine that decays exponentially from full scale.
+func ClickSound(freq, duration float64, samplerate uint) []float64 {
+	n := int(duration * float64(samplerate))
+	sound := make([]float64, n)
+	for i := range sound {
+		t := float64(i) / float64(samplerate)
+		sound[i] = math.Sin(2*math.Pi*freq*t) * math.Exp(-t*5/duration)
+	}
+	return sound
+}
+
+// click is a sound to be mixed in starting at a frame.
+type click struct {
+	start uint
+	sound []float64
+}
+
+// ClickTrack is an AudioSource that mixes clicks on beats and onsets
+// into another source, such as the one they were detected in. To
+// render the clicks alone, use a GeneratorSource of the desired length
+// returning silence.
+type ClickTrack struct {
+	src        AudioSource
+	clicks     []click
+	next       int
+	active     []click
+	gain       float64
+	sourceGain float64
+	pos        uint
+}
+
+// NewClickTrack constructs a ClickTrack mixing clicks at beats and
+// onsets, both in seconds, into src. Either may be nil. Closing the
+// ClickTrack closes src.
+//
+//	ct := NewClickTrack(src, beats, nil, ClickConfig{BeatsPerBar: 4, Gain: -6})
+//	p := NewSimplePipeline(ct, sink, hopSize)
+//	defer p.Close()
+//	p.DoAll()
+func NewClickTrack(src AudioSource, beats, onsets []float64, config ClickConfig) *ClickTrack {
+	sr := src.Samplerate()
+	if config.BeatSound == nil {
+		config.BeatSound = ClickSound(1000, 0.03, sr)
+	}
+	if config.AccentSound == nil {
+		config.AccentSound = ClickSound(1500, 0.03, sr)
+	}
+	if config.OnsetSound == nil {
+		config.OnsetSound = ClickSound(3000, 0.015, sr)
+		for i := range config.OnsetSound {
+			config.OnsetSound[i] *= 0.5
+		}
+	}
+	ct := &ClickTrack{
+		src:        src,
+		gain:       math.Pow(10, config.Gain/20),
+		sourceGain: math.Pow(10, config.SourceGain/20),
+	}
+	frame := func(t float64) uint {
+		return uint(math.Max(0, math.Floor(t*float64(sr)+0.5)))
+	}
+	for i, t := range beats {
+		sound := config.BeatSound
+		if config.BeatsPerBar > 0 && uint(i)%config.BeatsPerBar == 0 {
+			sound = config.AccentSound
+		}
+		ct.clicks = append(ct.clicks, click{frame(t), sound})
+	}
+	for _, t := range onsets {
+		ct.clicks = append(ct.clicks, click{frame(t), config.OnsetSound})
+	}
+	sort.SliceStable(ct.clicks, func(i, j int) bool {
+		return ct.clicks[i].start < ct.clicks[j].start
+	})
+	return ct
+}
+
+// Read reads the next block of the source into buf and mixes the
+// clicks that sound during it in.
+func (ct *ClickTrack) Read(buf *SimpleBuffer) (uint, error) {
+	n, err := ct.src.Read(buf)
+	block := buf.Slice()[:n]
+	for i := range block {
+		block[i] *= ct.sourceGain
+	}
+	end := ct.pos + n
+	for ct.next < len(ct.clicks) && ct.clicks[ct.next].start < end {
+		ct.active = append(ct.active, ct.clicks[ct.next])
+		ct.next++
+	}
+	active := ct.active[:0]
+	for _, c := range ct.active {
+		for i := range block {
+			if at := ct.pos + uint(i); at >= c.start && at-c.start < uint(len(c.sound)) {
+				block[i] += c.sound[at-c.start] * ct.gain
+			}
+		}
+		if c.start+uint(len(c.sound)) > end {
+			active = append(active, c)
+		}
+	}
+	ct.active = active
+	ct.pos = end
+	buf.SetData(block)
+	return n, err
+}
+
+// Samplerate returns the samplerate of the source.
+func (ct *ClickTrack) Samplerate() uint {
+	return ct.src.Samplerate()
+}
+
+// Channels returns the number of channels of the source.
+func (ct *ClickTrack) Channels() uint {
+	return ct.src.Channels()
+}
+
+// Close closes the source.
+func (ct *ClickTrack) Close() error {
+	if c, ok := ct.src.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
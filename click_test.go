@@ -0,0 +1,87 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package aubio
+
+import (
+	"math"
+	"testing"
+)
+
+// readClicks reads all of ct in blocks of 64 samples.
+func readClicks(t *testing.T, ct *ClickTrack) []float64 {
+	t.Helper()
+	buf := NewSimpleBuffer(64)
+	defer buf.Free()
+	var out []float64
+	for {
+		n, err := ct.Read(buf)
+		out = append(out, buf.Slice()[:n]...)
+		if err != nil || n == 0 {
+			break
+		}
+	}
+	return out
+}
+
+func TestClickTrack(t *testing.T) {
+	silence := func(uint) float64 { return 0 }
+	src := NewGeneratorSource(silence, 1000, 1000, 64)
+	// The second beat straddles two blocks and the last runs past the
+	// end. The onset lands inside the first accent.
+	beats := []float64{0.1, 0.127, 0.6, 0.999}
+	onsets := []float64{0.1011}
+	config := ClickConfig{
+		BeatSound:   []float64{1, 0.5, 0.25},
+		AccentSound: []float64{-1, -0.5},
+		OnsetSound:  []float64{0.125},
+		BeatsPerBar: 2,
+		Gain:        20 * math.Log10(0.5),
+	}
+	got := readClicks(t, NewClickTrack(src, beats, onsets, config))
+	if len(got) != 1000 {
+		t.Fatalf("Read %d samples, want 1000", len(got))
+	}
+	want := make([]float64, 1000)
+	for at, v := range map[int]float64{
+		100: -0.5, 101: -0.25 + 0.0625,
+		127: 0.5, 128: 0.25, 129: 0.125,
+		600: -0.5, 601: -0.25,
+		999: 0.5,
+	} {
+		want[at] = v
+	}
+	for i := range want {
+		if math.Abs(got[i]-want[i]) > 1e-6 {
+			t.Errorf("Sample %d = %g, want %g", i, got[i], want[i])
+		}
+	}
+}
+
+func TestClickTrackSourceGain(t *testing.T) {
+	one := func(uint) float64 { return 1 }
+	src := NewGeneratorSource(one, 1600, 8000, 64)
+	config := ClickConfig{Gain: 20 * math.Log10(2), SourceGain: 20 * math.Log10(0.25)}
+	got := readClicks(t, NewClickTrack(src, []float64{0.05}, nil, config))
+	if len(got) != 1600 {
+		t.Fatalf("Read %d samples, want 1600", len(got))
+	}
+	// The default beat click is 30ms of 1kHz from the beat's sample.
+	click := ClickSound(1000, 0.03, 8000)
+	for i, v := range got {
+		want := 0.25
+		if i >= 400 && i < 400+len(click) {
+			want += 2 * click[i-400]
+		}
+		if math.Abs(v-want) > 1e-6 {
+			t.Errorf("Sample %d = %g, want %g", i, v, want)
+		}
+	}
+}
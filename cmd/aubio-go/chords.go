@@ -0,0 +1,52 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package main
+
+import (
+	"strings"
+
+	"go.marzhillstudios.com/pkg/play/aubio"
+)
+
+func runChords(args []string) error {
+	o := newOptions("chords", "file")
+	o.setSizes(8192, 2048)
+	tuning := o.Float64("tuning", 440, "Frequency of A4 in Hz")
+	qualities := o.String("qualities", "maj,min,7,dim", "Comma separated chord qualities to recognise: maj, min, 7 and dim")
+	stay := o.Float64("stay", 0.9, "Probability of a chord lasting another frame. Higher values give longer chords")
+	if err := o.parse(args); err != nil {
+		return err
+	}
+	config := aubio.ChordConfig{
+		Chroma: aubio.ChromaConfig{
+			BufSize: o.buf,
+			HopSize: o.hop,
+			Tuning:  *tuning,
+		},
+		SelfTransition: *stay,
+	}
+	for _, name := range strings.Split(*qualities, ",") {
+		q, err := aubio.ParseChordQuality(strings.TrimSpace(name))
+		if err != nil {
+			return usageError{err}
+		}
+		config.Qualities = append(config.Qualities, q)
+	}
+	src, err := o.source()
+	if err != nil {
+		return err
+	}
+	chords, err := aubio.DetectChords(src, config)
+	for _, c := range chords {
+		o.emit(o.stream.Chord(c))
+	}
+	return o.flush(err)
+}
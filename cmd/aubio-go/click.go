@@ -0,0 +1,102 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package main
+
+import (
+	"errors"
+	"io"
+
+	"go.marzhillstudios.com/pkg/play/aubio"
+)
+
+func runClick(args []string) error {
+	o := newOptions("click", "file")
+	modeName := o.String("mode", "specdiff", "Onset detection function used for beat tracking")
+	out := o.String("out", "", "Path to write the mix to")
+	onsets := o.Bool("onsets", false, "Also click on every onset")
+	gain := o.Float64("gain", 0, "Level of the clicks in dB")
+	mix := o.Float64("mix", 0, "Level of the input in dB")
+	bar := o.Uint("bar", 4, "Beats per bar. The first beat of each bar is accented. 0 accents none")
+	if err := o.parse(args); err != nil {
+		return err
+	}
+	if *out == "" {
+		return usageError{errors.New("must provide an output file with -out")}
+	}
+
+	// Find the beats, and onsets, on a first pass, then mix the
+	// clicks into the input on a second.
+	src, err := o.source()
+	if err != nil {
+		return err
+	}
+	sr := src.Samplerate()
+	tempo, err := newTempo(o, *modeName, sr)
+	if err != nil {
+		src.(io.Closer).Close()
+		return err
+	}
+	defer tempo.Free()
+	var beatTimes, onsetTimes []float64
+	fs := []aubio.ProcessFunc{func(in *aubio.SimpleBuffer) {
+		tempo.Do(in)
+		if tempo.Buffer().Slice()[0] != 0 {
+			beatTimes = append(beatTimes, tempo.GetLastBeat())
+		}
+	}}
+	if *onsets {
+		onset, err := aubio.NewOnset(aubio.HFC, o.buf, o.hop, sr)
+		if err != nil {
+			return err
+		}
+		defer onset.Free()
+		onset.SetSilence(o.silence)
+		if o.threshold != 0 {
+			onset.SetThreshold(o.threshold)
+		}
+		fs = append(fs, func(in *aubio.SimpleBuffer) {
+			onset.Do(in)
+			if onset.Buffer().Slice()[0] != 0 {
+				onsetTimes = append(onsetTimes, onset.GetLastOnset())
+			}
+		})
+	}
+	if err := o.run(src, fs...); err != nil {
+		return err
+	}
+
+	if src, err = o.source(); err != nil {
+		return err
+	}
+	ct := aubio.NewClickTrack(src, beatTimes, onsetTimes, aubio.ClickConfig{
+		BeatsPerBar: *bar,
+		Gain:        *gain,
+		SourceGain:  *mix,
+	})
+	sink, err := o.sink(*out, sr)
+	if err != nil {
+		ct.Close()
+		return err
+	}
+	p := aubio.NewSimplePipeline(ct, sink, o.hop)
+	p.DoAll()
+	err = p.Err()
+	if cerr := p.Close(); err == nil {
+		err = cerr
+	}
+	for _, t := range beatTimes {
+		o.emit(o.stream.Beat(t))
+	}
+	for _, t := range onsetTimes {
+		o.emit(o.stream.Onset(t))
+	}
+	return o.flush(err)
+}
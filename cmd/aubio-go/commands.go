@@ -0,0 +1,390 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"go.marzhillstudios.com/pkg/play/aubio"
+)
+
+func runOnset(args []string) error {
+	o := newOptions("onset", "file")
+	modeName := o.String("mode", "hfc", "Onset detection function")
+	if err := o.parse(args); err != nil {
+		return err
+	}
+	mode, err := aubio.ParseOnsetMode(*modeName)
+	if err != nil {
+		return usageError{err}
+	}
+	src, err := o.source()
+	if err != nil {
+		return err
+	}
+	onset, err := aubio.NewOnset(mode, o.buf, o.hop, src.Samplerate())
+	if err != nil {
+		src.(io.Closer).Close()
+		return err
+	}
+	defer onset.Free()
+	onset.SetSilence(o.silence)
+	if o.threshold != 0 {
+		onset.SetThreshold(o.threshold)
+	}
+	err = o.run(src, func(in *aubio.SimpleBuffer) {
+		onset.Do(in)
+		if onset.Buffer().Slice()[0] != 0 {
+			o.emit(o.stream.Onset(onset.GetLastOnset()))
+		}
+	})
+	return o.flush(err)
+}
+
+func runPitch(args []string) error {
+	o := newOptions("pitch", "file")
+	modeName := o.String("mode", "yinfft", "Pitch detection function")
+	unitName := o.String("unit", "freq", "Output unit: freq, midi, cent or bin")
+	tolerance := o.Float64("tolerance", 0, "Yin tolerance. 0 uses aubio's default")
+	if err := o.parse(args); err != nil {
+		return err
+	}
+	mode, err := aubio.ParsePitchMode(*modeName)
+	if err != nil {
+		return usageError{err}
+	}
+	unit, err := aubio.ParsePitchUnit(*unitName)
+	if err != nil {
+		return usageError{err}
+	}
+	src, err := o.source()
+	if err != nil {
+		return err
+	}
+	sr := src.Samplerate()
+	pitch, err := aubio.NewPitchChecked(mode, o.buf, o.hop, sr)
+	if err != nil {
+		src.(io.Closer).Close()
+		return err
+	}
+	defer pitch.Free()
+	pitch.SetUnit(unit)
+	if *tolerance != 0 {
+		pitch.SetTolerance(*tolerance)
+	}
+	frames := uint(0)
+	err = o.run(src, func(in *aubio.SimpleBuffer) {
+		pitch.Do(in)
+		o.emit(o.stream.Pitch(aubio.PitchFrame{
+			Time:       seconds(frames, sr),
+			Pitch:      pitch.Buffer().Slice()[0],
+			Confidence: pitch.GetConfidence(),
+		}))
+		frames += o.hop
+	})
+	return o.flush(err)
+}
+
+// newTempo constructs a Tempo object configured from the flags.
+func newTempo(o *options, modeName string, samplerate uint) (*aubio.Tempo, error) {
+	mode, err := aubio.ParseOnsetMode(modeName)
+	if err != nil {
+		return nil, usageError{err}
+	}
+	tempo, err := aubio.NewTempo(mode, o.buf, o.hop, samplerate)
+	if err != nil {
+		return nil, err
+	}
+	tempo.SetSilence(o.silence)
+	if o.threshold != 0 {
+		tempo.SetThreshold(o.threshold)
+	}
+	return tempo, nil
+}
+
+func runTempo(args []string) error {
+	o := newOptions("tempo", "file")
+	modeName := o.String("mode", "specdiff", "Onset detection function used for beat tracking")
+	if err := o.parse(args); err != nil {
+		return err
+	}
+	src, err := o.source()
+	if err != nil {
+		return err
+	}
+	sr := src.Samplerate()
+	tempo, err := newTempo(o, *modeName, sr)
+	if err != nil {
+		src.(io.Closer).Close()
+		return err
+	}
+	defer tempo.Free()
+	frames := uint(0)
+	err = o.run(src, func(in *aubio.SimpleBuffer) {
+		tempo.Do(in)
+		frames += o.hop
+	})
+	if err == nil {
+		o.emit(o.stream.Tempo(seconds(frames, sr), tempo.GetBpm(), tempo.GetConfidence()))
+	}
+	return o.flush(err)
+}
+
+func runBeat(args []string) error {
+	o := newOptions("beat", "file")
+	modeName := o.String("mode", "specdiff", "Onset detection function used for beat tracking")
+	if err := o.parse(args); err != nil {
+		return err
+	}
+	src, err := o.source()
+	if err != nil {
+		return err
+	}
+	tempo, err := newTempo(o, *modeName, src.Samplerate())
+	if err != nil {
+		src.(io.Closer).Close()
+		return err
+	}
+	defer tempo.Free()
+	err = o.run(src, func(in *aubio.SimpleBuffer) {
+		tempo.Do(in)
+		if tempo.Buffer().Slice()[0] != 0 {
+			o.emit(o.stream.Beat(tempo.GetLastBeat()))
+		}
+	})
+	return o.flush(err)
+}
+
+func runNotes(args []string) error {
+	o := newOptions("notes", "file")
+	if err := o.parse(args); err != nil {
+		return err
+	}
+	src, err := o.source()
+	if err != nil {
+		return err
+	}
+	notes, err := aubio.NewNotes(o.buf, o.hop, src.Samplerate())
+	if err != nil {
+		return err
+	}
+	defer notes.Free()
+	notes.SetSilence(o.silence)
+	if o.threshold != 0 {
+		notes.SetThreshold(o.threshold)
+	}
+	print := func(n *aubio.Note) {
+		if n != nil {
+			o.emit(o.stream.Note(*n))
+		}
+	}
+	err = o.run(src, func(in *aubio.SimpleBuffer) {
+		print(notes.Do(in))
+	})
+	print(notes.Flush())
+	return o.flush(err)
+}
+
+// runSpectral runs the phase vocoder over the source and calls f with
+// the time and spectrum of each frame.
+func runSpectral(o *options, src aubio.AudioSource, f func(t float64, grain *aubio.ComplexBuffer)) error {
+	pv, err := aubio.NewPhaseVoc(o.buf, o.hop)
+	if err != nil {
+		return err
+	}
+	defer pv.Free()
+	sr := src.Samplerate()
+	frames := uint(0)
+	return o.run(src, func(in *aubio.SimpleBuffer) {
+		pv.Do(in)
+		f(seconds(frames, sr), pv.Grain())
+		frames += o.hop
+	})
+}
+
+func runMFCC(args []string) error {
+	o := newOptions("mfcc", "file")
+	filters := o.Uint("filters", 40, "Number of mel filters")
+	coeffs := o.Uint("coeffs", 13, "Number of coefficients")
+	if err := o.parse(args); err != nil {
+		return err
+	}
+	src, err := o.source()
+	if err != nil {
+		return err
+	}
+	mfcc, err := aubio.NewMFCC(o.buf, *filters, *coeffs, src.Samplerate())
+	if err != nil {
+		return err
+	}
+	defer mfcc.Free()
+	err = runSpectral(o, src, func(t float64, grain *aubio.ComplexBuffer) {
+		mfcc.Do(grain)
+		o.emit(o.stream.Feature("mfcc", t, mfcc.Buffer().Slice()))
+	})
+	return o.flush(err)
+}
+
+func runMelbands(args []string) error {
+	o := newOptions("melbands", "file")
+	filters := o.Uint("filters", 40, "Number of mel bands")
+	if err := o.parse(args); err != nil {
+		return err
+	}
+	src, err := o.source()
+	if err != nil {
+		return err
+	}
+	fb := aubio.NewFilterBank(*filters, o.buf)
+	defer fb.Free()
+	fb.SetMelCoeffsSlaney(src.Samplerate())
+	err = runSpectral(o, src, func(t float64, grain *aubio.ComplexBuffer) {
+		fb.Do(grain)
+		o.emit(o.stream.Feature("melbands", t, fb.Buffer().Slice()))
+	})
+	return o.flush(err)
+}
+
+func runQuiet(args []string) error {
+	o := newOptions("quiet", "file")
+	minDuration := o.Float64("min", 0, "Minimum length in seconds of quiet")
+	if err := o.parse(args); err != nil {
+		return err
+	}
+	src, err := o.source()
+	if err != nil {
+		return err
+	}
+	sr := src.Samplerate()
+	regions, err := aubio.QuietRegions(src, o.hop, o.silence, *minDuration)
+	for _, region := range regions {
+		state := "NOISY"
+		if region.Silent {
+			state = "QUIET"
+		}
+		r := o.stream.Feature("quiet", seconds(region.Start, sr), nil)
+		r.Duration = seconds(region.End-region.Start, sr)
+		r.Label = state
+		o.emit(r)
+	}
+	return o.flush(err)
+}
+
+func runTrim(args []string) error {
+	o := newOptions("trim", "file")
+	out := o.String("out", "", "Path to write the trimmed audio to")
+	minDuration := o.Float64("min", 0, "Minimum length in seconds of quiet to trim at the start and end")
+	if err := o.parse(args); err != nil {
+		return err
+	}
+	if *out == "" {
+		return usageError{errors.New("must provide an output file with -out")}
+	}
+	src, err := o.source()
+	if err != nil {
+		return err
+	}
+	sr := src.Samplerate()
+	sink, err := o.sink(*out, sr)
+	if err != nil {
+		src.(io.Closer).Close()
+		return err
+	}
+	region, err := aubio.Trim(src, sink, o.hop, o.silence, *minDuration)
+	if err == nil {
+		r := o.stream.Feature(kindTrim, seconds(region.Start, sr), nil)
+		r.Duration = seconds(region.End-region.Start, sr)
+		r.Label = *out
+		o.emit(r)
+	}
+	return o.flush(err)
+}
+
+func runStretch(args []string) error {
+	o := newOptions("stretch", "file")
+	out := o.String("out", "", "Path to write the processed audio to")
+	rate := o.Float64("rate", 1, "Speed factor. Above 1 is faster")
+	semitones := o.Float64("semitones", 0, "Transposition in semitones")
+	if err := o.parse(args); err != nil {
+		return err
+	}
+	if *out == "" {
+		return usageError{errors.New("must provide an output file with -out")}
+	}
+	src, err := o.source()
+	if err != nil {
+		return err
+	}
+	if *rate != 1 {
+		ts, err := aubio.NewTimeStretch(src, *rate, o.buf, o.hop)
+		if err != nil {
+			src.(io.Closer).Close()
+			return usageError{err}
+		}
+		src = ts
+	}
+	if *semitones != 0 {
+		ps, err := aubio.NewPitchShift(src, *semitones, o.buf, o.hop)
+		if err != nil {
+			src.(io.Closer).Close()
+			return usageError{err}
+		}
+		src = ps
+	}
+	sink, err := o.sink(*out, src.Samplerate())
+	if err != nil {
+		src.(io.Closer).Close()
+		return err
+	}
+	p := aubio.NewSimplePipeline(src, sink, o.hop)
+	n := p.DoAll()
+	if o.verbose {
+		fmt.Fprintln(os.Stderr, "Wrote", n, "frames")
+	}
+	err = p.Err()
+	if cerr := p.Close(); err == nil {
+		err = cerr
+	}
+	return o.flush(err)
+}
+
+func runInfo(args []string) error {
+	o := newOptions("info", "file")
+	if err := o.parse(args); err != nil {
+		return err
+	}
+	src, err := o.source()
+	if err != nil {
+		return err
+	}
+	sr, channels := src.Samplerate(), src.Channels()
+	frames := uint(0)
+	// Not every source knows its length so count the frames.
+	p := aubio.NewSimplePipeline(src, nil, o.hop)
+	defer p.Close()
+	frames = p.DoAll()
+	if err := p.Err(); err != nil {
+		return err
+	}
+	if o.rw != nil {
+		o.emit(o.stream.Info(seconds(frames, sr), channels))
+		return o.flush(nil)
+	}
+	o.printf("path:\t%s\n", o.src)
+	o.printf("samplerate:\t%d\n", sr)
+	o.printf("channels:\t%d\n", channels)
+	o.printf("frames:\t%d\n", frames)
+	o.printf("duration:\t%.6f\n", seconds(frames, sr))
+	return o.flush(nil)
+}
@@ -0,0 +1,80 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package main
+
+import (
+	"path/filepath"
+	"strings"
+
+	"go.marzhillstudios.com/pkg/play/aubio"
+)
+
+func runCut(args []string) error {
+	o := newOptions("cut", "file")
+	modeName := o.String("mode", "", "Onset detection function. Defaults to hfc, or specdiff with -beats")
+	outDir := o.String("out", ".", "Directory to write the slices to")
+	beats := o.Bool("beats", false, "Cut at beats instead of onsets")
+	minLength := o.Float64("min", 0, "Minimum slice length in seconds")
+	fadeIn := o.Float64("fadein", 0, "Fade in length in seconds")
+	fadeOut := o.Float64("fadeout", 0, "Fade out length in seconds")
+	template := o.String("template", aubio.DefaultCutTemplate,
+		"Slice file name. {base}, {index}, {frame} and {time} are replaced")
+	if err := o.parse(args); err != nil {
+		return err
+	}
+	config := aubio.CutConfig{
+		BufSize:   o.buf,
+		HopSize:   o.hop,
+		Beats:     *beats,
+		Silence:   o.silence,
+		Threshold: o.threshold,
+		MinLength: *minLength,
+		FadeIn:    *fadeIn,
+		FadeOut:   *fadeOut,
+		Template:  *template,
+	}
+	if *modeName != "" {
+		mode, err := aubio.ParseOnsetMode(*modeName)
+		if err != nil {
+			return usageError{err}
+		}
+		config.Mode = mode
+	}
+	config.Create = o.sink
+
+	// First find the cuts, then cut the source at them on a second
+	// pass.
+	src, err := o.source()
+	if err != nil {
+		return err
+	}
+	cuts, err := aubio.FindCuts(src, config)
+	if err != nil {
+		return err
+	}
+	if src, err = o.source(); err != nil {
+		return err
+	}
+	sr := src.Samplerate()
+	base := strings.TrimSuffix(filepath.Base(o.src), filepath.Ext(o.src))
+	slices, err := aubio.WriteSlices(src, cuts, config, func(s aubio.Slice) string {
+		return filepath.Join(*outDir, config.SliceName(base, s, sr))
+	})
+	for _, s := range slices {
+		r := o.stream.Feature("slice", seconds(s.Start, sr), nil)
+		r.Duration = seconds(s.End-s.Start, sr)
+		if o.rw != nil {
+			r.Label = s.Path
+		}
+		o.emit(r)
+	}
+	return o.flush(err)
+}
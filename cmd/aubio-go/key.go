@@ -0,0 +1,94 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package main
+
+import (
+	"go.marzhillstudios.com/pkg/play/aubio"
+	"go.marzhillstudios.com/pkg/play/aubio/results"
+)
+
+func runChroma(args []string) error {
+	o := newOptions("chroma", "file")
+	o.setSizes(8192, 2048)
+	tuning := o.Float64("tuning", 440, "Frequency of A4 in Hz")
+	if err := o.parse(args); err != nil {
+		return err
+	}
+	src, err := o.source()
+	if err != nil {
+		return err
+	}
+	sr := src.Samplerate()
+	chroma, err := aubio.NewChroma(aubio.ChromaConfig{
+		BufSize: o.buf,
+		HopSize: o.hop,
+		Tuning:  *tuning,
+	}, sr)
+	if err != nil {
+		return err
+	}
+	defer chroma.Free()
+	frames := uint(0)
+	err = o.run(src, func(in *aubio.SimpleBuffer) {
+		chroma.Do(in)
+		o.emit(o.stream.Feature("chroma", seconds(frames, sr), chroma.Buffer().Slice()))
+		frames += o.hop
+	})
+	return o.flush(err)
+}
+
+func runKey(args []string) error {
+	o := newOptions("key", "file")
+	o.setSizes(8192, 2048)
+	profileName := o.String("profile", "krumhansl", "Key profile: krumhansl or temperley")
+	tuning := o.Float64("tuning", 440, "Frequency of A4 in Hz")
+	window := o.Float64("window", 0, "Also print the key of windows this many seconds long. 0 prints the overall key only")
+	step := o.Float64("step", 0, "Seconds between the starts of windows. 0 uses half the window")
+	if err := o.parse(args); err != nil {
+		return err
+	}
+	profile, err := aubio.ParseKeyProfile(*profileName)
+	if err != nil {
+		return usageError{err}
+	}
+	src, err := o.source()
+	if err != nil {
+		return err
+	}
+	r, err := aubio.DetectKey(src, aubio.KeyConfig{
+		Chroma: aubio.ChromaConfig{
+			BufSize: o.buf,
+			HopSize: o.hop,
+			Tuning:  *tuning,
+		},
+		Profile: profile,
+		Window:  *window,
+		Step:    *step,
+	})
+	if err != nil {
+		return o.flush(err)
+	}
+	o.emit(keyRecord(o.stream, 0, r.Duration, r.Key))
+	for _, s := range r.Segments {
+		o.emit(keyRecord(o.stream, s.Start, s.End-s.Start, s.Key))
+	}
+	return o.flush(nil)
+}
+
+// keyRecord returns the record of key k from t lasting duration
+// seconds.
+func keyRecord(s results.Stream, t, duration float64, k aubio.Key) results.Record {
+	r := s.Feature(kindKey, t, nil)
+	r.Duration = duration
+	r.Label = k.String()
+	r.Confidence = k.Confidence
+	return r
+}
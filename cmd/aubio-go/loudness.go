@@ -0,0 +1,62 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package main
+
+import (
+	"math"
+
+	"go.marzhillstudios.com/pkg/play/aubio"
+)
+
+// loudnessLimit bounds the values printed in dB so every output
+// format can write the -Inf loudness of silence and the +Inf gain it
+// would need.
+const loudnessLimit = 120
+
+func finiteLoudness(v float64) float64 {
+	return math.Max(-loudnessLimit, math.Min(v, loudnessLimit))
+}
+
+func runLoudness(args []string) error {
+	o := newOptions("loudness", "file")
+	target := o.Float64("target", -23, "Target loudness in LUFS the printed gain is for")
+	series := o.Bool("series", false, "Also print the momentary and short-term loudness every 100ms")
+	if err := o.parse(args); err != nil {
+		return err
+	}
+	src, err := o.source()
+	if err != nil {
+		return err
+	}
+	l, err := aubio.MeasureLoudness(src, o.hop)
+	if err != nil {
+		return o.flush(err)
+	}
+	r := o.stream.Feature(kindLoudness, 0, []float64{
+		finiteLoudness(l.Integrated()),
+		l.Range(),
+		finiteLoudness(l.TruePeak()),
+		finiteLoudness(l.SamplePeak()),
+		finiteLoudness(l.Gain(*target)),
+	})
+	r.Duration = l.Duration()
+	o.emit(r)
+	if *series {
+		short := l.ShortTerm()
+		for i, m := range l.Momentary() {
+			o.emit(o.stream.Feature("momentary", float64(i+1)/10, []float64{
+				finiteLoudness(m),
+				finiteLoudness(short[i]),
+			}))
+		}
+	}
+	return o.flush(nil)
+}
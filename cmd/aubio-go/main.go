@@ -0,0 +1,311 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+// aubio-go is a command line front end for the aubio package.
+// Run it: aubio-go <command> [flags] file.wav
+//
+// By default every command prints one line per event starting with
+// the time of the event in seconds. The -format flag selects JSON,
+// JSON Lines or CSV output using the schema of the results package,
+// or label tracks for Audacity and Sonic Visualiser, instead. It
+// exits with status 2 on usage errors and status 1 if the analysis
+// fails.
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"go.marzhillstudios.com/pkg/play/aubio"
+	"go.marzhillstudios.com/pkg/play/aubio/results"
+)
+
+type command struct {
+	summary string
+	run     func(args []string) error
+}
+
+var commands = map[string]command{
+	"onset":    {"print the time of each onset", runOnset},
+	"pitch":    {"print the pitch of each frame", runPitch},
+	"tempo":    {"print the overall tempo", runTempo},
+	"beat":     {"print the time of each beat", runBeat},
+	"notes":    {"print the start, end, pitch and velocity of each note", runNotes},
+	"mfcc":     {"print the mel frequency cepstrum coefficients of each frame", runMFCC},
+	"melbands": {"print the mel band energies of each frame", runMelbands},
+	"quiet":    {"print the times the input becomes quiet or noisy", runQuiet},
+	"cut":      {"slice the input at each onset", runCut},
+	"trim":     {"copy the input without its quiet start and end", runTrim},
+	"stretch":  {"change the speed and pitch of the input independently", runStretch},
+	"click":    {"mix a click on each beat into the input", runClick},
+	"specgram": {"draw the spectrogram of the input as a PNG", runSpectrogram},
+	"waveform": {"write the waveform peaks of the input for display", runWaveform},
+	"tune":     {"search for the detector parameters that best match annotations", runTune},
+	"chroma":   {"print the pitch class energies of each frame", runChroma},
+	"key":      {"print the musical key of the input", runKey},
+	"chords":   {"print the chords of the input", runChords},
+	"loudness": {"print the EBU R128 loudness and true peak of the input", runLoudness},
+	"info":     {"print the samplerate, channels and duration", runInfo},
+}
+
+// usageError is returned by commands when they are invoked wrongly.
+type usageError struct {
+	error
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: aubio-go <command> [flags] file")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Commands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-9s %s\n", name, commands[name].summary)
+	}
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Run aubio-go <command> -help for the flags of a command.")
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	name := os.Args[1]
+	if name == "help" || name == "-help" || name == "-h" {
+		usage()
+		return
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "aubio-go: unknown command %q\n", name)
+		usage()
+		os.Exit(2)
+	}
+	err := cmd.run(os.Args[2:])
+	var uerr usageError
+	switch {
+	case err == nil:
+	case errors.Is(err, flag.ErrHelp):
+	case errors.As(err, &uerr):
+		fmt.Fprintf(os.Stderr, "aubio-go %s: %s\n", name, err)
+		os.Exit(2)
+	default:
+		fmt.Fprintf(os.Stderr, "aubio-go %s: %s\n", name, err)
+		os.Exit(1)
+	}
+}
+
+// options holds the flags shared by every command.
+type options struct {
+	*flag.FlagSet
+	src        string
+	samplerate uint
+	hop        uint
+	buf        uint
+	silence    float64
+	threshold  float64
+	wav        bool
+	verbose    bool
+	format     string
+	out        *bufio.Writer
+	// rw writes records when a structured format was requested.
+	rw     results.Writer
+	stream results.Stream
+	err    error
+}
+
+func newOptions(name, args string) *options {
+	o := &options{FlagSet: flag.NewFlagSet(name, flag.ContinueOnError)}
+	o.StringVar(&o.src, "src", "", "Path to source file. May also be given as the first argument")
+	o.UintVar(&o.samplerate, "samplerate", 0, "Sample rate to open the source with. 0 uses the file's rate")
+	o.UintVar(&o.hop, "hop", 256, "Hop size, the number of samples read per block")
+	o.UintVar(&o.buf, "buf", 512, "Buffer size, the analysis window length")
+	o.Float64Var(&o.silence, "silence", -90, "Silence threshold in dB")
+	o.Float64Var(&o.threshold, "threshold", 0, "Detection threshold. 0 uses aubio's default")
+	o.BoolVar(&o.wav, "wav", false, "Decode the source with the built in WAV reader instead of aubio")
+	o.BoolVar(&o.verbose, "verbose", false, "Print verbose output")
+	o.StringVar(&o.format, "format", "text", "Output format: text, json, jsonl, csv, audacity or svcsv")
+	o.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: aubio-go %s [flags] %s\n\nFlags:\n", name, args)
+		o.PrintDefaults()
+	}
+	o.out = bufio.NewWriter(os.Stdout)
+	return o
+}
+
+// setSizes changes the default buffer and hop sizes, for commands
+// that need longer windows than the detectors.
+func (o *options) setSizes(buf, hop uint) {
+	o.buf, o.hop = buf, hop
+	o.Lookup("buf").DefValue = fmt.Sprint(buf)
+	o.Lookup("hop").DefValue = fmt.Sprint(hop)
+}
+
+// parse parses args and checks the shared flags.
+func (o *options) parse(args []string) error {
+	if err := o.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return err
+		}
+		return usageError{err}
+	}
+	if o.src == "" && o.NArg() > 0 {
+		o.src = o.Arg(0)
+	}
+	if o.src == "" {
+		o.Usage()
+		return usageError{errors.New("must provide a source file")}
+	}
+	if o.hop == 0 || o.buf < o.hop {
+		return usageError{fmt.Errorf("invalid hop size %d for buffer size %d", o.hop, o.buf)}
+	}
+	if o.format != "text" {
+		f, err := results.ParseFormat(o.format)
+		if err != nil {
+			return usageError{err}
+		}
+		if o.rw, err = results.NewWriter(o.out, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// source opens the source file.
+func (o *options) source() (aubio.AudioSource, error) {
+	if o.verbose {
+		fmt.Fprintln(os.Stderr, "Input file:", o.src)
+	}
+	var s aubio.AudioSource
+	if o.wav {
+		ws, err := aubio.OpenWavSource(o.src, o.samplerate, o.hop)
+		if err != nil {
+			return nil, err
+		}
+		s = ws
+	} else {
+		as, err := aubio.OpenSource(o.src, o.samplerate, o.hop)
+		if err != nil {
+			return nil, err
+		}
+		s = as
+	}
+	o.stream = results.Stream{File: o.src, Samplerate: s.Samplerate(), Hop: o.hop}
+	return s, nil
+}
+
+// sink opens a sink for writing the file at path.
+func (o *options) sink(path string, samplerate uint) (aubio.AudioSink, error) {
+	if o.wav {
+		s, err := aubio.OpenWavSink(path, samplerate)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	}
+	s, err := aubio.OpenSink(path, samplerate)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// run pipes src through fs and closes it.
+func (o *options) run(src aubio.AudioSource, fs ...aubio.ProcessFunc) error {
+	p := aubio.NewSimplePipeline(src, nil, o.hop)
+	defer p.Close()
+	n := p.DoAll(fs...)
+	if o.verbose {
+		fmt.Fprintln(os.Stderr, "Processed", n, "frames")
+	}
+	return p.Err()
+}
+
+// seconds converts a frame count into seconds.
+func seconds(frames, samplerate uint) float64 {
+	return float64(frames) / float64(samplerate)
+}
+
+// printf writes a line of output.
+func (o *options) printf(format string, args ...interface{}) {
+	fmt.Fprintf(o.out, format, args...)
+}
+
+// printRow writes a time stamp followed by a tab separated row of values.
+func (o *options) printRow(t float64, vals []float64) {
+	fmt.Fprintf(o.out, "%.6f", t)
+	for _, v := range vals {
+		fmt.Fprintf(o.out, "\t%.6f", v)
+	}
+	io.WriteString(o.out, "\n")
+}
+
+// Kinds of the records written by the commands on top of those in
+// the results package.
+const (
+	kindTrim     = "trim"
+	kindLoudness = "loudness"
+	kindKey      = "key"
+)
+
+// emit writes r in the requested format. The first write error is
+// kept and returned by flush.
+func (o *options) emit(r results.Record) {
+	if o.rw != nil {
+		if err := o.rw.Write(r); err != nil && o.err == nil {
+			o.err = err
+		}
+		return
+	}
+	switch r.Kind {
+	case results.Pitch, results.Tempo:
+		o.printRow(r.Time, []float64{r.Value, r.Confidence})
+	case results.Note:
+		o.printRow(r.Time, []float64{r.Time + r.Duration, r.Value, r.Velocity})
+	case kindTrim:
+		o.printRow(r.Time, []float64{r.Time + r.Duration})
+	case kindLoudness:
+		o.printf("integrated\t%.1f LUFS\nrange\t%.1f LU\ntrue peak\t%.1f dBTP\nsample peak\t%.1f dBFS\ngain\t%.1f dB\n",
+			r.Values[0], r.Values[1], r.Values[2], r.Values[3], r.Values[4])
+	case kindKey, results.Chord:
+		o.printf("%.6f\t%.6f\t%s\t%.6f\n", r.Time, r.Time+r.Duration, r.Label, r.Confidence)
+	default:
+		if r.Label != "" {
+			o.printf("%.6f\t%s\n", r.Time, r.Label)
+		} else {
+			o.printRow(r.Time, r.Values)
+		}
+	}
+}
+
+// flush completes and flushes the output, keeping err if it is set.
+func (o *options) flush(err error) error {
+	if o.rw != nil {
+		if cerr := o.rw.Close(); o.err == nil {
+			o.err = cerr
+		}
+	}
+	if err == nil {
+		err = o.err
+	}
+	if ferr := o.out.Flush(); err == nil {
+		err = ferr
+	}
+	return err
+}
@@ -0,0 +1,146 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+
+	"go.marzhillstudios.com/pkg/play/aubio"
+	"go.marzhillstudios.com/pkg/play/aubio/plot"
+)
+
+func runSpectrogram(args []string) error {
+	o := newOptions("specgram", "file")
+	out := o.String("out", "", "Path to write the PNG to")
+	scaleName := o.String("scale", "linear", "Frequency scale: linear, log or mel")
+	bands := o.Uint("bands", 96, "Number of bands of the log scale")
+	minFreq := o.Float64("minfreq", 50, "Lowest frequency of the log scale in Hz")
+	maxFreq := o.Float64("maxfreq", 0, "Highest frequency of the log scale in Hz. 0 uses the Nyquist frequency")
+	width := o.Int("width", 0, "Width of the image. 0 uses one pixel per frame")
+	height := o.Int("height", 0, "Height of the image. 0 uses one pixel per band")
+	colormap := o.String("colormap", "viridis", "Colormap: gray, viridis, magma or inferno")
+	dbRange := o.Float64("range", plot.DefaultRange, "Range in dB below the loudest cell to show")
+	onsets := o.String("onsets", "", "Onset detection function to mark onsets with. Empty marks none")
+	beats := o.String("beats", "", "Onset detection function to mark beats with. Empty marks none")
+	pitch := o.String("pitch", "", "Pitch detection function to mark pitches with. Empty marks none")
+	if err := o.parse(args); err != nil {
+		return err
+	}
+	if *out == "" {
+		return usageError{errors.New("must provide an output file with -out")}
+	}
+	scale, err := aubio.ParseSpectrumScale(*scaleName)
+	if err != nil {
+		return usageError{err}
+	}
+	cmap, err := plot.ParseColormap(*colormap)
+	if err != nil {
+		return usageError{err}
+	}
+	src, err := o.source()
+	if err != nil {
+		return err
+	}
+	// run closes src as well. Closing it twice is harmless and this
+	// covers the detectors failing to build.
+	defer src.(io.Closer).Close()
+	sr := src.Samplerate()
+	spec, err := aubio.NewSpectrogram(aubio.SpectrogramConfig{
+		BufSize: o.buf,
+		HopSize: o.hop,
+		Scale:   scale,
+		Bands:   *bands,
+		MinFreq: *minFreq,
+		MaxFreq: *maxFreq,
+	}, sr)
+	if err != nil {
+		return err
+	}
+	defer spec.Free()
+	opts := plot.SpectrogramOptions{
+		Width:    *width,
+		Height:   *height,
+		Colormap: cmap,
+		Range:    *dbRange,
+	}
+	fs := []aubio.ProcessFunc{spec.Do}
+	if *onsets != "" {
+		mode, err := aubio.ParseOnsetMode(*onsets)
+		if err != nil {
+			return usageError{err}
+		}
+		onset, err := aubio.NewOnset(mode, o.buf, o.hop, sr)
+		if err != nil {
+			return err
+		}
+		defer onset.Free()
+		onset.SetSilence(o.silence)
+		if o.threshold != 0 {
+			onset.SetThreshold(o.threshold)
+		}
+		fs = append(fs, func(in *aubio.SimpleBuffer) {
+			onset.Do(in)
+			if onset.Buffer().Slice()[0] != 0 {
+				opts.Onsets = append(opts.Onsets, onset.GetLastOnset())
+			}
+		})
+	}
+	if *beats != "" {
+		tempo, err := newTempo(o, *beats, sr)
+		if err != nil {
+			return err
+		}
+		defer tempo.Free()
+		fs = append(fs, func(in *aubio.SimpleBuffer) {
+			tempo.Do(in)
+			if tempo.Buffer().Slice()[0] != 0 {
+				opts.Beats = append(opts.Beats, tempo.GetLastBeat())
+			}
+		})
+	}
+	if *pitch != "" {
+		mode, err := aubio.ParsePitchMode(*pitch)
+		if err != nil {
+			return usageError{err}
+		}
+		p, err := aubio.NewPitchChecked(mode, o.buf, o.hop, sr)
+		if err != nil {
+			return err
+		}
+		defer p.Free()
+		p.SetUnit(aubio.PitchOutFreq)
+		frames := uint(0)
+		fs = append(fs, func(in *aubio.SimpleBuffer) {
+			p.Do(in)
+			opts.Pitches = append(opts.Pitches, aubio.PitchFrame{
+				Time:       seconds(frames, sr),
+				Pitch:      p.Buffer().Slice()[0],
+				Confidence: p.GetConfidence(),
+			})
+			frames += o.hop
+		})
+	}
+	if err := o.run(src, fs...); err != nil {
+		return err
+	}
+
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	if err := plot.WriteSpectrogramPNG(f, spec, opts); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
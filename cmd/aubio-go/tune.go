@@ -0,0 +1,176 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"go.marzhillstudios.com/pkg/play/aubio"
+	"go.marzhillstudios.com/pkg/play/aubio/eval"
+	"go.marzhillstudios.com/pkg/play/aubio/results"
+	"go.marzhillstudios.com/pkg/play/aubio/tune"
+)
+
+// readLabels reads reference times from a label file. The format is
+// chosen by the extension: .svl for Sonic Visualiser layers, .csv for
+// Sonic Visualiser CSV and anything else for Audacity label tracks.
+func readLabels(path string) ([]float64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var recs []results.Record
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".svl":
+		recs, err = results.ReadSonicVisualiserXML(f, "")
+	case ".csv":
+		recs, err = results.ReadSonicVisualiserCSV(f, "")
+	default:
+		recs, err = results.ReadAudacityLabels(f, "")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return eval.Times(recs), nil
+}
+
+// floatList parses a comma separated list of numbers.
+func floatList(s string) ([]float64, error) {
+	var vals []float64
+	for _, f := range strings.Split(s, ",") {
+		if f = strings.TrimSpace(f); f == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			return nil, err
+		}
+		vals = append(vals, v)
+	}
+	return vals, nil
+}
+
+// uintList parses a comma separated list of sizes.
+func uintList(s string) ([]uint, error) {
+	vals, err := floatList(s)
+	if err != nil {
+		return nil, err
+	}
+	sizes := make([]uint, len(vals))
+	for i, v := range vals {
+		if v < 0 || v != float64(uint(v)) {
+			return nil, fmt.Errorf("invalid size %g", v)
+		}
+		sizes[i] = uint(v)
+	}
+	return sizes, nil
+}
+
+func runTune(args []string) error {
+	fs := flag.NewFlagSet("tune", flag.ContinueOnError)
+	targetName := fs.String("target", "onset", "What to tune: onset or beat")
+	modes := fs.String("modes", "", "Comma separated onset detection functions to try")
+	thresholds := fs.String("thresholds", "", "Comma separated thresholds to try")
+	silences := fs.String("silences", "", "Comma separated silence thresholds in dB to try")
+	bufs := fs.String("bufs", "512", "Comma separated buffer sizes to try")
+	hops := fs.String("hops", "256", "Comma separated hop sizes to try")
+	random := fs.Int("random", 0, "Try this many random configurations instead of all of them")
+	seed := fs.Int64("seed", 1, "Seed for -random")
+	workers := fs.Int("workers", 0, "Number of configurations tried at once. 0 uses every CPU")
+	wav := fs.Bool("wav", false, "Decode the sources with the built in WAV reader instead of aubio")
+	verbose := fs.Bool("verbose", false, "Print progress")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: aubio-go tune [flags] file labels [file labels...]\n\n")
+		fmt.Fprintf(os.Stderr, "Labels are Audacity label tracks, Sonic Visualiser CSV (.csv) or layers (.svl).\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return err
+		}
+		return usageError{err}
+	}
+	if fs.NArg() == 0 || fs.NArg()%2 != 0 {
+		fs.Usage()
+		return usageError{errors.New("must provide pairs of source and label files")}
+	}
+
+	t := &tune.Tuner{Workers: *workers}
+	switch *targetName {
+	case "onset":
+		t.Target = tune.Onsets
+	case "beat":
+		t.Target = tune.Beats
+	default:
+		return usageError{fmt.Errorf("unknown target %q", *targetName)}
+	}
+	var err error
+	if *modes != "" {
+		t.Space.Modes = strings.Split(*modes, ",")
+	}
+	if t.Space.Thresholds, err = floatList(*thresholds); err != nil {
+		return usageError{err}
+	}
+	if t.Space.Silences, err = floatList(*silences); err != nil {
+		return usageError{err}
+	}
+	if t.Space.BufSizes, err = uintList(*bufs); err != nil {
+		return usageError{err}
+	}
+	if t.Space.HopSizes, err = uintList(*hops); err != nil {
+		return usageError{err}
+	}
+	if *wav {
+		t.Base.Open = func(path string, samplerate, hopSize uint) (aubio.AudioSource, error) {
+			s, err := aubio.OpenWavSource(path, samplerate, hopSize)
+			if err != nil {
+				return nil, err
+			}
+			return s, nil
+		}
+	}
+	for i := 0; i < fs.NArg(); i += 2 {
+		times, err := readLabels(fs.Arg(i + 1))
+		if err != nil {
+			return err
+		}
+		ex := tune.Example{Path: fs.Arg(i)}
+		if t.Target == tune.Beats {
+			ex.Beats = times
+		} else {
+			ex.Onsets = times
+		}
+		t.Examples = append(t.Examples, ex)
+	}
+	if *verbose {
+		t.Progress = func(done, total int, trial *tune.Trial) {
+			fmt.Fprintf(os.Stderr, "%d/%d\t%.4f\n", done, total, trial.Score)
+		}
+	}
+
+	var report *tune.Report
+	if *random > 0 {
+		report, err = t.Random(*random, *seed)
+	} else {
+		report, err = t.Grid()
+	}
+	if err != nil {
+		return usageError{err}
+	}
+	return report.WriteTable(os.Stdout)
+}
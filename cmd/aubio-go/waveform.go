@@ -0,0 +1,82 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"go.marzhillstudios.com/pkg/play/aubio/waveform"
+)
+
+func runWaveform(args []string) error {
+	o := newOptions("waveform", "file")
+	out := o.String("out", "", "Path to write the peaks to. A .json extension writes JSON, anything else binary")
+	zooms := o.String("zoom", "256", "Comma separated samples per pixel of each zoom level")
+	bits := o.Int("bits", 8, "Bits per value: 8 or 16")
+	if err := o.parse(args); err != nil {
+		return err
+	}
+	if *out == "" {
+		return usageError{errors.New("must provide an output file with -out")}
+	}
+	levels, err := uintList(*zooms)
+	if err != nil || len(levels) == 0 {
+		return usageError{fmt.Errorf("invalid zoom levels %q", *zooms)}
+	}
+	if *bits != 8 && *bits != 16 {
+		return usageError{fmt.Errorf("invalid bits %d", *bits)}
+	}
+	src, err := o.source()
+	if err != nil {
+		return err
+	}
+	peaks, err := waveform.Peaks(src, levels...)
+	if err != nil {
+		return err
+	}
+	ext := filepath.Ext(*out)
+	for _, l := range peaks {
+		// With several zoom levels each goes to its own file named
+		// after its samples per pixel.
+		path := *out
+		if len(peaks) > 1 {
+			path = fmt.Sprintf("%s_%d%s", strings.TrimSuffix(*out, ext), l.SamplesPerPixel, ext)
+		}
+		if err := writeLevel(path, l, *bits); err != nil {
+			return err
+		}
+		if o.verbose {
+			fmt.Fprintln(os.Stderr, "Wrote", l.Len(), "pixels to", path)
+		}
+	}
+	return nil
+}
+
+// writeLevel writes l to path in the format its extension selects.
+func writeLevel(path string, l *waveform.Level, bits int) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = l.WriteJSON(f, bits)
+	} else {
+		err = l.WriteBinary(f, bits)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,345 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package aubio
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// CutConfig configures slicing audio with Cut.
+type CutConfig struct {
+	// BufSize is the analysis window size. Defaults to 512.
+	BufSize uint
+	// HopSize is the number of samples read per block. Defaults to 256.
+	HopSize uint
+	// Samplerate to open the file with. 0 uses the file's own rate.
+	Samplerate uint
+
+	// Beats cuts at the beats found by Tempo instead of at onsets.
+	Beats bool
+	// Mode is the onset detection function. Defaults to HFC when
+	// cutting at onsets and SpecDiff when cutting at beats.
+	Mode onsetMode
+	// Silence is the silence threshold in dB. 0 leaves aubio's default.
+	Silence float64
+	// Threshold is the peak picking threshold. 0 leaves aubio's default.
+	Threshold float64
+
+	// MinLength is the shortest slice in seconds. Cuts closer than
+	// this to the previous one are dropped.
+	MinLength float64
+	// FadeIn and FadeOut are the lengths in seconds of the linear
+	// fades applied to the start and end of every slice.
+	FadeIn  float64
+	FadeOut float64
+
+	// Template names the file of each slice. "{base}" is replaced
+	// with the name of the cut file without its extension, "{index}"
+	// with the number of the slice starting at 0, "{frame}" with its
+	// first sample and "{time}" with its start in seconds. Defaults
+	// to "{base}_{time}.wav".
+	Template string
+
+	// Open opens the file to cut. Defaults to OpenSource.
+	Open func(path string, samplerate, hopSize uint) (AudioSource, error)
+	// Create opens the file a slice is written to. Defaults to OpenSink.
+	Create func(path string, samplerate uint) (AudioSink, error)
+}
+
+// DefaultCutTemplate is the Template used when a CutConfig has none.
+const DefaultCutTemplate = "{base}_{time}.wav"
+
+// Slice is a segment written by Cut.
+type Slice struct {
+	// Index is the number of the slice starting at 0.
+	Index int
+	// Start and End are the first sample of the slice and the
+	// sample after its last.
+	Start uint
+	End   uint
+	// Path is the file the slice was written to.
+	Path string
+}
+
+func openSink(path string, samplerate uint) (AudioSink, error) {
+	s, err := OpenSink(path, samplerate)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (c *CutConfig) defaults() {
+	if c.BufSize == 0 {
+		c.BufSize = 512
+	}
+	if c.HopSize == 0 {
+		c.HopSize = 256
+	}
+	if c.Mode == "" {
+		c.Mode = HFC
+		if c.Beats {
+			c.Mode = SpecDiff
+		}
+	}
+	if c.Template == "" {
+		c.Template = DefaultCutTemplate
+	}
+	if c.Open == nil {
+		c.Open = openSource
+	}
+	if c.Create == nil {
+		c.Create = openSink
+	}
+}
+
+// Cut slices the file at path at its onsets, or its beats, and writes
+// each slice to its own file. The file is read twice: once to find
+// the cuts and once to write the slices.
+//
+//	slices, err := Cut("loop.wav", CutConfig{MinLength: 0.05, FadeOut: 0.005})
+//	if err != nil {
+//		// handle error
+//	}
+//	for _, s := range slices {
+//		fmt.Println(s.Path)
+//	}
+func Cut(path string, config CutConfig) ([]Slice, error) {
+	config.defaults()
+	src, err := config.Open(path, config.Samplerate, config.HopSize)
+	if err != nil {
+		return nil, err
+	}
+	cuts, err := FindCuts(src, config)
+	if err != nil {
+		return nil, err
+	}
+	if src, err = config.Open(path, config.Samplerate, config.HopSize); err != nil {
+		return nil, err
+	}
+	base := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	sr := src.Samplerate()
+	return WriteSlices(src, cuts, config, func(s Slice) string {
+		return config.SliceName(base, s, sr)
+	})
+}
+
+// SliceName returns the file name of slice s of the file called base
+// by expanding the Template.
+func (c *CutConfig) SliceName(base string, s Slice, samplerate uint) string {
+	template := c.Template
+	if template == "" {
+		template = DefaultCutTemplate
+	}
+	return strings.NewReplacer(
+		"{base}", base,
+		"{index}", strconv.Itoa(s.Index),
+		"{frame}", strconv.FormatUint(uint64(s.Start), 10),
+		"{time}", fmt.Sprintf("%.6f", float64(s.Start)/float64(samplerate)),
+	).Replace(template)
+}
+
+// FindCuts reads src to the end and returns the sample positions to
+// cut it at, starting with 0. src is closed when done.
+func FindCuts(src AudioSource, config CutConfig) ([]uint, error) {
+	config.defaults()
+	sr := src.Samplerate()
+	p := NewSimplePipeline(src, nil, config.HopSize)
+	defer p.Close()
+
+	minLength := uint(config.MinLength * float64(sr))
+	cuts := []uint{0}
+	add := func(t float64) {
+		at := uint(t * float64(sr))
+		if last := cuts[len(cuts)-1]; at > last && at-last >= minLength {
+			cuts = append(cuts, at)
+		}
+	}
+	var f ProcessFunc
+	if config.Beats {
+		t, err := NewTempo(config.Mode, config.BufSize, config.HopSize, sr)
+		if err != nil {
+			return nil, err
+		}
+		defer t.Free()
+		if config.Silence != 0 {
+			t.SetSilence(config.Silence)
+		}
+		if config.Threshold != 0 {
+			t.SetThreshold(config.Threshold)
+		}
+		f = func(in *SimpleBuffer) {
+			t.Do(in)
+			if t.Buffer().Slice()[0] != 0 {
+				add(t.GetLastBeat())
+			}
+		}
+	} else {
+		o, err := NewOnset(config.Mode, config.BufSize, config.HopSize, sr)
+		if err != nil {
+			return nil, err
+		}
+		defer o.Free()
+		if config.Silence != 0 {
+			o.SetSilence(config.Silence)
+		}
+		if config.Threshold != 0 {
+			o.SetThreshold(config.Threshold)
+		}
+		f = func(in *SimpleBuffer) {
+			o.Do(in)
+			if o.Buffer().Slice()[0] != 0 {
+				add(o.GetLastOnset())
+			}
+		}
+	}
+	p.DoAll(f)
+	return cuts, p.Err()
+}
+
+// WriteSlices reads src to the end and writes the samples between
+// consecutive cuts to the sinks opened by config.Create, applying the
+// fades in config. name returns the path of each slice. src is
+// closed when done. The slices written are returned, even on error.
+func WriteSlices(src AudioSource, cuts []uint, config CutConfig, name func(Slice) string) ([]Slice, error) {
+	config.defaults()
+	if c, ok := src.(io.Closer); ok {
+		defer c.Close()
+	}
+	sr := src.Samplerate()
+	buf := newBuffer(config.HopSize)
+	defer freeBuffer(buf)
+	out := newBuffer(config.HopSize)
+	defer freeBuffer(out)
+
+	w := &sliceWriter{
+		out:     out,
+		fadeIn:  uint(config.FadeIn * float64(sr)),
+		fadeOut: uint(config.FadeOut * float64(sr)),
+	}
+	var slices []Slice
+	finish := func(end uint) error {
+		if w.sink == nil {
+			return nil
+		}
+		slices[len(slices)-1].End = end
+		return w.close()
+	}
+	pos, next := uint(0), 0
+	defer func() { finish(pos) }()
+	for {
+		n, rerr := src.Read(buf)
+		block := buf.Slice()[:n]
+		for len(block) > 0 {
+			for next < len(cuts) && cuts[next] < pos {
+				next++
+			}
+			if next < len(cuts) && pos == cuts[next] {
+				if err := finish(pos); err != nil {
+					return slices, err
+				}
+				s := Slice{Index: len(slices), Start: pos}
+				s.Path = name(s)
+				sink, err := config.Create(s.Path, sr)
+				if err != nil {
+					return slices, err
+				}
+				slices = append(slices, s)
+				w.open(sink)
+				next++
+			}
+			m := uint(len(block))
+			if next < len(cuts) && cuts[next]-pos < m {
+				m = cuts[next] - pos
+			}
+			if w.sink != nil {
+				if err := w.write(block[:m]); err != nil {
+					return slices, err
+				}
+			}
+			block = block[m:]
+			pos += m
+		}
+		if rerr == io.EOF || n == 0 {
+			break
+		}
+		if rerr != nil {
+			return slices, rerr
+		}
+	}
+	return slices, finish(pos)
+}
+
+// sliceWriter writes one slice at a time, fading it in and out. The
+// last fadeOut samples are held back until the end of the slice is
+// known.
+type sliceWriter struct {
+	sink    AudioSink
+	out     *SimpleBuffer
+	fadeIn  uint
+	fadeOut uint
+	written uint
+	pending []float64
+}
+
+func (w *sliceWriter) open(sink AudioSink) {
+	w.sink = sink
+	w.written = 0
+	w.pending = w.pending[:0]
+}
+
+func (w *sliceWriter) write(samples []float64) error {
+	for _, v := range samples {
+		if i := w.written + uint(len(w.pending)); i < w.fadeIn {
+			v *= float64(i) / float64(w.fadeIn)
+		}
+		w.pending = append(w.pending, v)
+	}
+	if uint(len(w.pending)) > w.fadeOut {
+		ready := uint(len(w.pending)) - w.fadeOut
+		if err := w.flush(w.pending[:ready]); err != nil {
+			return err
+		}
+		w.pending = append(w.pending[:0], w.pending[ready:]...)
+	}
+	return nil
+}
+
+// close fades out the held back samples, writes them and closes the
+// sink.
+func (w *sliceWriter) close() error {
+	n := len(w.pending)
+	for i := range w.pending {
+		w.pending[i] *= float64(n-1-i) / float64(n)
+	}
+	err := w.flush(w.pending)
+	if c, ok := w.sink.(io.Closer); ok {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	w.sink = nil
+	w.pending = w.pending[:0]
+	return err
+}
+
+// flush writes samples to the sink.
+func (w *sliceWriter) flush(samples []float64) error {
+	if err := writeSamples(w.sink, w.out, samples); err != nil {
+		return err
+	}
+	w.written += uint(len(samples))
+	return nil
+}
@@ -0,0 +1,130 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package aubio_test
+
+import (
+	"math"
+	"testing"
+
+	"go.marzhillstudios.com/pkg/play/aubio"
+	"go.marzhillstudios.com/pkg/play/aubio/synth"
+)
+
+// memorySinks returns a Create function for a CutConfig recording
+// each slice in sinks, by path.
+func memorySinks(sinks map[string]*aubio.MemorySink) func(string, uint) (aubio.AudioSink, error) {
+	return func(path string, samplerate uint) (aubio.AudioSink, error) {
+		s := aubio.NewMemorySink(samplerate)
+		sinks[path] = s
+		return s, nil
+	}
+}
+
+func TestWriteSlicesAtOnsets(t *testing.T) {
+	const sr = 1000
+	// Impulses at 100, 600, 1100 and 1600 over a quiet tone, read in
+	// blocks that don't line up with them.
+	onsets := synth.BeatTimes(120, 0.1, 2)
+	signal := synth.Mix(synth.Impulses(onsets, 1, sr), synth.Sine(50, 0.1, sr))
+	data := synth.Render(signal, 2000)
+	cuts := []uint{0}
+	for _, t := range onsets {
+		cuts = append(cuts, synth.Samples(t, sr))
+	}
+	sinks := map[string]*aubio.MemorySink{}
+	config := aubio.CutConfig{HopSize: 64, Template: "{base}_{index}_{frame}_{time}.wav", Create: memorySinks(sinks)}
+	slices, err := aubio.WriteSlices(aubio.NewMemorySource(data, sr, 64), cuts, config, func(s aubio.Slice) string {
+		return config.SliceName("loop", s, sr)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []aubio.Slice{
+		{0, 0, 100, "loop_0_0_0.000000.wav"},
+		{1, 100, 600, "loop_1_100_0.100000.wav"},
+		{2, 600, 1100, "loop_2_600_0.600000.wav"},
+		{3, 1100, 1600, "loop_3_1100_1.100000.wav"},
+		{4, 1600, 2000, "loop_4_1600_1.600000.wav"},
+	}
+	if len(slices) != len(want) {
+		t.Fatalf("WriteSlices = %v, want %v", slices, want)
+	}
+	for i, s := range slices {
+		if s != want[i] {
+			t.Errorf("Slice %d = %+v, want %+v", i, s, want[i])
+			continue
+		}
+		got := sinks[s.Path].Data()
+		if uint(len(got)) != s.End-s.Start {
+			t.Errorf("%s has %d samples, want %d", s.Path, len(got), s.End-s.Start)
+			continue
+		}
+		// Every slice but the first starts on its impulse.
+		for j, v := range got {
+			if math.Abs(v-data[s.Start+uint(j)]) > 1e-6 {
+				t.Errorf("%s sample %d = %g, want %g", s.Path, j, v, data[s.Start+uint(j)])
+				break
+			}
+			if onset := v > 0.5; onset != (j == 0 && i > 0) {
+				t.Errorf("%s sample %d = %g, an impulse in the wrong place", s.Path, j, v)
+			}
+		}
+	}
+}
+
+func TestWriteSlicesFades(t *testing.T) {
+	const sr = 1000
+	data := synth.Render(func(uint) float64 { return 1 }, 100)
+	sinks := map[string]*aubio.MemorySink{}
+	config := aubio.CutConfig{HopSize: 16, FadeIn: 0.004, FadeOut: 0.004, Create: memorySinks(sinks)}
+	slices, err := aubio.WriteSlices(aubio.NewMemorySource(data, sr, 16), []uint{0, 30, 70}, config, func(s aubio.Slice) string {
+		return config.SliceName("fade", s, sr)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(slices) != 3 {
+		t.Fatalf("Wrote %d slices, want 3", len(slices))
+	}
+	for _, s := range slices {
+		got := sinks[s.Path].Data()
+		n := len(got)
+		if uint(n) != s.End-s.Start {
+			t.Fatalf("%s has %d samples, want %d", s.Path, n, s.End-s.Start)
+		}
+		// Linear fades over 4 samples, from 0 and down to 0.
+		for i, v := range got {
+			want := 1.0
+			if i < 4 {
+				want = float64(i) / 4
+			}
+			if i >= n-4 {
+				want = math.Min(want, float64(n-1-i)/4)
+			}
+			if math.Abs(v-want) > 1e-6 {
+				t.Errorf("%s sample %d = %g, want %g", s.Path, i, v, want)
+			}
+		}
+	}
+}
+
+func TestSliceName(t *testing.T) {
+	s := aubio.Slice{Index: 3, Start: 22050}
+	var config aubio.CutConfig
+	if got := config.SliceName("drums", s, 44100); got != "drums_0.500000.wav" {
+		t.Errorf("SliceName = %q, want %q", got, "drums_0.500000.wav")
+	}
+	config.Template = "{base}/{index}-{frame}.wav"
+	if got := config.SliceName("drums", s, 44100); got != "drums/3-22050.wav" {
+		t.Errorf("SliceName = %q, want %q", got, "drums/3-22050.wav")
+	}
+}
@@ -0,0 +1,219 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package eval
+
+import (
+	"math"
+	"sort"
+)
+
+// Parameters of the beat measures, as used by mir_eval.
+const (
+	// BeatWindow is the tolerance in seconds of the beat F-measure.
+	BeatWindow = 0.07
+	// CemgilSigma is the standard deviation in seconds of the
+	// Gaussian error function of the Cemgil accuracy.
+	CemgilSigma = 0.04
+	// PScoreThreshold is the tolerance of the P-score as a
+	// fraction of the median reference beat interval.
+	PScoreThreshold = 0.2
+	// ContinuityPhaseThreshold and ContinuityPeriodThreshold are the
+	// tolerances of the continuity measures as fractions of the
+	// reference beat interval.
+	ContinuityPhaseThreshold  = 0.175
+	ContinuityPeriodThreshold = 0.175
+)
+
+// BeatScores holds the beat tracking measures.
+type BeatScores struct {
+	// FMeasure matches beats within BeatWindow seconds.
+	FMeasure float64
+	// Cemgil scores the timing error of each beat with a Gaussian.
+	Cemgil float64
+	// PScore is the normalised cross correlation of the beat trains.
+	PScore float64
+	// CMLc and CMLt are the longest continuously correct segment and
+	// the total proportion of correct beats at the correct metrical
+	// level.
+	CMLc float64
+	CMLt float64
+	// AMLc and AMLt are as CMLc and CMLt but also accept beats at
+	// double or half the tempo and on the off beat.
+	AMLc float64
+	AMLt float64
+}
+
+// Beats scores estimated beats against reference beats. mir_eval
+// ignores beats in the first 5 seconds by default; trim them before
+// calling Beats to compare scores with it.
+func Beats(reference, estimated []float64) BeatScores {
+	reference, estimated = sorted(reference), sorted(estimated)
+	s := BeatScores{
+		FMeasure: fMeasure(reference, estimated, BeatWindow).FMeasure,
+		Cemgil:   cemgil(reference, estimated),
+		PScore:   pScore(reference, estimated),
+	}
+	s.CMLc, s.CMLt, s.AMLc, s.AMLt = continuity(reference, estimated)
+	return s
+}
+
+// cemgil returns the Cemgil accuracy of sorted beats.
+func cemgil(reference, estimated []float64) float64 {
+	if len(reference) == 0 || len(estimated) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, r := range reference {
+		d := math.Abs(estimated[nearest(estimated, r)] - r)
+		sum += math.Exp(-d * d / (2 * CemgilSigma * CemgilSigma))
+	}
+	return sum / (float64(len(reference)+len(estimated)) / 2)
+}
+
+// pScore returns the P-score of sorted beats. Beats are quantised to
+// 10ms, as by mir_eval.
+func pScore(reference, estimated []float64) float64 {
+	if len(reference) < 2 || len(estimated) == 0 {
+		return 0
+	}
+	const rate = 100
+	intervals := make([]float64, len(reference)-1)
+	for i := range intervals {
+		intervals[i] = reference[i+1] - reference[i]
+	}
+	sort.Float64s(intervals)
+	median := intervals[len(intervals)/2]
+	if len(intervals)%2 == 0 {
+		median = (median + intervals[len(intervals)/2-1]) / 2
+	}
+	window := int(math.Floor(PScoreThreshold*median*rate + 0.5))
+	offset := math.Min(reference[0], estimated[0])
+	quantise := func(t float64) int {
+		return int(math.Floor((t-offset)*rate + 0.5))
+	}
+	pairs := 0
+	for _, e := range estimated {
+		qe := quantise(e)
+		for _, r := range reference {
+			if d := qe - quantise(r); d >= -window && d <= window {
+				pairs++
+			}
+		}
+	}
+	n := len(reference)
+	if len(estimated) > n {
+		n = len(estimated)
+	}
+	return float64(pairs) / float64(n)
+}
+
+// nearest returns the index of the time in sorted times closest to t.
+func nearest(times []float64, t float64) int {
+	i := sort.SearchFloat64s(times, t)
+	if i == len(times) || (i > 0 && t-times[i-1] <= times[i]-t) {
+		return i - 1
+	}
+	return i
+}
+
+// beatVariations returns the reference beats at the correct metrical
+// level followed by the off beats, double tempo, and half tempo on the
+// odd and even beats.
+func beatVariations(reference []float64) [][]float64 {
+	double := make([]float64, 0, 2*len(reference))
+	for i, r := range reference {
+		double = append(double, r)
+		if i+1 < len(reference) {
+			double = append(double, (r+reference[i+1])/2)
+		}
+	}
+	var off, odd, even []float64
+	for i := 1; i < len(double); i += 2 {
+		off = append(off, double[i])
+	}
+	for i, r := range reference {
+		if i%2 == 0 {
+			odd = append(odd, r)
+		} else {
+			even = append(even, r)
+		}
+	}
+	return [][]float64{reference, off, double, odd, even}
+}
+
+// continuity returns the continuity based measures of sorted beats.
+func continuity(reference, estimated []float64) (cmlc, cmlt, amlc, amlt float64) {
+	if len(reference) < 2 || len(estimated) < 2 {
+		return 0, 0, 0, 0
+	}
+	for v, ref := range beatVariations(reference) {
+		c, t := continuityScores(ref, estimated)
+		if v == 0 {
+			cmlc, cmlt = c, t
+		}
+		amlc, amlt = math.Max(amlc, c), math.Max(amlt, t)
+	}
+	return cmlc, cmlt, amlc, amlt
+}
+
+// continuityScores returns the longest continuously correct segment
+// and the total proportion of estimated beats that are correct for
+// one variation of the reference beats.
+func continuityScores(reference, estimated []float64) (longest, total float64) {
+	if len(reference) < 2 {
+		return 0, 0
+	}
+	n := len(reference)
+	if len(estimated) > n {
+		n = len(estimated)
+	}
+	used := make([]bool, len(reference))
+	run, best, correct := 0, 0, 0
+	for m, e := range estimated {
+		k := nearest(reference, e)
+		ok := false
+		if !used[k] {
+			var refInterval, estInterval float64
+			if m == 0 || k == 0 {
+				// At the start, look forward.
+				if k+1 < len(reference) {
+					refInterval = reference[k+1] - reference[k]
+				} else {
+					refInterval = reference[k] - reference[k-1]
+				}
+				if m+1 < len(estimated) {
+					estInterval = estimated[m+1] - e
+				} else {
+					estInterval = e - estimated[m-1]
+				}
+			} else {
+				refInterval = reference[k] - reference[k-1]
+				estInterval = e - estimated[m-1]
+			}
+			if refInterval > 0 {
+				phase := math.Abs((e - reference[k]) / refInterval)
+				period := math.Abs(1 - estInterval/refInterval)
+				ok = phase < ContinuityPhaseThreshold && period < ContinuityPeriodThreshold
+			}
+		}
+		if ok {
+			used[k] = true
+			correct++
+			run++
+			if run > best {
+				best = run
+			}
+		} else {
+			run = 0
+		}
+	}
+	return float64(best) / float64(n), float64(correct) / float64(n)
+}
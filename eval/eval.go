@@ -0,0 +1,114 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+// Package eval scores onset, beat and pitch detection against reference
+// annotations using the measures common in MIR evaluation (MIREX,
+// mir_eval).
+//
+// Times are in seconds and need not be sorted. References can be read
+// from label files with the results package:
+//
+//	ref, err := results.ReadAudacityLabels(f, results.Onset)
+//	if err != nil {
+//		// handle error
+//	}
+//	scores := eval.Onsets(eval.Times(ref), detected, 0)
+//	fmt.Printf("F=%.3f\n", scores.FMeasure)
+package eval
+
+import (
+	"math"
+	"sort"
+
+	"go.marzhillstudios.com/pkg/play/aubio/results"
+)
+
+// DefaultOnsetWindow is the tolerance in seconds used by Onsets when
+// none is given.
+const DefaultOnsetWindow = 0.05
+
+// Scores holds the precision, recall and F-measure of a set of events.
+type Scores struct {
+	Precision float64
+	Recall    float64
+	FMeasure  float64
+	// Matched is the number of estimated events matched to a
+	// reference event.
+	Matched int
+}
+
+// Times returns the times of recs.
+func Times(recs []results.Record) []float64 {
+	times := make([]float64, len(recs))
+	for i, r := range recs {
+		times[i] = r.Time
+	}
+	return times
+}
+
+// sorted returns a sorted copy of times.
+func sorted(times []float64) []float64 {
+	s := append([]float64(nil), times...)
+	sort.Float64s(s)
+	return s
+}
+
+// match returns the largest number of one to one pairs of reference
+// and estimated events that are at most window seconds apart. Both
+// must be sorted.
+func match(reference, estimated []float64, window float64) int {
+	n := 0
+	for i, j := 0, 0; i < len(reference) && j < len(estimated); {
+		switch d := estimated[j] - reference[i]; {
+		case d < -window:
+			j++
+		case d > window:
+			i++
+		default:
+			n++
+			i++
+			j++
+		}
+	}
+	return n
+}
+
+// fMeasure scores estimated against reference, matching events at most
+// window seconds apart. It scores 0 if either is empty.
+func fMeasure(reference, estimated []float64, window float64) Scores {
+	if len(reference) == 0 || len(estimated) == 0 {
+		return Scores{}
+	}
+	n := match(sorted(reference), sorted(estimated), window)
+	s := Scores{
+		Precision: float64(n) / float64(len(estimated)),
+		Recall:    float64(n) / float64(len(reference)),
+		Matched:   n,
+	}
+	if n > 0 {
+		s.FMeasure = 2 * s.Precision * s.Recall / (s.Precision + s.Recall)
+	}
+	return s
+}
+
+// Onsets scores estimated onsets against reference onsets. An onset is
+// correct if it is within window seconds of a reference onset not
+// already matched. A window of 0 uses DefaultOnsetWindow.
+func Onsets(reference, estimated []float64, window float64) Scores {
+	if window == 0 {
+		window = DefaultOnsetWindow
+	}
+	return fMeasure(reference, estimated, window)
+}
+
+// cents returns the interval from a to b in cents.
+func cents(a, b float64) float64 {
+	return 1200 * math.Log2(b/a)
+}
@@ -0,0 +1,187 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package eval
+
+import (
+	"math"
+	"testing"
+
+	"go.marzhillstudios.com/pkg/play/aubio"
+)
+
+// near reports whether got is within 1e-9 of want.
+func near(got, want float64) bool {
+	return math.Abs(got-want) < 1e-9
+}
+
+func TestOnsets(t *testing.T) {
+	// 1.02 and 3.0 match. 2.06 is outside the window and 3.01 is
+	// close to 3 but that reference onset is already taken.
+	ref := []float64{4, 2, 3, 1}
+	est := []float64{5, 3.01, 1.02, 3, 2.06}
+	s := Onsets(ref, est, 0)
+	want := Scores{Precision: 2.0 / 5, Recall: 2.0 / 4, FMeasure: 4.0 / 9, Matched: 2}
+	if s.Matched != want.Matched || !near(s.Precision, want.Precision) ||
+		!near(s.Recall, want.Recall) || !near(s.FMeasure, want.FMeasure) {
+		t.Errorf("Onsets = %+v, want %+v", s, want)
+	}
+}
+
+func TestOnsetsWindowBoundary(t *testing.T) {
+	// The differences are exact in binary, so the window is tested
+	// at its edge.
+	ref := []float64{1, 2}
+	est := []float64{1.0625, 1.9375}
+	if s := Onsets(ref, est, 0.0625); s.Matched != 2 || s.FMeasure != 1 {
+		t.Errorf("Onsets at the edge of the window = %+v, want both matched", s)
+	}
+	if s := Onsets(ref, est, 0.0624); s.Matched != 0 || s.FMeasure != 0 {
+		t.Errorf("Onsets outside the window = %+v, want none matched", s)
+	}
+}
+
+func TestOnsetsEmpty(t *testing.T) {
+	onsets := []float64{1, 2}
+	for _, s := range []Scores{
+		Onsets(nil, onsets, 0),
+		Onsets(onsets, nil, 0),
+		Onsets(nil, nil, 0),
+	} {
+		if s != (Scores{}) {
+			t.Errorf("Onsets = %+v, want zero scores", s)
+		}
+	}
+}
+
+// beatGrid returns n beats every 0.5s from start.
+func beatGrid(start float64, n int) []float64 {
+	beats := make([]float64, n)
+	for i := range beats {
+		beats[i] = start + 0.5*float64(i)
+	}
+	return beats
+}
+
+func TestBeatsPerfect(t *testing.T) {
+	s := Beats(beatGrid(1, 10), beatGrid(1, 10))
+	want := BeatScores{1, 1, 1, 1, 1, 1, 1}
+	if s != want {
+		t.Errorf("Beats = %+v, want %+v", s, want)
+	}
+}
+
+func TestBeatsOffBeat(t *testing.T) {
+	// Beats a quarter of a second late are all on the off beat. Only
+	// the allowed metrical levels count them.
+	s := Beats(beatGrid(1, 5), beatGrid(1.25, 4))
+	if s.FMeasure != 0 || s.PScore != 0 || s.CMLc != 0 || s.CMLt != 0 {
+		t.Errorf("Beats = %+v, want F-measure, P-score and CML of 0", s)
+	}
+	if s.AMLc != 1 || s.AMLt != 1 {
+		t.Errorf("AMLc, AMLt = %g, %g, want 1, 1", s.AMLc, s.AMLt)
+	}
+	// exp(-0.25²/(2*0.04²)) is about 3e-9.
+	if s.Cemgil > 1e-8 {
+		t.Errorf("Cemgil = %g, want about 0", s.Cemgil)
+	}
+}
+
+func TestBeatsCemgil(t *testing.T) {
+	// One beat 40ms late, one standard deviation of the error
+	// function, and inside the F-measure window.
+	s := Beats([]float64{1, 2}, []float64{1, 2.04})
+	if want := (1 + math.Exp(-0.5)) / 2; !near(s.Cemgil, want) {
+		t.Errorf("Cemgil = %g, want %g", s.Cemgil, want)
+	}
+	if s.FMeasure != 1 {
+		t.Errorf("FMeasure = %g, want 1", s.FMeasure)
+	}
+}
+
+func TestBeatsEmpty(t *testing.T) {
+	beats := beatGrid(1, 4)
+	for _, s := range []BeatScores{
+		Beats(nil, beats),
+		Beats(beats, nil),
+		Beats(beats[:1], beats[:1]),
+	} {
+		if s.PScore != 0 || s.CMLt != 0 || s.AMLt != 0 {
+			t.Errorf("Beats = %+v, want P-score and continuity of 0", s)
+		}
+	}
+	if s := Beats(nil, beats); s != (BeatScores{}) {
+		t.Errorf("Beats with no reference = %+v, want zero scores", s)
+	}
+}
+
+func TestPitch(t *testing.T) {
+	ref := []aubio.PitchFrame{
+		{Time: 0.00, Pitch: 440},
+		{Time: 0.01, Pitch: 440},
+		{Time: 0.02, Pitch: 0},
+		{Time: 0.03, Pitch: 220},
+		{Time: 0.04, Pitch: 440},
+		{Time: 0.05, Pitch: 0},
+	}
+	// Out of order, and a little off the reference times.
+	est := []aubio.PitchFrame{
+		{Time: 0.051, Pitch: 0},
+		{Time: 0.041, Pitch: 0},
+		{Time: 0.031, Pitch: -220},
+		{Time: 0.021, Pitch: 300},
+		{Time: 0.011, Pitch: 880},
+		{Time: 0.001, Pitch: 440},
+	}
+	s := Pitch(ref, est, 0)
+	// Voiced reference frames are 0, 1, 3 and 4. Frames 0 and 1 are
+	// estimated voiced, 0 and 3 have the right pitch, and 0, 1 and 3
+	// the right chroma. Frame 2 is a false alarm. Frames 0 and 5 are
+	// right overall.
+	want := PitchScores{
+		VoicingRecall:     2.0 / 4,
+		VoicingFalseAlarm: 1.0 / 2,
+		RawPitchAccuracy:  2.0 / 4,
+		RawChromaAccuracy: 3.0 / 4,
+		OverallAccuracy:   2.0 / 6,
+	}
+	if s != want {
+		t.Errorf("Pitch = %+v, want %+v", s, want)
+	}
+}
+
+func TestPitchTolerance(t *testing.T) {
+	ref := []aubio.PitchFrame{{Time: 0, Pitch: 440}}
+	inside := []aubio.PitchFrame{{Time: 0, Pitch: 440 * math.Pow(2, 49.9/1200)}}
+	outside := []aubio.PitchFrame{{Time: 0, Pitch: 440 * math.Pow(2, -50.1/1200)}}
+	if s := Pitch(ref, inside, 0); s.RawPitchAccuracy != 1 {
+		t.Errorf("RawPitchAccuracy at 49.9 cents = %g, want 1", s.RawPitchAccuracy)
+	}
+	if s := Pitch(ref, outside, 0); s.RawPitchAccuracy != 0 || s.RawChromaAccuracy != 0 {
+		t.Errorf("Accuracies at -50.1 cents = %+v, want 0", s)
+	}
+	if s := Pitch(ref, outside, 51); s.RawPitchAccuracy != 1 {
+		t.Errorf("RawPitchAccuracy at -50.1 cents with 51 cents tolerance = %g, want 1", s.RawPitchAccuracy)
+	}
+}
+
+func TestPitchEmpty(t *testing.T) {
+	frames := []aubio.PitchFrame{{Time: 0, Pitch: 440}}
+	if s := Pitch(nil, frames, 0); s != (PitchScores{}) {
+		t.Errorf("Pitch with no reference = %+v, want zero scores", s)
+	}
+	if s := Pitch(frames, nil, 0); s != (PitchScores{}) {
+		t.Errorf("Pitch with no estimate = %+v, want zero scores", s)
+	}
+	// Without unvoiced reference frames there can be no false alarm.
+	if s := Pitch(frames, frames, 0); s.VoicingFalseAlarm != 0 || s.OverallAccuracy != 1 {
+		t.Errorf("Pitch = %+v, want no false alarms and full accuracy", s)
+	}
+}
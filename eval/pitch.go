@@ -0,0 +1,109 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package eval
+
+import (
+	"math"
+	"sort"
+
+	"go.marzhillstudios.com/pkg/play/aubio"
+)
+
+// DefaultPitchTolerance is the tolerance in cents used by Pitch when
+// none is given.
+const DefaultPitchTolerance = 50
+
+// PitchScores holds the melody extraction measures.
+type PitchScores struct {
+	// VoicingRecall is the proportion of voiced reference frames
+	// estimated as voiced.
+	VoicingRecall float64
+	// VoicingFalseAlarm is the proportion of unvoiced reference
+	// frames estimated as voiced.
+	VoicingFalseAlarm float64
+	// RawPitchAccuracy is the proportion of voiced reference frames
+	// whose estimated pitch is within the tolerance.
+	RawPitchAccuracy float64
+	// RawChromaAccuracy is as RawPitchAccuracy but ignores octave
+	// errors.
+	RawChromaAccuracy float64
+	// OverallAccuracy is the proportion of all frames that are
+	// correctly unvoiced or voiced with a correct pitch.
+	OverallAccuracy float64
+}
+
+// Pitch scores estimated pitch frames against reference frames. Both
+// hold frequencies in Hz, as detected with PitchOutFreq. Frames with a
+// pitch of 0 are unvoiced. Estimated frames with a negative pitch are
+// unvoiced but their absolute value still counts towards the pitch
+// accuracies, as in mir_eval. Each reference frame is compared with the
+// estimated frame closest in time. A tolerance of 0 uses
+// DefaultPitchTolerance cents.
+func Pitch(reference, estimated []aubio.PitchFrame, tolerance float64) PitchScores {
+	var s PitchScores
+	if len(reference) == 0 || len(estimated) == 0 {
+		return s
+	}
+	if tolerance == 0 {
+		tolerance = DefaultPitchTolerance
+	}
+	estimated = append([]aubio.PitchFrame(nil), estimated...)
+	sort.Slice(estimated, func(i, j int) bool { return estimated[i].Time < estimated[j].Time })
+	times := make([]float64, len(estimated))
+	for i, f := range estimated {
+		times[i] = f.Time
+	}
+
+	var voiced, unvoiced, recalled, falseAlarms, rawPitch, rawChroma, overall int
+	for _, r := range reference {
+		e := estimated[nearest(times, r.Time)].Pitch
+		estVoiced := e > 0
+		if r.Pitch <= 0 {
+			unvoiced++
+			if estVoiced {
+				falseAlarms++
+			} else {
+				overall++
+			}
+			continue
+		}
+		voiced++
+		if estVoiced {
+			recalled++
+		}
+		if e == 0 {
+			continue
+		}
+		d := cents(r.Pitch, math.Abs(e))
+		if math.Abs(d) <= tolerance {
+			rawPitch++
+			if estVoiced {
+				overall++
+			}
+		}
+		// Fold the error into a single octave.
+		if d = math.Abs(d - 1200*math.Floor(d/1200+0.5)); d <= tolerance {
+			rawChroma++
+		}
+	}
+	ratio := func(a, b int) float64 {
+		if b == 0 {
+			return 0
+		}
+		return float64(a) / float64(b)
+	}
+	s.VoicingRecall = ratio(recalled, voiced)
+	s.VoicingFalseAlarm = ratio(falseAlarms, unvoiced)
+	s.RawPitchAccuracy = ratio(rawPitch, voiced)
+	s.RawChromaAccuracy = ratio(rawChroma, voiced)
+	s.OverallAccuracy = ratio(overall, len(reference))
+	return s
+}
@@ -0,0 +1,600 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package aubio_test
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"go.marzhillstudios.com/pkg/play/aubio"
+	"go.marzhillstudios.com/pkg/play/aubio/synth"
+)
+
+// The golden tests run every wrapper on deterministic synthetic
+// signals and compare the results with the files in testdata, so that
+// regressions in the wrapper or changes in behaviour between libaubio
+// versions show up. Record the golden files with a libaubio known to
+// be good, then check against them after changing either:
+//
+//	go test -run Golden -update
+//	go test -run Golden
+//
+// A case whose golden file is missing fails until it is recorded.
+var update = flag.Bool("update", false, "Write the golden files instead of comparing with them")
+
+// goldenTolerance is the largest relative difference allowed between
+// values.
+const goldenTolerance = 1e-4
+
+// The analysis settings shared by every case.
+const (
+	samplerate = 16000
+	bufSize    = 1024
+	hopSize    = 256
+)
+
+// result holds the named series of values produced by a case.
+type result map[string][]float64
+
+// add appends values to the series called name.
+func (r result) add(name string, values ...float64) {
+	r[name] = append(r[name], values...)
+}
+
+// golden runs c, checks that it frees everything it allocates, and
+// writes or compares the golden file called name.
+func golden(t *testing.T, name string, c func() (result, error)) {
+	t.Helper()
+	before := map[aubio.Allocation]bool{}
+	for _, l := range aubio.Leaks() {
+		before[l] = true
+	}
+	aubio.DetectLeaks(true)
+	got, err := c()
+	aubio.DetectLeaks(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range aubio.Leaks() {
+		if !before[l] {
+			t.Errorf("%s was never freed. Allocated at:\n%s", l.Type, l.Stack)
+		}
+	}
+
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.MkdirAll("testdata", 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := writeGolden(path, got); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := readGolden(path)
+	if os.IsNotExist(err) {
+		t.Fatalf("No golden file %s. Record it with -update against a known good libaubio", path)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := compare(want, got); err != nil {
+		t.Error(err)
+	}
+}
+
+// compare returns an error describing the first difference between
+// want and got.
+func compare(want, got result) error {
+	for _, name := range names(want) {
+		g, ok := got[name]
+		if !ok {
+			return fmt.Errorf("Missing %s", name)
+		}
+		w := want[name]
+		if len(g) != len(w) {
+			return fmt.Errorf("%s has %d values, want %d", name, len(g), len(w))
+		}
+		for i := range w {
+			if !near(w[i], g[i]) {
+				return fmt.Errorf("%s[%d] = %g, want %g", name, i, g[i], w[i])
+			}
+		}
+	}
+	for _, name := range names(got) {
+		if _, ok := want[name]; !ok {
+			return fmt.Errorf("Unexpected %s", name)
+		}
+	}
+	return nil
+}
+
+// near reports whether a and b differ by at most the tolerance,
+// relative to the larger of them or 1.
+func near(a, b float64) bool {
+	if math.IsNaN(a) || math.IsNaN(b) {
+		return math.IsNaN(a) && math.IsNaN(b)
+	}
+	if math.IsInf(a, 0) || math.IsInf(b, 0) {
+		return a == b
+	}
+	scale := math.Max(1, math.Max(math.Abs(a), math.Abs(b)))
+	return math.Abs(a-b) <= goldenTolerance*scale
+}
+
+func names(r result) []string {
+	var ns []string
+	for name := range r {
+		ns = append(ns, name)
+	}
+	sort.Strings(ns)
+	return ns
+}
+
+// writeGolden writes r to path, one series per line: its name
+// followed by its values. Unlike JSON the format can hold NaN and
+// infinities.
+func writeGolden(path string, r result) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, name := range names(r) {
+		w.WriteString(name)
+		for _, v := range r[name] {
+			w.WriteByte(' ')
+			w.WriteString(strconv.FormatFloat(v, 'g', -1, 64))
+		}
+		w.WriteByte('\n')
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readGolden reads a file written by writeGolden.
+func readGolden(path string) (result, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := result{}
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 1<<24)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		values := make([]float64, len(fields)-1)
+		for i, s := range fields[1:] {
+			if values[i], err = strconv.ParseFloat(s, 64); err != nil {
+				return nil, fmt.Errorf("Bad value in %s: %s", path, err)
+			}
+		}
+		r[fields[0]] = values
+	}
+	return r, sc.Err()
+}
+
+// The test signals. Each has a known answer: the melody's notes, the
+// beats of the pulse, the frequencies of the chirp.
+var (
+	melody   = synth.Notes(synth.Melody([]float64{57, 60, 64, 69, 72, 69, 64, 60}, 0.2, 0.05, 100), samplerate)
+	pulse    = synth.Notes(synth.Melody(repeat(76, 16), 0.1, 0.4, 100), samplerate)
+	chirp    = synth.Chirp(100, 4000, 1, 0.5, samplerate)
+	noisy    = synth.Mix(melody, synth.PinkNoise(0.01, 1))
+	sawtooth = synth.Saw(220, 0.5, samplerate)
+)
+
+func repeat(v float64, n int) []float64 {
+	vs := make([]float64, n)
+	for i := range vs {
+		vs[i] = v
+	}
+	return vs
+}
+
+// frames runs f on every hopSize block of the first length samples
+// of s.
+func frames(s synth.Signal, length uint, f func(in *aubio.SimpleBuffer)) error {
+	p := aubio.NewSimplePipeline(synth.Source(s, length, samplerate, hopSize), nil, hopSize)
+	defer p.Close()
+	p.DoAll(f)
+	return p.Err()
+}
+
+func TestGoldenBuffers(t *testing.T) { golden(t, "buffers", buffers) }
+
+func buffers() (result, error) {
+	r := result{}
+	ramp := []float64{1, 2, 3, 4, 5, 6}
+
+	b := aubio.NewSimpleBufferData(8, ramp)
+	defer b.Free()
+	r.add("simple_size", float64(b.Size()))
+	r.add("simple_data", b.Slice()...)
+	b.SetData(ramp[:3])
+	r.add("simple_set", b.Slice()...)
+	b.Zero()
+	r.add("simple_zero", b.Slice()...)
+
+	cb := aubio.NewComplexBufferData(8, ramp)
+	defer cb.Free()
+	r.add("complex_size", float64(cb.Size()))
+	r.add("complex_data", cb.Norm()...)
+	cb.SetNorm([]float64{0.5, 0.25})
+	cb.SetPhase([]float64{-1, 1, 2})
+	r.add("complex_norm", cb.Norm()...)
+	r.add("complex_phase", cb.Phase()...)
+	cb.Zero()
+	r.add("complex_zero", append(cb.Norm(), cb.Phase()...)...)
+
+	lb := aubio.NewLBuffer(4)
+	defer lb.Free()
+	r.add("long_size", float64(lb.Size()))
+	r.add("long_data", lb.Slice()...)
+	return r, nil
+}
+
+func TestGoldenPool(t *testing.T) { golden(t, "pool", pool) }
+
+func pool() (result, error) {
+	r := result{}
+	p := aubio.NewBufferPool(2)
+	defer p.Free()
+	b := p.GetSimple(16)
+	b.SetData([]float64{1, 2})
+	p.PutSimple(b)
+	// A recycled buffer comes back zeroed.
+	b = p.GetSimple(16)
+	r.add("simple", b.Slice()...)
+	p.PutSimple(b)
+	cb := p.GetComplex(16)
+	r.add("complex", float64(cb.Size()))
+	p.PutComplex(cb)
+	lb := p.GetLong(4)
+	r.add("long", lb.Slice()...)
+	p.PutLong(lb)
+	return r, nil
+}
+
+func TestGoldenFilter(t *testing.T) { golden(t, "filter", filter) }
+
+func filter() (result, error) {
+	r := result{}
+	f, err := aubio.NewFilter(3, hopSize)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Free()
+	f.SetSamplerate(samplerate)
+	r.add("order", float64(f.Order()))
+	r.add("samplerate", float64(f.Samplerate()))
+	r.add("feedback", f.Feedback().Slice()...)
+	r.add("feedforward", f.Feedforward().Slice()...)
+
+	in := aubio.NewSimpleBufferData(hopSize, synth.Render(sawtooth, hopSize))
+	defer in.Free()
+	f.DoOutplace(in)
+	r.add("outplace", f.Buffer().Slice()...)
+	f.Reset()
+	f.Do(in)
+	r.add("inplace", in.Slice()...)
+	f.Reset()
+	f.DoFwdBack(in, hopSize)
+	r.add("fwdback", in.Slice()...)
+	return r, nil
+}
+
+// spectra runs a PhaseVoc over the first length samples of s and
+// calls f with the grain of every block.
+func spectra(s synth.Signal, length uint, f func(grain *aubio.ComplexBuffer)) error {
+	pv, err := aubio.NewPhaseVoc(bufSize, hopSize)
+	if err != nil {
+		return err
+	}
+	defer pv.Free()
+	return frames(s, length, func(in *aubio.SimpleBuffer) {
+		pv.Do(in)
+		f(pv.Grain())
+	})
+}
+
+func TestGoldenFilterBank(t *testing.T) { golden(t, "filterbank", filterbank) }
+
+func filterbank() (result, error) {
+	r := result{}
+	fb := aubio.NewFilterBank(40, bufSize)
+	defer fb.Free()
+	fb.SetMelCoeffsSlaney(samplerate)
+	err := spectra(chirp, samplerate, func(grain *aubio.ComplexBuffer) {
+		fb.Do(grain)
+		r.add("energies", fb.Buffer().Slice()...)
+	})
+	return r, err
+}
+
+func TestGoldenMFCC(t *testing.T) { golden(t, "mfcc", mfcc) }
+
+func mfcc() (result, error) {
+	r := result{}
+	m, err := aubio.NewMFCC(bufSize, 40, 13, samplerate)
+	if err != nil {
+		return nil, err
+	}
+	defer m.Free()
+	err = spectra(chirp, samplerate, func(grain *aubio.ComplexBuffer) {
+		m.Do(grain)
+		r.add("coeffs", m.Buffer().Slice()...)
+	})
+	return r, err
+}
+
+func TestGoldenPhaseVoc(t *testing.T) { golden(t, "pvoc", pvoc) }
+
+func pvoc() (result, error) {
+	r := result{}
+	pv, err := aubio.NewPhaseVoc(bufSize, hopSize)
+	if err != nil {
+		return nil, err
+	}
+	defer pv.Free()
+	out := aubio.NewSimpleBuffer(hopSize)
+	defer out.Free()
+	n := 0
+	err = frames(chirp, samplerate/2, func(in *aubio.SimpleBuffer) {
+		pv.Do(in)
+		if n%8 == 7 {
+			r.add("norm", pv.Grain().Norm()...)
+			r.add("phase", pv.Grain().Phase()...)
+		}
+		pv.ReverseDo(out)
+		r.add("resynth", out.Slice()...)
+		n++
+	})
+	return r, err
+}
+
+func TestGoldenPitch(t *testing.T) {
+	units := []string{aubio.PitchOutFreq, aubio.PitchOutMidi, aubio.PitchOutCent, aubio.PitchOutBin, aubio.PitchOutDefault}
+	for _, mode := range []string{"default", "yin", "yinfft", "mcomb", "schmitt", "fcomb"} {
+		for _, unit := range units {
+			name := mode + "_" + unit
+			t.Run(name, func(t *testing.T) { golden(t, "pitch_"+name, pitch(mode, unit)) })
+		}
+	}
+}
+
+func pitch(mode, unit string) func() (result, error) {
+	return func() (result, error) {
+		m, err := aubio.ParsePitchMode(mode)
+		if err != nil {
+			return nil, err
+		}
+		u, err := aubio.ParsePitchUnit(unit)
+		if err != nil {
+			return nil, err
+		}
+		r := result{}
+		p := aubio.NewPitch(m, bufSize, hopSize, samplerate)
+		defer p.Free()
+		p.SetUnit(u)
+		err = frames(melody, 2*samplerate, func(in *aubio.SimpleBuffer) {
+			p.Do(in)
+			r.add("pitch", p.Buffer().Slice()[0])
+			r.add("confidence", p.GetConfidence())
+		})
+		return r, err
+	}
+}
+
+func TestGoldenOnset(t *testing.T) {
+	for _, m := range aubio.OnsetModes() {
+		mode := string(m)
+		t.Run(mode, func(t *testing.T) { golden(t, "onset_"+mode, onset(mode)) })
+	}
+}
+
+func onset(mode string) func() (result, error) {
+	return func() (result, error) {
+		m, err := aubio.ParseOnsetMode(mode)
+		if err != nil {
+			return nil, err
+		}
+		r := result{"onsets": nil}
+		o, err := aubio.NewOnset(m, bufSize, hopSize, samplerate)
+		if err != nil {
+			return nil, err
+		}
+		defer o.Free()
+		err = frames(noisy, 2*samplerate, func(in *aubio.SimpleBuffer) {
+			o.Do(in)
+			if o.Buffer().Slice()[0] != 0 {
+				r.add("onsets", o.GetLastOnset())
+			}
+		})
+		return r, err
+	}
+}
+
+func TestGoldenTempo(t *testing.T) { golden(t, "tempo", tempo) }
+
+func tempo() (result, error) {
+	r := result{"beats": nil}
+	t, err := aubio.NewTempo(aubio.SpecDiff, bufSize, hopSize, samplerate)
+	if err != nil {
+		return nil, err
+	}
+	defer t.Free()
+	err = frames(pulse, 8*samplerate, func(in *aubio.SimpleBuffer) {
+		t.Do(in)
+		if t.Buffer().Slice()[0] != 0 {
+			r.add("beats", t.GetLastBeat())
+		}
+	})
+	r.add("bpm", t.GetBpm())
+	r.add("confidence", t.GetConfidence())
+	return r, err
+}
+
+// readAll reads src to the end and returns its samples.
+func readAll(src aubio.AudioSource) ([]float64, error) {
+	sink := aubio.NewMemorySink(src.Samplerate())
+	p := aubio.NewSimplePipeline(src, sink, hopSize)
+	defer p.Close()
+	p.DoAll()
+	return sink.Data(), p.Err()
+}
+
+// roundTrip writes the first length samples of sawtooth with create,
+// reads them back with open and records what was read.
+func roundTrip(create func(path string) (aubio.AudioSink, error), open func(path string) (aubio.AudioSource, error)) (result, error) {
+	tmp, err := ioutil.TempDir("", "golden")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	path := filepath.Join(tmp, "saw.wav")
+
+	sink, err := create(path)
+	if err != nil {
+		return nil, err
+	}
+	p := aubio.NewSimplePipeline(synth.Source(sawtooth, 3000, samplerate, hopSize), sink, hopSize)
+	p.DoAll()
+	if err := p.Err(); err != nil {
+		p.Close()
+		return nil, err
+	}
+	if err := p.Close(); err != nil {
+		return nil, err
+	}
+
+	src, err := open(path)
+	if err != nil {
+		return nil, err
+	}
+	r := result{}
+	r.add("samplerate", float64(src.Samplerate()))
+	r.add("channels", float64(src.Channels()))
+	data, err := readAll(src)
+	r.add("samples", data...)
+	return r, err
+}
+
+func TestGoldenSinkSource(t *testing.T) { golden(t, "sink_source", sinkSource) }
+
+func sinkSource() (result, error) {
+	return roundTrip(func(path string) (aubio.AudioSink, error) {
+		s, err := aubio.OpenSink(path, samplerate)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	}, func(path string) (aubio.AudioSource, error) {
+		s, err := aubio.OpenSource(path, 0, hopSize)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	})
+}
+
+func TestGoldenWav(t *testing.T) { golden(t, "wav", wav) }
+
+func wav() (result, error) {
+	return roundTrip(func(path string) (aubio.AudioSink, error) {
+		s, err := aubio.OpenWavSink(path, samplerate)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	}, func(path string) (aubio.AudioSource, error) {
+		s, err := aubio.OpenWavSource(path, 0, hopSize)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	})
+}
+
+func TestGoldenPCM(t *testing.T) {
+	for _, format := range []string{"u8", "s16le", "s24le", "s32le", "f32le", "f64le"} {
+		t.Run(format, func(t *testing.T) { golden(t, "pcm_"+format, pcm(format)) })
+	}
+}
+
+func pcm(format string) func() (result, error) {
+	return func() (result, error) {
+		f, err := aubio.ParsePCMFormat(format)
+		if err != nil {
+			return nil, err
+		}
+		var data bytes.Buffer
+		sink, err := aubio.NewWriterSink(&data, f, 2, samplerate)
+		if err != nil {
+			return nil, err
+		}
+		p := aubio.NewSimplePipeline(synth.Source(sawtooth, 1000, samplerate, hopSize), sink, hopSize)
+		p.DoAll()
+		p.Close()
+		if err := p.Err(); err != nil {
+			return nil, err
+		}
+		r := result{}
+		r.add("bytes", float64(data.Len()))
+		src, err := aubio.NewReaderSource(&data, f, 2, samplerate, hopSize)
+		if err != nil {
+			return nil, err
+		}
+		samples, err := readAll(src)
+		r.add("samples", samples...)
+		return r, err
+	}
+}
+
+func TestGoldenPipeline(t *testing.T) { golden(t, "pipeline", pipeline) }
+
+func pipeline() (result, error) {
+	r := result{}
+	src := synth.MemorySource(sawtooth, 1000, samplerate, 100)
+	sink := aubio.NewMemorySink(samplerate)
+	p := aubio.NewSimplePipeline(src, sink, hopSize)
+	defer p.Close()
+	r.add("blocksize", float64(p.BlockSize()))
+	r.add("bufsize", float64(p.BufSize()))
+	calls := 0
+	count := func(*aubio.SimpleBuffer) { calls++ }
+	r.add("do", float64(p.Do(count)))
+	r.add("don", float64(p.DoN(3, count)))
+	r.add("doall", float64(p.DoAll(count)))
+	r.add("calls", float64(calls))
+	r.add("samples", sink.Data()...)
+	if err := p.Err(); err != nil {
+		return nil, fmt.Errorf("Pipeline failed: %s", err)
+	}
+	return r, nil
+}
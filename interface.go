@@ -20,3 +20,40 @@ type ReverseComplexAnalyzer interface {
 }
 
 type ProcessFunc func(input *SimpleBuffer)
+
+// AudioSource is a stream of mono audio that can be read into a
+// SimpleBuffer one block at a time.
+//
+// Read fills buf with the next block of samples and returns the
+// number of samples read. When the stream is exhausted Read returns
+// io.EOF, possibly along with a final short block. Any other error
+// means the stream could not be read.
+type AudioSource interface {
+	Read(buf *SimpleBuffer) (uint, error)
+	// Samplerate returns the samplerate of the stream.
+	Samplerate() uint
+	// Channels returns the number of channels in the underlying
+	// stream before it was mixed down to mono.
+	Channels() uint
+}
+
+// MultiChannelSource is an AudioSource that can also read each
+// channel separately instead of mixed down to mono.
+//
+// ReadChannels fills bufs, which must hold one buffer per channel,
+// with the next block of each channel and returns the number of
+// samples read per channel. It follows the same rules as Read.
+type MultiChannelSource interface {
+	AudioSource
+	ReadChannels(bufs []*SimpleBuffer) (uint, error)
+}
+
+// AudioSink consumes mono audio one block at a time.
+//
+// Write writes the first n samples of buf to the sink and returns
+// the number of samples written.
+type AudioSink interface {
+	Write(buf *SimpleBuffer, n uint) (uint, error)
+	// Samplerate returns the samplerate the sink expects.
+	Samplerate() uint
+}
@@ -10,6 +10,10 @@
 
 // Package aubio is a Go binding to the aubio audio analysis library
 // http://aubio.org/.
+//
+// The types in this package are not safe for concurrent use. Use
+// SyncPitch, SyncOnset, SyncTempo or SyncPhaseVoc to share an
+// analyzer between goroutines.
 package aubio
 
 /*
@@ -19,12 +23,20 @@ package aubio
 import "C"
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"runtime"
 	"syscall"
 )
 
+var (
+	errClosedSource = errors.New("Read on closed Source")
+	errClosedSink   = errors.New("Write on closed Sink")
+	errNilSink      = errors.New("Write on nil sink")
+)
+
 func newSink(uri string, sr uint) (*C.aubio_sink_t, error) {
 	sink, err := C.new_aubio_sink(
 		toCharTPtr(uri), C.uint_t(sr))
@@ -41,6 +53,8 @@ func newSource(uri string, sr, hopSize uint) (*C.aubio_source_t, error) {
 type Source struct {
 	blockSize uint
 	s         *C.aubio_source_t
+	// frames holds a block of every channel for ReadChannels.
+	frames *C.fmat_t
 }
 
 // OpenSource opens an aubio_source_t from the uri.
@@ -58,10 +72,12 @@ func OpenSource(uri string, samplerate, hopSize uint) (*Source, error) {
 		return nil, fmt.Errorf("Failed to open source uri %q %s errno: %d", uri, err,
 			int(err.(syscall.Errno)))
 	}
-	return &Source{
+	s := &Source{
 		blockSize: hopSize,
 		s:         src,
-	}, nil
+	}
+	manage(s, (*Source).finalize)
+	return s, nil
 }
 
 // BlockSize returns the blockSize used by this Source.
@@ -77,6 +93,14 @@ func (s *Source) Samplerate() (n uint) {
 	return
 }
 
+// Channels returns the number of channels in the Source.
+func (s *Source) Channels() (n uint) {
+	s.ifOpen(func() {
+		n = uint(C.aubio_source_get_channels(s.s))
+	})
+	return
+}
+
 func (s *Source) ifOpen(f func()) {
 	if s.s != nil {
 		f()
@@ -94,13 +118,75 @@ func (s *Source) Do(buf *SimpleBuffer) uint {
 	s.ifOpen(func() {
 		C.aubio_source_do(s.s, buf.vec, &n)
 	})
+	runtime.KeepAlive(s)
+	runtime.KeepAlive(buf)
 	return uint(n)
 }
 
+// Read reads the next block from the source into buf.
+// It returns io.EOF once a short block has been read.
+func (s *Source) Read(buf *SimpleBuffer) (uint, error) {
+	if s.s == nil {
+		return 0, errClosedSource
+	}
+	n := s.Do(buf)
+	if n < s.blockSize {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// ReadChannels reads the next block of every channel into bufs, one
+// buffer per channel, instead of mixing them down as Read does. It
+// returns io.EOF once a short block has been read.
+func (s *Source) ReadChannels(bufs []*SimpleBuffer) (uint, error) {
+	if s.s == nil {
+		return 0, errClosedSource
+	}
+	channels := uint(C.aubio_source_get_channels(s.s))
+	if uint(len(bufs)) != channels {
+		return 0, fmt.Errorf("Got %d buffers for %d channels", len(bufs), channels)
+	}
+	if s.frames == nil {
+		s.frames = C.new_fmat(C.uint_t(channels), C.uint_t(s.blockSize))
+	}
+	var n C.uint_t
+	C.aubio_source_do_multi(s.s, s.frames, &n)
+	for c, buf := range bufs {
+		for i := uint(0); i < buf.Size(); i++ {
+			var v C.smpl_t
+			if i < uint(n) && i < s.blockSize {
+				v = C.fmat_get_sample(s.frames, C.uint_t(c), C.uint_t(i))
+			}
+			C.fvec_set_sample(buf.vec, v, C.uint_t(i))
+		}
+		runtime.KeepAlive(buf)
+	}
+	runtime.KeepAlive(s)
+	if uint(n) < s.blockSize {
+		return uint(n), io.EOF
+	}
+	return uint(n), nil
+}
+
 // Close closes the aubio_source_t and frees the memory.
-func (s *Source) Close() {
-	s.ifOpen(func() { C.del_aubio_source(s.s) })
-	s.s = nil
+// It is safe to call Close more than once.
+func (s *Source) Close() error {
+	if s.s != nil {
+		unmanage(s)
+		C.del_aubio_source(s.s)
+		s.s = nil
+	}
+	if s.frames != nil {
+		C.del_fmat(s.frames)
+		s.frames = nil
+	}
+	return nil
+}
+
+func (s *Source) finalize() {
+	leaked(s)
+	s.Close()
 }
 
 // Sink is a wrapper for an aubio_sink_t object.
@@ -123,10 +209,12 @@ func OpenSink(uri string, samplerate uint) (*Sink, error) {
 		return nil, fmt.Errorf("Failed to open source uri %q %s errno: %d", uri, err,
 			int(err.(syscall.Errno)))
 	}
-	return &Sink{
+	s := &Sink{
 		samplerate: samplerate,
 		s:          sink,
-	}, nil
+	}
+	manage(s, (*Sink).finalize)
+	return s, nil
 }
 
 func (s *Sink) ifOpen(f func()) {
@@ -145,31 +233,56 @@ func (s *Sink) Samplerate() uint {
 }
 
 // Close closes the aubio_sink_t and frees the memory.
-func (s *Sink) Close() {
-	s.ifOpen(func() { C.del_aubio_sink(s.s) })
-	s.s = nil
+// It is safe to call Close more than once.
+func (s *Sink) Close() error {
+	if s.s != nil {
+		unmanage(s)
+		C.del_aubio_sink(s.s)
+		s.s = nil
+	}
+	return nil
+}
+
+func (s *Sink) finalize() {
+	leaked(s)
+	s.Close()
 }
 
 // Do writes to the sink from the buffer.
 // It returns the amount of data written.
 func (s *Sink) Do(buf *SimpleBuffer, n uint) uint {
 	s.ifOpen(func() { C.aubio_sink_do(s.s, buf.vec, C.uint_t(n)) })
+	runtime.KeepAlive(s)
+	runtime.KeepAlive(buf)
 	return n
 }
 
-// Pipeline pipes data from a Source to a Sink.
+// Write writes n samples from buf to the sink.
+func (s *Sink) Write(buf *SimpleBuffer, n uint) (uint, error) {
+	if s == nil {
+		return 0, errNilSink
+	}
+	if s.s == nil {
+		return 0, errClosedSink
+	}
+	return s.Do(buf, n), nil
+}
+
+// Pipeline pipes data from an AudioSource to an AudioSink.
 type SimplePipeline struct {
-	source *Source
-	sink   *Sink
+	source AudioSource
+	sink   AudioSink
 	buf    *SimpleBuffer
+	err    error
 }
 
-// NewPipeline constructs a Pipeline between a Source and an optional Sink
+// NewPipeline constructs a Pipeline between an AudioSource and an optional AudioSink
 // using a Buffer of bufSize.  It will run any ProcessFuncs passed to it as it
 // pulls from the source.
 //
 // The Pipeline assumes ownership of the source and sink so calling Close on
-// the Pipeline will close the source as well as the sink.
+// the Pipeline will close the source as well as the sink. Pass a nil
+// AudioSink, not a nil *Sink, when there is no sink.
 //
 //     pitch := NewPitch(...)
 //     fn := func(in, out *Buffer) {
@@ -181,9 +294,9 @@ type SimplePipeline struct {
 //                      bufSize, fn)
 //     defer p.Close()
 //     p.DoAll() // pipe all the data in source to the sink
-func NewSimplePipeline(in *Source, out *Sink, bufSize uint) *SimplePipeline {
+func NewSimplePipeline(in AudioSource, out AudioSink, bufSize uint) *SimplePipeline {
 	return &SimplePipeline{
-		buf:    NewSimpleBuffer(bufSize),
+		buf:    newBuffer(bufSize),
 		source: in,
 		sink:   out,
 	}
@@ -209,20 +322,37 @@ func PipelineFromUris(inUri, outUri string, samplerate, blockSize, bufSize uint)
 }
 
 // Close closes the the Source, Sink, and frees the Buffer.
-func (p *SimplePipeline) Close() {
-	p.source.Close()
+// The source and sink are only closed if they implement io.Closer.
+// It returns the first error returned by either of them.
+func (p *SimplePipeline) Close() error {
+	var err error
+	if c, ok := p.source.(io.Closer); ok {
+		err = c.Close()
+	}
 	p.source = nil
-	if p.sink != nil {
-		p.sink.Close()
-		p.sink = nil
+	if c, ok := p.sink.(io.Closer); ok {
+		if serr := c.Close(); err == nil {
+			err = serr
+		}
 	}
-	p.buf.Free()
-	p.buf = nil
+	p.sink = nil
+	if p.buf != nil {
+		freeBuffer(p.buf)
+		p.buf = nil
+	}
+	return err
 }
 
 // BlockSize returns the BlockSize used by this Pipeline.
+// Sources without a BlockSize method fill the whole buffer
+// so BufSize is returned for them.
 func (p *SimplePipeline) BlockSize() uint {
-	return p.source.BlockSize()
+	if b, ok := p.source.(interface {
+		BlockSize() uint
+	}); ok {
+		return b.BlockSize()
+	}
+	return p.BufSize()
 }
 
 // BufSize returns the current buffer size the Pipeline is using.
@@ -230,36 +360,48 @@ func (p *SimplePipeline) BufSize() uint {
 	return uint(p.buf.vec.length)
 }
 
-func (p *SimplePipeline) do(fs []ProcessFunc) (amt uint) {
-	n := p.source.Do(p.buf)
-	for _, f := range fs {
-		f(p.buf)
+// Err returns the first error other than io.EOF that was
+// encountered reading from the source or writing to the sink.
+func (p *SimplePipeline) Err() error {
+	return p.err
+}
+
+// do pipes one block through the pipeline. more is false once
+// the source has been exhausted or either end has failed.
+func (p *SimplePipeline) do(fs []ProcessFunc) (amt uint, more bool) {
+	n, err := p.source.Read(p.buf)
+	if n > 0 {
+		for _, f := range fs {
+			f(p.buf)
+		}
+		if p.sink != nil {
+			var werr error
+			if n, werr = p.sink.Write(p.buf, n); werr != nil {
+				err = werr
+			}
+		}
 	}
-	if p.sink != nil {
-		return p.sink.Do(p.buf, n)
+	if err != nil && err != io.EOF && p.err == nil {
+		p.err = err
 	}
-	return n
+	return n, err == nil && n > 0
 }
 
 // Do pipes up to BufSize data from the source to a sink if there is
 // one.
 // It returns the number of frames processed.
 func (p *SimplePipeline) Do(fs ...ProcessFunc) uint {
-	out := NewSimpleBuffer(p.BufSize())
-	defer out.Free()
-	return p.do(fs)
+	n, _ := p.do(fs)
+	return n
 }
 
 // DoN runs Do up to n times.
 // It returns the number of frames processed.
 func (p *SimplePipeline) DoN(n int, fs ...ProcessFunc) (total uint) {
-	total = uint(0)
-	read := p.BlockSize()
-	for i := 0; i < n && read != p.BlockSize(); i++ {
-		// TODO(jwall): Is it safe to share the buffer?
-		out := NewSimpleBuffer(p.BufSize())
-		defer out.Free()
-		read = p.do(fs)
+	more := true
+	for i := 0; i < n && more; i++ {
+		var read uint
+		read, more = p.do(fs)
 		total += read
 	}
 	return
@@ -268,12 +410,9 @@ func (p *SimplePipeline) DoN(n int, fs ...ProcessFunc) (total uint) {
 // DoAll runs Do until the source has been exhausted.
 // It returns the number of frames processed.
 func (p *SimplePipeline) DoAll(fs ...ProcessFunc) (total uint) {
-	read := p.BlockSize()
-	for read == p.BlockSize() {
-		// TODO(jwall): Is it safe to share the buffer?
-		out := NewSimpleBuffer(p.BufSize())
-		defer out.Free()
-		read = p.do(fs)
+	for more := true; more; {
+		var read uint
+		read, more = p.do(fs)
 		total += read
 	}
 	return
@@ -0,0 +1,69 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package aubio
+
+import (
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func TestSourceReadChannels(t *testing.T) {
+	// The left channel is a ramp up and the right one a ramp down.
+	const frames = 300
+	var data []int16
+	for i := 0; i < frames; i++ {
+		data = append(data, int16(i*100), int16(-i*100))
+	}
+	path := filepath.Join(t.TempDir(), "stereo.wav")
+	if err := ioutil.WriteFile(path, wavFile(wavFormatPCM, 2, 8000, 16, false, nil, le(data)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	src, err := OpenSource(path, 0, 256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Close()
+	if src.Channels() != 2 {
+		t.Skipf("libaubio read %d channels from a stereo WAV file", src.Channels())
+	}
+	if _, err := src.ReadChannels([]*SimpleBuffer{NewSimpleBuffer(256)}); err == nil {
+		t.Errorf("ReadChannels with one buffer for two channels succeeded")
+	}
+
+	bufs := []*SimpleBuffer{NewSimpleBuffer(256), NewSimpleBuffer(256)}
+	defer bufs[0].Free()
+	defer bufs[1].Free()
+	var left, right []float64
+	for {
+		n, err := src.ReadChannels(bufs)
+		left = append(left, bufs[0].Slice()[:n]...)
+		right = append(right, bufs[1].Slice()[:n]...)
+		if err != nil {
+			break
+		}
+	}
+	if len(left) != frames {
+		t.Fatalf("Read %d frames, want %d", len(left), frames)
+	}
+	for i := range left {
+		want := float64(i*100) / 32768
+		if math.Abs(left[i]-want) > 1e-6 || math.Abs(right[i]+want) > 1e-6 {
+			t.Fatalf("Frame %d = %g, %g, want %g, %g", i, left[i], right[i], want, -want)
+		}
+	}
+
+	src.Close()
+	if _, err := src.ReadChannels(bufs); err == nil {
+		t.Errorf("ReadChannels after Close succeeded")
+	}
+}
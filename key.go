@@ -0,0 +1,235 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package aubio
+
+import (
+	"fmt"
+	"math"
+)
+
+type keyMode string
+
+const (
+	// Modes of a Key.
+	Major keyMode = "major"
+	Minor keyMode = "minor"
+)
+
+type keyProfile string
+
+const (
+	// Key profiles rating how well each pitch class fits a key.
+	// Listener ratings from Krumhansl and Kessler, 1982
+	Krumhansl keyProfile = "krumhansl"
+	// Ratings from Temperley, "What's key for key?", 1999
+	Temperley keyProfile = "temperley"
+)
+
+// keyProfiles holds the major and minor profile of each keyProfile
+// for a tonic of C.
+var keyProfiles = map[keyProfile][2][PitchClasses]float64{
+	Krumhansl: {
+		{6.35, 2.23, 3.48, 2.33, 4.38, 4.09, 2.52, 5.19, 2.39, 3.66, 2.29, 2.88},
+		{6.33, 2.68, 3.52, 5.38, 2.60, 3.53, 2.54, 4.75, 3.98, 2.69, 3.34, 3.17},
+	},
+	Temperley: {
+		{5.0, 2.0, 3.5, 2.0, 4.5, 4.0, 2.0, 4.5, 2.0, 3.5, 1.5, 4.0},
+		{5.0, 2.0, 3.5, 4.5, 2.0, 4.0, 2.0, 4.5, 3.5, 2.0, 1.5, 4.0},
+	},
+}
+
+// ParseKeyProfile returns the key profile called name.
+func ParseKeyProfile(name string) (keyProfile, error) {
+	if _, ok := keyProfiles[keyProfile(name)]; ok {
+		return keyProfile(name), nil
+	}
+	return "", fmt.Errorf("Unknown key profile %q", name)
+}
+
+// Key is a musical key.
+type Key struct {
+	// Tonic is the pitch class of the key's tonic, 0 being C.
+	Tonic int
+	Mode  keyMode
+	// Confidence is the correlation, between -1 and 1, of the chroma
+	// the key was estimated from with the key's profile.
+	Confidence float64
+}
+
+// String returns the name of the key, e.g. "F# minor".
+func (k Key) String() string {
+	if k.Mode == "" {
+		return "unknown"
+	}
+	return PitchClassName(k.Tonic) + " " + string(k.Mode)
+}
+
+// EstimateKey returns the key whose profile correlates best with
+// chroma, a vector of PitchClasses energies starting at C. A chroma
+// of zeros has no key and gives the zero Key.
+func EstimateKey(chroma []float64, profile keyProfile) Key {
+	profiles, ok := keyProfiles[profile]
+	if !ok || len(chroma) != PitchClasses {
+		return Key{}
+	}
+	var best Key
+	found := false
+	for m, mode := range []keyMode{Major, Minor} {
+		for tonic := 0; tonic < PitchClasses; tonic++ {
+			var rotated [PitchClasses]float64
+			for pc := range rotated {
+				rotated[pc] = profiles[m][(pc-tonic+PitchClasses)%PitchClasses]
+			}
+			r := correlation(chroma, rotated[:])
+			if math.IsNaN(r) {
+				return Key{}
+			}
+			if !found || r > best.Confidence {
+				best = Key{Tonic: tonic, Mode: mode, Confidence: r}
+				found = true
+			}
+		}
+	}
+	return best
+}
+
+// correlation returns the Pearson correlation of a and b, which must
+// have the same length. It is NaN if either is constant.
+func correlation(a, b []float64) float64 {
+	var ma, mb float64
+	for i := range a {
+		ma += a[i]
+		mb += b[i]
+	}
+	ma /= float64(len(a))
+	mb /= float64(len(b))
+	var cov, va, vb float64
+	for i := range a {
+		da, db := a[i]-ma, b[i]-mb
+		cov += da * db
+		va += da * da
+		vb += db * db
+	}
+	return cov / math.Sqrt(va*vb)
+}
+
+// KeyConfig configures DetectKey.
+type KeyConfig struct {
+	// Chroma configures the chroma the key is estimated from.
+	Chroma ChromaConfig
+	// Profile is the key profile to match. Defaults to Krumhansl.
+	Profile keyProfile
+	// Window is the length in seconds of the windows to estimate
+	// the key of as well as the whole file. 0 estimates the whole
+	// file only.
+	Window float64
+	// Step is the time in seconds between the starts of windows.
+	// Defaults to half the Window.
+	Step float64
+}
+
+// KeySegment is the key of part of a file.
+type KeySegment struct {
+	// Start and End of the segment in seconds.
+	Start float64
+	End   float64
+	Key   Key
+}
+
+// KeyResult is the key of a whole file and of each of its windows.
+type KeyResult struct {
+	Key Key
+	// Duration of the file in seconds.
+	Duration float64
+	Segments []KeySegment
+}
+
+// DetectKey reads src to the end and estimates its key, over the
+// whole of src and over sliding windows if config has a Window. src
+// is closed when done.
+//
+//	r, err := DetectKey(src, KeyConfig{Window: 30})
+//	if err != nil {
+//		// handle error
+//	}
+//	fmt.Println(r.Key)
+//	for _, s := range r.Segments {
+//		fmt.Println(s.Start, s.End, s.Key)
+//	}
+func DetectKey(src AudioSource, config KeyConfig) (*KeyResult, error) {
+	config.Chroma.defaults()
+	if config.Profile == "" {
+		config.Profile = Krumhansl
+	}
+	if config.Step <= 0 {
+		config.Step = config.Window / 2
+	}
+	sr := src.Samplerate()
+	c, err := NewChroma(config.Chroma, sr)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Free()
+	hop := config.Chroma.HopSize
+	p := NewSimplePipeline(src, nil, hop)
+	defer p.Close()
+	var frames [][]float64
+	total := p.DoAll(func(in *SimpleBuffer) {
+		c.Do(in)
+		frames = append(frames, c.Buffer().Slice())
+	})
+	if err := p.Err(); err != nil {
+		return nil, err
+	}
+
+	r := &KeyResult{
+		Key:      EstimateKey(sumFrames(frames), config.Profile),
+		Duration: float64(total) / float64(sr),
+	}
+	if config.Window <= 0 || len(frames) == 0 {
+		return r, nil
+	}
+	toFrames := func(t float64) int {
+		n := int(math.Floor(t*float64(sr)/float64(hop) + 0.5))
+		if n < 1 {
+			n = 1
+		}
+		return n
+	}
+	window, step := toFrames(config.Window), toFrames(config.Step)
+	for start := 0; start < len(frames); start += step {
+		end := start + window
+		if end > len(frames) {
+			end = len(frames)
+		}
+		seg := KeySegment{
+			Start: float64(start*int(hop)) / float64(sr),
+			End:   math.Min(float64(end*int(hop))/float64(sr), r.Duration),
+			Key:   EstimateKey(sumFrames(frames[start:end]), config.Profile),
+		}
+		r.Segments = append(r.Segments, seg)
+		if end == len(frames) {
+			break
+		}
+	}
+	return r, nil
+}
+
+// sumFrames returns the sum of chroma vectors.
+func sumFrames(frames [][]float64) []float64 {
+	sum := make([]float64, PitchClasses)
+	for _, f := range frames {
+		for i, v := range f {
+			sum[i] += v
+		}
+	}
+	return sum
+}
@@ -0,0 +1,492 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package aubio
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+const (
+	// Gates and windows of ITU-R BS.1770-4 and EBU R128.
+	// Blocks quieter than this many LUFS never count
+	LoudnessAbsoluteGate = -70
+	// Integrated loudness ignores blocks this many LU below the
+	// loudness of the blocks above the absolute gate
+	IntegratedRelativeGate = -10
+	// Loudness range ignores short-term values this many LU below
+	// the loudness of the values above the absolute gate
+	RangeRelativeGate = -20
+)
+
+// The loudness measurements are made from 100ms blocks: momentary
+// loudness covers 4 of them and short-term loudness 30.
+const (
+	momentaryBlocks = 4
+	shortTermBlocks = 30
+)
+
+// Loudness is an AudioSink that measures the loudness of what is
+// written to it as specified by ITU-R BS.1770-4 and EBU R128: the
+// channels are K-weighted, their power is summed and gated into an
+// integrated loudness in LUFS, a loudness range in LU and series of
+// momentary and short-term loudness, and the true peak is estimated
+// by oversampling.
+//
+// Closing a Loudness frees its filters. Its measurements remain
+// available.
+type Loudness struct {
+	samplerate uint
+	channels   []*loudnessChannel
+	// blockSize is the number of samples in 100ms.
+	blockSize uint
+	// energy and filled accumulate the block being measured.
+	energy float64
+	filled uint
+	// blocks holds the mean weighted power of each complete block.
+	blocks   []float64
+	peak     float64
+	truePeak float64
+	filtered [][]float64
+}
+
+// loudnessChannel holds the K-weighting filters of a channel.
+type loudnessChannel struct {
+	weight  float64
+	pre     *Filter
+	rlb     *Filter
+	scratch *SimpleBuffer
+	tp      *truePeakMeter
+}
+
+// NewLoudness constructs a Loudness measuring audio with the given
+// number of channels at samplerate. Every channel has a weight of 1,
+// except for 6 channels which are taken to be 5.1 in the order L, R,
+// C, LFE, Ls, Rs and weighted as BS.1770 specifies, leaving out the
+// LFE. It is the Callers responsibility to call Close on the
+// returned Loudness or leak memory.
+//
+//	l, err := NewLoudness(1, samplerate)
+//	if err != nil {
+//		// handle error
+//	}
+//	p := NewSimplePipeline(src, l, hopSize)
+//	p.DoAll()
+//	p.Close()
+//	fmt.Println(l.Integrated(), "LUFS")
+func NewLoudness(channels, samplerate uint) (*Loudness, error) {
+	if channels == 0 || samplerate == 0 {
+		return nil, fmt.Errorf("Invalid loudness meter of %d channels at %dHz", channels, samplerate)
+	}
+	l := &Loudness{
+		samplerate: samplerate,
+		blockSize:  uint(math.Floor(float64(samplerate)/10 + 0.5)),
+		filtered:   make([][]float64, channels),
+	}
+	surround := []float64{1, 1, 1, 0, 1.41, 1.41}
+	for c := uint(0); c < channels; c++ {
+		ch := &loudnessChannel{weight: 1, tp: newTruePeakMeter(samplerate)}
+		if channels == uint(len(surround)) {
+			ch.weight = surround[c]
+		}
+		l.channels = append(l.channels, ch)
+		var err error
+		if ch.pre, err = kWeightingShelf(samplerate); err != nil {
+			l.Free()
+			return nil, err
+		}
+		if ch.rlb, err = kWeightingHighPass(samplerate); err != nil {
+			l.Free()
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// kWeightingShelf returns the first stage of the K-weighting filter,
+// a high shelf modelling the acoustic effect of the head. The
+// coefficients are derived for any samplerate from the analog
+// prototype of the 48kHz ones given in BS.1770.
+func kWeightingShelf(samplerate uint) (*Filter, error) {
+	const (
+		f0   = 1681.974450955533
+		gain = 3.999843853973347
+		q    = 0.7071752369554196
+	)
+	k := math.Tan(math.Pi * f0 / float64(samplerate))
+	vh := math.Pow(10, gain/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + k/q + k*k
+	return newBiquad(
+		(vh+vb*k/q+k*k)/a0,
+		2*(k*k-vh)/a0,
+		(vh-vb*k/q+k*k)/a0,
+		2*(k*k-1)/a0,
+		(1-k/q+k*k)/a0)
+}
+
+// kWeightingHighPass returns the second stage of the K-weighting
+// filter, the RLB high pass.
+func kWeightingHighPass(samplerate uint) (*Filter, error) {
+	const (
+		f0 = 38.13547087602444
+		q  = 0.5003270373238773
+	)
+	k := math.Tan(math.Pi * f0 / float64(samplerate))
+	a0 := 1 + k/q + k*k
+	return newBiquad(1, -2, 1, 2*(k*k-1)/a0, (1-k/q+k*k)/a0)
+}
+
+func newBiquad(b0, b1, b2, a1, a2 float64) (*Filter, error) {
+	f, err := NewFilter(3, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err := f.SetBiquad(b0, b1, b2, a1, a2); err != nil {
+		f.Free()
+		return nil, err
+	}
+	return f, nil
+}
+
+// SetChannelWeight sets the weight of channel c's power in the
+// sum over channels.
+func (l *Loudness) SetChannelWeight(c uint, weight float64) {
+	l.channels[c].weight = weight
+}
+
+// Samplerate returns the samplerate the Loudness measures.
+func (l *Loudness) Samplerate() uint {
+	return l.samplerate
+}
+
+// Channels returns the number of channels the Loudness measures.
+func (l *Loudness) Channels() uint {
+	return uint(len(l.channels))
+}
+
+// Write measures the first n samples of buf. It can only be used to
+// measure a single channel: on a Loudness of more than one channel it
+// returns an error and measures nothing. Use WriteChannels instead.
+func (l *Loudness) Write(buf *SimpleBuffer, n uint) (uint, error) {
+	return l.WriteChannels([]*SimpleBuffer{buf}, n)
+}
+
+// WriteChannels measures the first n samples of each channel, one
+// buffer per channel.
+func (l *Loudness) WriteChannels(bufs []*SimpleBuffer, n uint) (uint, error) {
+	if len(bufs) != len(l.channels) {
+		return 0, fmt.Errorf("Got %d buffers for %d channels", len(bufs), len(l.channels))
+	}
+	for _, buf := range bufs {
+		n = writeLen(buf, n)
+	}
+	for c, ch := range l.channels {
+		if ch.pre == nil {
+			return 0, fmt.Errorf("Write called on closed Loudness")
+		}
+		samples := bufs[c].Slice()[:n]
+		for _, v := range samples {
+			l.peak = math.Max(l.peak, math.Abs(v))
+			l.truePeak = math.Max(l.truePeak, ch.tp.add(v))
+		}
+		// Filter a copy of exactly n samples so the filter state
+		// never sees padding.
+		if ch.scratch == nil || ch.scratch.Size() != n {
+			if ch.scratch != nil {
+				freeBuffer(ch.scratch)
+			}
+			ch.scratch = newBuffer(n)
+		}
+		ch.scratch.SetData(samples)
+		ch.pre.Do(ch.scratch)
+		ch.rlb.Do(ch.scratch)
+		l.filtered[c] = ch.scratch.Slice()
+	}
+	for i := uint(0); i < n; i++ {
+		for c, ch := range l.channels {
+			v := l.filtered[c][i]
+			l.energy += ch.weight * v * v
+		}
+		l.filled++
+		if l.filled == l.blockSize {
+			l.blocks = append(l.blocks, l.energy/float64(l.blockSize))
+			l.energy, l.filled = 0, 0
+		}
+	}
+	return n, nil
+}
+
+// lufs converts a mean weighted power into LUFS.
+func lufs(power float64) float64 {
+	if power <= 0 {
+		return math.Inf(-1)
+	}
+	return -0.691 + 10*math.Log10(power)
+}
+
+// window returns the mean power of the size blocks ending with block
+// i, taking blocks before the start to be silent.
+func (l *Loudness) window(i, size int) float64 {
+	sum := 0.0
+	for j := i - size + 1; j <= i; j++ {
+		if j >= 0 {
+			sum += l.blocks[j]
+		}
+	}
+	return sum / float64(size)
+}
+
+// series returns the loudness of the window of size blocks ending at
+// every block.
+func (l *Loudness) series(size int) []float64 {
+	s := make([]float64, len(l.blocks))
+	for i := range s {
+		s[i] = lufs(l.window(i, size))
+	}
+	return s
+}
+
+// Momentary returns the momentary loudness in LUFS every 100ms.
+// Value i is the loudness of the 400ms ending (i+1)*100ms into the
+// audio, taking the audio to be silent before it starts.
+func (l *Loudness) Momentary() []float64 {
+	return l.series(momentaryBlocks)
+}
+
+// ShortTerm returns the short-term loudness in LUFS every 100ms.
+// Value i is the loudness of the 3s ending (i+1)*100ms into the
+// audio, taking the audio to be silent before it starts.
+func (l *Loudness) ShortTerm() []float64 {
+	return l.series(shortTermBlocks)
+}
+
+// gated returns the powers of the complete windows of size blocks
+// that are above the absolute gate and no more than relative LU below
+// the loudness of those.
+func (l *Loudness) gated(size int, relative float64) []float64 {
+	var above []float64
+	sum := 0.0
+	for i := size - 1; i < len(l.blocks); i++ {
+		if p := l.window(i, size); lufs(p) > LoudnessAbsoluteGate {
+			above = append(above, p)
+			sum += p
+		}
+	}
+	if len(above) == 0 {
+		return nil
+	}
+	threshold := lufs(sum/float64(len(above))) + relative
+	var kept []float64
+	for _, p := range above {
+		if lufs(p) > threshold {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
+
+// Integrated returns the gated loudness of everything written so far
+// in LUFS, or -Inf if nothing was loud enough to measure.
+func (l *Loudness) Integrated() float64 {
+	kept := l.gated(momentaryBlocks, IntegratedRelativeGate)
+	if len(kept) == 0 {
+		return math.Inf(-1)
+	}
+	sum := 0.0
+	for _, p := range kept {
+		sum += p
+	}
+	return lufs(sum / float64(len(kept)))
+}
+
+// Range returns the loudness range in LU as specified by EBU Tech
+// 3342: the spread between the 10th and 95th percentiles of the gated
+// short-term loudness. It is 0 for less than 3s of audio.
+func (l *Loudness) Range() float64 {
+	kept := l.gated(shortTermBlocks, RangeRelativeGate)
+	if len(kept) == 0 {
+		return 0
+	}
+	sort.Float64s(kept)
+	percentile := func(p float64) float64 {
+		return lufs(kept[int(math.Floor(p*float64(len(kept)-1)+0.5))])
+	}
+	return percentile(0.95) - percentile(0.10)
+}
+
+// SamplePeak returns the largest absolute sample in dBFS, or -Inf if
+// every sample was 0.
+func (l *Loudness) SamplePeak() float64 {
+	return 20 * math.Log10(l.peak)
+}
+
+// TruePeak returns the estimated largest absolute value of the
+// signal between samples in dBTP, or -Inf if every sample was 0.
+func (l *Loudness) TruePeak() float64 {
+	return 20 * math.Log10(math.Max(l.peak, l.truePeak))
+}
+
+// Gain returns the gain in dB that brings the integrated loudness to
+// target LUFS, e.g. -23 for EBU R128 broadcast or -14 for streaming.
+func (l *Loudness) Gain(target float64) float64 {
+	return target - l.Integrated()
+}
+
+// Duration returns the length in seconds of the complete 100ms
+// blocks measured.
+func (l *Loudness) Duration() float64 {
+	return float64(len(l.blocks)) / 10
+}
+
+// Free frees the filters of the Loudness. The measurements remain
+// valid. It is safe to call Free more than once.
+func (l *Loudness) Free() {
+	for _, ch := range l.channels {
+		if ch.pre != nil {
+			ch.pre.Free()
+			ch.pre = nil
+		}
+		if ch.rlb != nil {
+			ch.rlb.Free()
+			ch.rlb = nil
+		}
+		if ch.scratch != nil {
+			freeBuffer(ch.scratch)
+			ch.scratch = nil
+		}
+	}
+}
+
+// Close frees the Loudness. It always returns nil.
+func (l *Loudness) Close() error {
+	l.Free()
+	return nil
+}
+
+// MeasureLoudness reads src to the end in blocks of hopSize samples
+// and returns its loudness. Every channel of a MultiChannelSource,
+// such as a Source or WavSource, is measured, other sources are
+// measured as mono. src is closed when done.
+//
+//	src, err := OpenWavSource(path, 0, 1024)
+//	if err != nil {
+//		// handle error
+//	}
+//	l, err := MeasureLoudness(src, 1024)
+//	if err != nil {
+//		// handle error
+//	}
+//	fmt.Printf("%.1f LUFS, %.1f LU, %.1f dBTP\n", l.Integrated(), l.Range(), l.TruePeak())
+func MeasureLoudness(src AudioSource, hopSize uint) (*Loudness, error) {
+	mc, multi := src.(MultiChannelSource)
+	if !multi || src.Channels() < 2 {
+		l, err := NewLoudness(1, src.Samplerate())
+		if err != nil {
+			if c, ok := src.(io.Closer); ok {
+				c.Close()
+			}
+			return nil, err
+		}
+		p := NewSimplePipeline(src, l, hopSize)
+		p.DoAll()
+		err = p.Err()
+		p.Close()
+		return l, err
+	}
+
+	if c, ok := src.(io.Closer); ok {
+		defer c.Close()
+	}
+	l, err := NewLoudness(src.Channels(), src.Samplerate())
+	if err != nil {
+		return nil, err
+	}
+	defer l.Free()
+	bufs := make([]*SimpleBuffer, src.Channels())
+	for i := range bufs {
+		bufs[i] = newBuffer(hopSize)
+		defer freeBuffer(bufs[i])
+	}
+	for {
+		n, rerr := mc.ReadChannels(bufs)
+		if n > 0 {
+			if _, err := l.WriteChannels(bufs, n); err != nil {
+				return l, err
+			}
+		}
+		if rerr == io.EOF || n == 0 {
+			break
+		}
+		if rerr != nil {
+			return l, rerr
+		}
+	}
+	return l, nil
+}
+
+// truePeakTaps is the number of samples each interpolated value is
+// computed from.
+const truePeakTaps = 12
+
+// truePeakMeter estimates the peak between samples by interpolating
+// several values between each pair of samples with a windowed sinc,
+// as BS.1770 Annex 2 suggests: 4 values below 96kHz, 2 below 192kHz
+// and none above.
+type truePeakMeter struct {
+	coefs   [][truePeakTaps]float64
+	history [truePeakTaps]float64
+	pos     int
+}
+
+func newTruePeakMeter(samplerate uint) *truePeakMeter {
+	factor := 1
+	switch {
+	case samplerate < 96000:
+		factor = 4
+	case samplerate < 192000:
+		factor = 2
+	}
+	m := &truePeakMeter{coefs: make([][truePeakTaps]float64, factor)}
+	half := float64(truePeakTaps) / 2
+	for p := range m.coefs {
+		for j := range m.coefs[p] {
+			// Tap j is the sample j-half+1 places from the one
+			// the values follow.
+			x := float64(p)/float64(factor) - (float64(j) - half + 1)
+			sinc := 1.0
+			if x != 0 {
+				sinc = math.Sin(math.Pi*x) / (math.Pi * x)
+			}
+			window := 0.5 + 0.5*math.Cos(math.Pi*x/half)
+			m.coefs[p][j] = sinc * window
+		}
+	}
+	return m
+}
+
+// add adds the next sample and returns the largest absolute value
+// interpolated after the sample half the taps before it.
+func (m *truePeakMeter) add(v float64) float64 {
+	m.history[m.pos] = v
+	m.pos = (m.pos + 1) % truePeakTaps
+	peak := 0.0
+	for p := 1; p < len(m.coefs); p++ {
+		sum := 0.0
+		for j, c := range m.coefs[p] {
+			sum += c * m.history[(m.pos+j)%truePeakTaps]
+		}
+		peak = math.Max(peak, math.Abs(sum))
+	}
+	return peak
+}
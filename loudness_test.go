@@ -0,0 +1,240 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package aubio
+
+import (
+	"math"
+	"testing"
+)
+
+// The EBU test signals are at 48kHz.
+const loudnessRate = 48000
+
+// level is a stretch of a test signal at a level in dBFS.
+type level struct {
+	seconds float64
+	dbfs    float64
+}
+
+// measure writes length samples of gen, which returns sample i of
+// channel c, to a Loudness of channels in blocks of 100ms.
+func measure(t *testing.T, channels, length uint, gen func(c, i uint) float64) *Loudness {
+	t.Helper()
+	l, err := NewLoudness(channels, loudnessRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Free()
+	bufs := make([]*SimpleBuffer, channels)
+	for c := range bufs {
+		bufs[c] = newBuffer(loudnessRate / 10)
+		defer freeBuffer(bufs[c])
+	}
+	block := make([]float64, loudnessRate/10)
+	for start := uint(0); start < length; start += uint(len(block)) {
+		n := length - start
+		if n > uint(len(block)) {
+			n = uint(len(block))
+		}
+		for c := range bufs {
+			for i := uint(0); i < n; i++ {
+				block[i] = gen(uint(c), start+i)
+			}
+			bufs[c].SetData(block[:n])
+		}
+		if _, err := l.WriteChannels(bufs, n); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return l
+}
+
+// sine measures a 1kHz sine through the levels, in the channels for
+// which active returns true and silence in the others.
+func sine(t *testing.T, channels uint, active func(c uint) bool, levels ...level) *Loudness {
+	t.Helper()
+	var ends []uint
+	var amps []float64
+	length := uint(0)
+	for _, lv := range levels {
+		length += uint(math.Floor(lv.seconds*loudnessRate + 0.5))
+		ends = append(ends, length)
+		amps = append(amps, math.Pow(10, lv.dbfs/20))
+	}
+	seg := 0
+	return measure(t, channels, length, func(c, i uint) float64 {
+		if !active(c) {
+			return 0
+		}
+		for i >= ends[seg] {
+			seg++
+		}
+		for seg > 0 && i < ends[seg-1] {
+			seg--
+		}
+		return amps[seg] * math.Sin(2*math.Pi*1000*float64(i)/loudnessRate)
+	})
+}
+
+// everyChannel puts a test signal in every channel.
+func everyChannel(uint) bool { return true }
+
+func TestLoudnessSine(t *testing.T) {
+	// BS.1770 calibrates K-weighting so a 1kHz sine reads its RMS
+	// level: -20dBFS peak is -23LUFS.
+	l := sine(t, 1, everyChannel, level{20, -20})
+	if got := l.Integrated(); math.Abs(got+23) > 0.1 {
+		t.Errorf("Integrated = %.2f LUFS, want -23.0", got)
+	}
+	if got := l.SamplePeak(); math.Abs(got+20) > 0.01 {
+		t.Errorf("SamplePeak = %.2f dBFS, want -20.0", got)
+	}
+	if got := l.TruePeak(); math.Abs(got+20) > 0.1 {
+		t.Errorf("TruePeak = %.2f dBTP, want -20.0", got)
+	}
+	if got := l.Duration(); got != 20 {
+		t.Errorf("Duration = %g, want 20", got)
+	}
+	if got := l.Gain(-14); math.Abs(got-9) > 0.1 {
+		t.Errorf("Gain(-14) = %.2f dB, want 9.0", got)
+	}
+}
+
+func TestLoudnessEBU3341(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Minutes of stereo audio")
+	}
+	// The stereo cases of EBU Tech 3341 checking integrated loudness
+	// and its gates, to within 0.1 LU.
+	tests := []struct {
+		name   string
+		levels []level
+		want   float64
+	}{
+		{"case 1", []level{{20, -23}}, -23},
+		{"case 2", []level{{20, -33}}, -33},
+		{"case 3", []level{{10, -36}, {60, -23}, {10, -36}}, -23},
+		{"case 4", []level{{10, -72}, {10, -36}, {60, -23}, {10, -36}, {10, -72}}, -23},
+		{"case 5", []level{{20, -26}, {20.1, -20}, {20, -26}}, -23},
+	}
+	for _, tc := range tests {
+		l := sine(t, 2, everyChannel, tc.levels...)
+		if got := l.Integrated(); math.Abs(got-tc.want) > 0.1 {
+			t.Errorf("%s: Integrated = %.2f LUFS, want %g", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestLoudnessEBU3342(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Minutes of stereo audio")
+	}
+	// The stereo cases of EBU Tech 3342 checking loudness range, to
+	// within 1 LU.
+	tests := []struct {
+		name   string
+		levels []level
+		want   float64
+	}{
+		{"case 1", []level{{20, -20}, {20, -30}}, 10},
+		{"case 2", []level{{20, -20}, {20, -15}}, 5},
+		{"case 3", []level{{20, -40}, {20, -20}}, 20},
+		{"case 4", []level{{20, -50}, {20, -35}, {20, -20}, {20, -35}, {20, -50}}, 15},
+	}
+	for _, tc := range tests {
+		l := sine(t, 2, everyChannel, tc.levels...)
+		if got := l.Range(); math.Abs(got-tc.want) > 1 {
+			t.Errorf("%s: Range = %.2f LU, want %g", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestLoudnessTruePeak(t *testing.T) {
+	// A sine at a quarter of the samplerate, 45° out of phase with
+	// the samples, peaks between them 3dB above every sample. EBU
+	// Tech 3341 allows the true peak to read 0.4dB under or 0.2dB
+	// over.
+	l := measure(t, 1, loudnessRate, func(c, i uint) float64 {
+		return math.Sin(math.Pi/2*float64(i) + math.Pi/4)
+	})
+	if got := l.SamplePeak(); math.Abs(got+3.01) > 0.01 {
+		t.Errorf("SamplePeak = %.2f dBFS, want -3.01", got)
+	}
+	if got := l.TruePeak(); got < -0.4 || got > 0.2 {
+		t.Errorf("TruePeak = %.2f dBTP, want 0.0", got)
+	}
+}
+
+func TestLoudnessChannelWeights(t *testing.T) {
+	// A sine in one channel reads its level plus the channel's weight,
+	// which is 1.41 or +1.5dB for the surround channels of 5.1 and 0
+	// for the LFE.
+	for _, tc := range []struct {
+		channels, c uint
+		want        float64
+	}{
+		{1, 0, -23},
+		{2, 1, -23},
+		{6, 0, -23},
+		{6, 1, -23},
+		{6, 2, -23},
+		{6, 4, -23 + 10*math.Log10(1.41)},
+		{6, 5, -23 + 10*math.Log10(1.41)},
+	} {
+		c := tc.c
+		l := sine(t, tc.channels, func(ch uint) bool { return ch == c }, level{5, -20})
+		if got := l.Integrated(); math.Abs(got-tc.want) > 0.1 {
+			t.Errorf("%d channels, sine in channel %d: Integrated = %.2f LUFS, want %.2f", tc.channels, c, got, tc.want)
+		}
+	}
+	l := sine(t, 6, func(c uint) bool { return c == 3 }, level{5, -20})
+	if got := l.Integrated(); !math.IsInf(got, -1) {
+		t.Errorf("Sine in the LFE: Integrated = %.2f LUFS, want -Inf", got)
+	}
+	// Every channel of 5.1 at once sums 5.82 times the power of one.
+	l = sine(t, 6, everyChannel, level{5, -20})
+	if want := -23 + 10*math.Log10(5.82); math.Abs(l.Integrated()-want) > 0.1 {
+		t.Errorf("Sine in every channel: Integrated = %.2f LUFS, want %.2f", l.Integrated(), want)
+	}
+}
+
+func TestLoudnessSilence(t *testing.T) {
+	l := measure(t, 1, loudnessRate*5, func(c, i uint) float64 { return 0 })
+	for name, got := range map[string]float64{
+		"Integrated": l.Integrated(),
+		"SamplePeak": l.SamplePeak(),
+		"TruePeak":   l.TruePeak(),
+	} {
+		if !math.IsInf(got, -1) {
+			t.Errorf("%s of silence = %g, want -Inf", name, got)
+		}
+	}
+	if got := l.Range(); got != 0 {
+		t.Errorf("Range of silence = %g, want 0", got)
+	}
+}
+
+func TestLoudnessWriteMultichannel(t *testing.T) {
+	l, err := NewLoudness(2, loudnessRate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	buf := newBuffer(480)
+	defer freeBuffer(buf)
+	buf.SetData(make([]float64, 480))
+	if n, err := l.Write(buf, 480); err == nil || n != 0 {
+		t.Errorf("Write on 2 channels = %d, %v, want an error", n, err)
+	}
+	if _, err := l.WriteChannels([]*SimpleBuffer{buf}, 480); err == nil {
+		t.Errorf("WriteChannels of 1 buffer on 2 channels succeeded")
+	}
+}
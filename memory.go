@@ -0,0 +1,127 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package aubio
+
+import (
+	"log"
+	"os"
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"sync"
+)
+
+// Every object wrapping aubio memory has a finalizer that frees it
+// once the object is garbage collected. The finalizer is only a safety
+// net: the garbage collector cannot see the memory aubio allocates, so
+// callers should still call Free or Close as soon as they are done.
+//
+// Leak detection records where each object was allocated and logs
+// the allocation stack of any object that reaches its finalizer
+// without having been freed. It can also be turned on by setting the
+// AUBIO_DETECT_LEAKS environment variable.
+
+// Allocation describes an object that has not been freed yet.
+type Allocation struct {
+	// Type is the Go type of the object, e.g. "*aubio.Pitch".
+	Type string
+	// Stack is the stack trace of the goroutine that allocated it.
+	Stack string
+}
+
+var leakDetector = struct {
+	sync.Mutex
+	enabled bool
+	live    map[uintptr]Allocation
+}{live: map[uintptr]Allocation{}}
+
+func init() {
+	if os.Getenv("AUBIO_DETECT_LEAKS") != "" {
+		DetectLeaks(true)
+	}
+}
+
+// DetectLeaks turns leak detection on or off. Only objects allocated
+// while it is on are tracked.
+func DetectLeaks(on bool) {
+	leakDetector.Lock()
+	defer leakDetector.Unlock()
+	leakDetector.enabled = on
+}
+
+// Leaks returns the tracked objects that have not been freed yet,
+// whether or not they are still reachable.
+//
+//	DetectLeaks(true)
+//	run()
+//	for _, l := range Leaks() {
+//		log.Printf("%s allocated at:\n%s", l.Type, l.Stack)
+//	}
+func Leaks() []Allocation {
+	leakDetector.Lock()
+	defer leakDetector.Unlock()
+	keys := make([]uintptr, 0, len(leakDetector.live))
+	for k := range leakDetector.live {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	allocs := make([]Allocation, len(keys))
+	for i, k := range keys {
+		allocs[i] = leakDetector.live[k]
+	}
+	return allocs
+}
+
+// manage sets finalizer on obj and records the allocation if leak
+// detection is on. finalizer must not refer to obj other than
+// through its argument.
+func manage(obj, finalizer interface{}) {
+	runtime.SetFinalizer(obj, finalizer)
+	track(obj)
+}
+
+// track records the allocation of obj if leak detection is on.
+func track(obj interface{}) {
+	leakDetector.Lock()
+	defer leakDetector.Unlock()
+	if leakDetector.enabled {
+		leakDetector.live[reflect.ValueOf(obj).Pointer()] = Allocation{
+			Type:  reflect.TypeOf(obj).String(),
+			Stack: string(debug.Stack()),
+		}
+	}
+}
+
+// unmanage clears the finalizer for an obj that has been freed.
+func unmanage(obj interface{}) {
+	runtime.SetFinalizer(obj, nil)
+	untrack(obj)
+}
+
+// untrack forgets the allocation of obj, e.g. while it is held by a
+// BufferPool rather than by a caller that could leak it.
+func untrack(obj interface{}) {
+	leakDetector.Lock()
+	defer leakDetector.Unlock()
+	delete(leakDetector.live, reflect.ValueOf(obj).Pointer())
+}
+
+// leaked is called by finalizers before they free obj.
+func leaked(obj interface{}) {
+	leakDetector.Lock()
+	defer leakDetector.Unlock()
+	key := reflect.ValueOf(obj).Pointer()
+	if a, ok := leakDetector.live[key]; ok {
+		log.Printf("%s was never freed. Allocated at:\n%s", a.Type, a.Stack)
+		delete(leakDetector.live, key)
+	}
+}
@@ -0,0 +1,315 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+// Package midi writes detected notes and tempo to Standard MIDI Files.
+//
+//	song := &midi.Song{Notes: notes, Tempo: midi.BeatTempo(beats)}
+//	if err := song.WriteFile("out.mid", midi.MultiTrack); err != nil {
+//		// handle error
+//	}
+package midi
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+
+	"go.marzhillstudios.com/pkg/play/aubio"
+)
+
+type smfFormat uint16
+
+const (
+	// SingleTrack writes a Type 0 file with the tempo map and notes
+	// in one track.
+	SingleTrack smfFormat = 0
+	// MultiTrack writes a Type 1 file with the tempo map in the first
+	// track and the notes in the second.
+	MultiTrack smfFormat = 1
+)
+
+// DefaultDivision is the number of ticks per quarter note used when a
+// Song doesn't set one.
+const DefaultDivision = 480
+
+// DefaultBpm is the tempo of a Song without a tempo map.
+const DefaultBpm = 120
+
+// Tempo is a change of tempo.
+type Tempo struct {
+	// Time is when the tempo takes effect in seconds.
+	Time float64
+	// Bpm is the number of quarter notes per minute.
+	Bpm float64
+}
+
+// ConstantTempo returns a tempo map holding the single tempo bpm, such
+// as the estimate returned by Tempo.GetBpm.
+func ConstantTempo(bpm float64) []Tempo {
+	return []Tempo{{Time: 0, Bpm: bpm}}
+}
+
+// BeatTempo returns a tempo map following beats, the beat times in
+// seconds as returned by Tempo.GetLastBeat. Every beat gets the tempo
+// of the interval to the next one and changes that don't alter the
+// tempo stored in the file are dropped. Fewer than two beats give an
+// empty map.
+func BeatTempo(beats []float64) []Tempo {
+	var tempo []Tempo
+	for i := 0; i+1 < len(beats); i++ {
+		interval := beats[i+1] - beats[i]
+		if interval <= 0 {
+			continue
+		}
+		t := Tempo{Time: beats[i], Bpm: 60 / interval}
+		if len(tempo) == 0 {
+			// The first tempo also covers the time before the first beat.
+			t.Time = 0
+		} else if microsPerQuarter(t.Bpm) == microsPerQuarter(tempo[len(tempo)-1].Bpm) {
+			continue
+		}
+		tempo = append(tempo, t)
+	}
+	return tempo
+}
+
+// Song is a transcription to be written as a MIDI file.
+type Song struct {
+	// Notes are the notes to write. Pitches are MIDI note numbers,
+	// as detected with PitchOutMidi, and are rounded to the nearest
+	// semitone.
+	Notes []aubio.Note
+	// Tempo is the tempo map, which need not be sorted. A Song
+	// without one is written at DefaultBpm.
+	Tempo []Tempo
+	// Division is the number of ticks per quarter note. 0 uses
+	// DefaultDivision.
+	Division uint16
+	// Channel is the MIDI channel of the notes, from 0 to 15.
+	Channel uint8
+	// Name is written as the name of the track holding the notes
+	// if set.
+	Name string
+}
+
+// event is a MIDI event at an absolute tick.
+type event struct {
+	tick uint64
+	// order sorts events at the same tick: tempo changes first,
+	// then note offs, then note ons.
+	order int
+	data  []byte
+}
+
+// WriteFile writes s to the file at path.
+func (s *Song) WriteFile(path string, format smfFormat) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := s.Write(f, format); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Write writes s to w as a Standard MIDI File in the given format.
+// It returns an error, writing nothing, if a note's pitch or velocity
+// is NaN or its times are not finite.
+func (s *Song) Write(w io.Writer, format smfFormat) error {
+	if format != SingleTrack && format != MultiTrack {
+		return fmt.Errorf("Unsupported MIDI file format %d", format)
+	}
+	if s.Channel > 15 {
+		return fmt.Errorf("Invalid MIDI channel %d", s.Channel)
+	}
+	division := s.Division
+	if division == 0 {
+		division = DefaultDivision
+	}
+	if division > 0x7fff {
+		return fmt.Errorf("Invalid MIDI division %d", division)
+	}
+	for i, n := range s.Notes {
+		if math.IsNaN(n.Pitch) || math.IsNaN(n.Velocity) {
+			return fmt.Errorf("Note %d has an undefined pitch or velocity", i)
+		}
+		if math.IsNaN(n.Start) || math.IsInf(n.Start, 0) || math.IsNaN(n.End) || math.IsInf(n.End, 0) {
+			return fmt.Errorf("Note %d has an undefined start or end", i)
+		}
+	}
+	tm := newTempoMap(s.Tempo, division)
+
+	tempoTrack := []event{{order: 0, data: []byte{0xff, 0x58, 4, 4, 2, 24, 8}}}
+	for i, us := range tm.us {
+		tempoTrack = append(tempoTrack, event{
+			tick:  tm.start[i],
+			order: 0,
+			data:  []byte{0xff, 0x51, 3, byte(us >> 16), byte(us >> 8), byte(us)},
+		})
+	}
+	var noteTrack []event
+	if s.Name != "" {
+		noteTrack = append(noteTrack, event{data: append([]byte{0xff, 0x03}, varLenString(s.Name)...)})
+	}
+	for _, n := range s.Notes {
+		key := byte(clamp(math.Floor(n.Pitch+0.5), 0, 127))
+		vel := byte(clamp(math.Floor(n.Velocity+0.5), 1, 127))
+		on := tm.ticks(n.Start)
+		off := tm.ticks(n.End)
+		if off <= on {
+			off = on + 1
+		}
+		noteTrack = append(noteTrack,
+			event{tick: on, order: 2, data: []byte{0x90 | s.Channel, key, vel}},
+			event{tick: off, order: 1, data: []byte{0x80 | s.Channel, key, 0}})
+	}
+
+	var tracks [][]event
+	if format == SingleTrack {
+		tracks = [][]event{append(tempoTrack, noteTrack...)}
+	} else {
+		tracks = [][]event{tempoTrack, noteTrack}
+	}
+	bw := bufio.NewWriter(w)
+	bw.WriteString("MThd")
+	binary.Write(bw, binary.BigEndian, []uint32{6})
+	binary.Write(bw, binary.BigEndian, []uint16{uint16(format), uint16(len(tracks)), division})
+	for _, track := range tracks {
+		bw.Write(encodeTrack(track))
+	}
+	return bw.Flush()
+}
+
+// encodeTrack returns the MTrk chunk for events. Channel messages use
+// running status: the status byte is left out when it repeats that of
+// the previous channel message. Meta events cancel running status.
+func encodeTrack(events []event) []byte {
+	sort.SliceStable(events, func(i, j int) bool {
+		if events[i].tick != events[j].tick {
+			return events[i].tick < events[j].tick
+		}
+		return events[i].order < events[j].order
+	})
+	var body bytes.Buffer
+	last := uint64(0)
+	running := byte(0)
+	for _, e := range events {
+		body.Write(varLen(e.tick - last))
+		switch status := e.data[0]; {
+		case status >= 0xf0:
+			running = 0
+			body.Write(e.data)
+		case status == running:
+			body.Write(e.data[1:])
+		default:
+			running = status
+			body.Write(e.data)
+		}
+		last = e.tick
+	}
+	// End of track.
+	body.Write([]byte{0, 0xff, 0x2f, 0})
+	chunk := make([]byte, 8, 8+body.Len())
+	copy(chunk, "MTrk")
+	binary.BigEndian.PutUint32(chunk[4:], uint32(body.Len()))
+	return append(chunk, body.Bytes()...)
+}
+
+// tempoMap converts times in seconds to ticks. It works from the
+// tempo values stored in the file, rounded to whole microseconds per
+// quarter note, and from the tick each change lands on, so positions
+// match what a player computes from the file however many changes
+// come before them.
+type tempoMap struct {
+	changes []Tempo
+	// us is the stored tempo of each change.
+	us []uint32
+	// start is the tick of each change and at is the time a player
+	// reaches that tick in seconds.
+	start    []uint64
+	at       []float64
+	division float64
+}
+
+func newTempoMap(tempo []Tempo, division uint16) *tempoMap {
+	changes := make([]Tempo, 0, len(tempo)+1)
+	for _, t := range tempo {
+		if t.Bpm > 0 {
+			changes = append(changes, t)
+		}
+	}
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].Time < changes[j].Time })
+	if len(changes) == 0 || changes[0].Time > 0 {
+		bpm := float64(DefaultBpm)
+		if len(changes) > 0 {
+			bpm = changes[0].Bpm
+		}
+		changes = append([]Tempo{{Time: 0, Bpm: bpm}}, changes...)
+	}
+	tm := &tempoMap{
+		changes:  changes,
+		us:       make([]uint32, len(changes)),
+		start:    make([]uint64, len(changes)),
+		at:       make([]float64, len(changes)),
+		division: float64(division),
+	}
+	for i, c := range changes {
+		tm.us[i] = microsPerQuarter(c.Bpm)
+		if i > 0 {
+			tm.start[i] = tm.after(i-1, c.Time)
+			tm.at[i] = tm.at[i-1] + float64(tm.start[i]-tm.start[i-1])/tm.division*float64(tm.us[i-1])/1e6
+		}
+	}
+	return tm
+}
+
+// after returns the tick of time t at the tempo of change i.
+func (tm *tempoMap) after(i int, t float64) uint64 {
+	quarters := (t - tm.at[i]) * 1e6 / float64(tm.us[i])
+	return tm.start[i] + uint64(math.Max(0, math.Floor(quarters*tm.division+0.5)))
+}
+
+func (tm *tempoMap) ticks(t float64) uint64 {
+	if t <= 0 {
+		return 0
+	}
+	i := sort.Search(len(tm.changes), func(i int) bool { return tm.changes[i].Time > t }) - 1
+	return tm.after(i, t)
+}
+
+// microsPerQuarter returns the value of a set tempo event for bpm.
+func microsPerQuarter(bpm float64) uint32 {
+	return uint32(clamp(math.Floor(60e6/bpm+0.5), 1, 0xffffff))
+}
+
+// varLen encodes v as a MIDI variable length quantity.
+func varLen(v uint64) []byte {
+	b := []byte{byte(v & 0x7f)}
+	for v >>= 7; v > 0; v >>= 7 {
+		b = append([]byte{byte(v&0x7f) | 0x80}, b...)
+	}
+	return b
+}
+
+// varLenString encodes s prefixed with its length.
+func varLenString(s string) []byte {
+	return append(varLen(uint64(len(s))), s...)
+}
+
+func clamp(v, min, max float64) float64 {
+	return math.Max(min, math.Min(max, v))
+}
@@ -0,0 +1,225 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package midi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"go.marzhillstudios.com/pkg/play/aubio"
+)
+
+func TestWriteSingleTrack(t *testing.T) {
+	song := &Song{Notes: []aubio.Note{{Start: 0, End: 0.5, Pitch: 60.2, Velocity: 100}}}
+	var b bytes.Buffer
+	if err := song.Write(&b, SingleTrack); err != nil {
+		t.Fatalf("Write failed: %s", err)
+	}
+	want := []byte{
+		'M', 'T', 'h', 'd', 0, 0, 0, 6,
+		0, 0, // Type 0
+		0, 1, // one track
+		0x01, 0xe0, // 480 ticks per quarter
+		'M', 'T', 'r', 'k', 0, 0, 0, 28,
+		0, 0xff, 0x58, 4, 4, 2, 24, 8, // 4/4
+		0, 0xff, 0x51, 3, 0x07, 0xa1, 0x20, // 500000us per quarter
+		0, 0x90, 60, 100,
+		0x83, 0x60, 0x80, 60, 0, // a quarter note later
+		0, 0xff, 0x2f, 0,
+	}
+	if !bytes.Equal(b.Bytes(), want) {
+		t.Errorf("Write =\n% x\nwant\n% x", b.Bytes(), want)
+	}
+}
+
+func TestWriteMultiTrackRunningStatus(t *testing.T) {
+	song := &Song{
+		Notes: []aubio.Note{
+			{Start: 0, End: 0.25, Pitch: 60, Velocity: 100},
+			{Start: 0, End: 0.25, Pitch: 64, Velocity: 90},
+		},
+		Tempo:    ConstantTempo(60),
+		Division: 96,
+		Channel:  2,
+		Name:     "x",
+	}
+	var b bytes.Buffer
+	if err := song.Write(&b, MultiTrack); err != nil {
+		t.Fatalf("Write failed: %s", err)
+	}
+	want := []byte{
+		'M', 'T', 'h', 'd', 0, 0, 0, 6,
+		0, 1, // Type 1
+		0, 2, // two tracks
+		0, 96,
+		'M', 'T', 'r', 'k', 0, 0, 0, 19,
+		0, 0xff, 0x58, 4, 4, 2, 24, 8,
+		0, 0xff, 0x51, 3, 0x0f, 0x42, 0x40, // 1000000us per quarter
+		0, 0xff, 0x2f, 0,
+		'M', 'T', 'r', 'k', 0, 0, 0, 23,
+		0, 0xff, 0x03, 1, 'x', // track name
+		0, 0x92, 60, 100,
+		0, 64, 90, // running status
+		24, 0x82, 60, 0,
+		0, 64, 0, // running status
+		0, 0xff, 0x2f, 0,
+	}
+	if !bytes.Equal(b.Bytes(), want) {
+		t.Errorf("Write =\n% x\nwant\n% x", b.Bytes(), want)
+	}
+}
+
+func TestWriteErrors(t *testing.T) {
+	var b bytes.Buffer
+	if err := (&Song{}).Write(&b, 2); err == nil {
+		t.Errorf("Writing a Type 2 file succeeded")
+	}
+	if err := (&Song{Channel: 16}).Write(&b, SingleTrack); err == nil {
+		t.Errorf("Writing channel 16 succeeded")
+	}
+	if err := (&Song{Division: 0x8000}).Write(&b, SingleTrack); err == nil {
+		t.Errorf("Writing an SMPTE division succeeded")
+	}
+}
+
+func TestWriteUndefinedNotes(t *testing.T) {
+	nan, inf := math.NaN(), math.Inf(1)
+	for _, n := range []aubio.Note{
+		{Start: 0, End: 1, Pitch: nan, Velocity: 100},
+		{Start: 0, End: 1, Pitch: 60, Velocity: nan},
+		{Start: nan, End: 1, Pitch: 60, Velocity: 100},
+		{Start: 0, End: nan, Pitch: 60, Velocity: 100},
+		{Start: 0, End: inf, Pitch: 60, Velocity: 100},
+	} {
+		var b bytes.Buffer
+		song := &Song{Notes: []aubio.Note{{Start: 0, End: 1, Pitch: 60, Velocity: 100}, n}}
+		if err := song.Write(&b, SingleTrack); err == nil {
+			t.Errorf("Writing note %+v succeeded", n)
+		}
+		if b.Len() != 0 {
+			t.Errorf("Writing note %+v wrote %d bytes", n, b.Len())
+		}
+	}
+}
+
+func TestVarLen(t *testing.T) {
+	tests := []struct {
+		v    uint64
+		want []byte
+	}{
+		{0, []byte{0}},
+		{0x7f, []byte{0x7f}},
+		{0x80, []byte{0x81, 0}},
+		{0x2000, []byte{0xc0, 0}},
+		{0x0fffffff, []byte{0xff, 0xff, 0xff, 0x7f}},
+	}
+	for _, tc := range tests {
+		if got := varLen(tc.v); !bytes.Equal(got, tc.want) {
+			t.Errorf("varLen(%#x) = % x, want % x", tc.v, got, tc.want)
+		}
+	}
+}
+
+// noteOnTimes decodes a Type 0 file and returns the time in seconds
+// of every note on, as a player following its tempo events would.
+func noteOnTimes(t *testing.T, data []byte) []float64 {
+	t.Helper()
+	division := float64(binary.BigEndian.Uint16(data[12:14]))
+	track := data[22:]
+	us := 500000.0
+	seconds := 0.0
+	running := byte(0)
+	var times []float64
+	for i := 0; i < len(track); {
+		delta := uint64(0)
+		for {
+			c := track[i]
+			i++
+			delta = delta<<7 | uint64(c&0x7f)
+			if c&0x80 == 0 {
+				break
+			}
+		}
+		seconds += float64(delta) / division * us / 1e6
+		status := track[i]
+		if status < 0x80 {
+			status = running
+		} else {
+			i++
+		}
+		switch {
+		case status == 0xff:
+			kind, size := track[i], int(track[i+1])
+			if kind == 0x51 {
+				us = float64(uint32(track[i+2])<<16 | uint32(track[i+3])<<8 | uint32(track[i+4]))
+			}
+			i += 2 + size
+			running = 0
+		case status&0xf0 == 0x90:
+			times = append(times, seconds)
+			i += 2
+			running = status
+		default:
+			i += 2
+			running = status
+		}
+	}
+	return times
+}
+
+// checkTimes checks that the notes of song play within half a tick
+// of their start. A tick lasts at most longest seconds.
+func checkTimes(t *testing.T, song *Song, longest float64) {
+	t.Helper()
+	var b bytes.Buffer
+	if err := song.Write(&b, SingleTrack); err != nil {
+		t.Fatal(err)
+	}
+	times := noteOnTimes(t, b.Bytes())
+	if len(times) != len(song.Notes) {
+		t.Fatalf("Decoded %d notes, want %d", len(times), len(song.Notes))
+	}
+	for i, n := range song.Notes {
+		if d := math.Abs(times[i] - n.Start); d > longest/2+1e-9 {
+			t.Errorf("Note %d plays at %.6fs, want %.6fs", i, times[i], n.Start)
+		}
+	}
+}
+
+func TestTempoRoundingDoesNotDrift(t *testing.T) {
+	// The file stores 500000us per quarter for this tempo, almost a
+	// microsecond short. Over three hours that adds up to more than a
+	// tick.
+	bpm := 60e6 / 500000.49
+	var notes []aubio.Note
+	for at := 0.0; at < 3*3600; at += 600 {
+		notes = append(notes, aubio.Note{Start: at, End: at + 1, Pitch: 60, Velocity: 100})
+	}
+	song := &Song{Notes: notes, Tempo: ConstantTempo(bpm), Division: 960}
+	checkTimes(t, song, 0.5/960)
+}
+
+func TestTempoChangesDoNotDrift(t *testing.T) {
+	// Slightly uneven beats give a tempo change on almost every beat,
+	// none of which lands on a whole tick or can be stored exactly.
+	var beats []float64
+	var notes []aubio.Note
+	at := 0.0
+	for i := 0; i < 400; i++ {
+		beats = append(beats, at)
+		notes = append(notes, aubio.Note{Start: at, End: at + 0.1, Pitch: 60, Velocity: 100})
+		at += 0.5 + 0.0137*math.Sin(float64(i))/3
+	}
+	song := &Song{Notes: notes, Tempo: BeatTempo(beats), Division: 96}
+	checkTimes(t, song, 0.51/96)
+}
@@ -0,0 +1,36 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package aubio
+
+/*
+#cgo LDFLAGS: -laubio
+#include <aubio/aubio.h>
+*/
+import "C"
+
+import (
+	"runtime"
+)
+
+// DbSpl returns the sound pressure level of buf in dB.
+func DbSpl(buf *SimpleBuffer) float64 {
+	db := float64(C.aubio_db_spl(buf.vec))
+	runtime.KeepAlive(buf)
+	return db
+}
+
+// SilenceDetection reports whether the sound pressure level of buf
+// is below threshold dB.
+func SilenceDetection(buf *SimpleBuffer, threshold float64) bool {
+	silent := C.aubio_silence_detection(buf.vec, C.smpl_t(threshold)) == 1
+	runtime.KeepAlive(buf)
+	return silent
+}
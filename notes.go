@@ -0,0 +1,146 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package aubio
+
+import (
+	"math"
+	"sort"
+)
+
+// Note is a single detected note.
+type Note struct {
+	// Start and End are in seconds.
+	Start float64
+	End   float64
+	// Pitch is the MIDI note number. It may be fractional.
+	Pitch float64
+	// Velocity is the MIDI velocity, between 0 and 127, derived
+	// from the level at the onset.
+	Velocity float64
+}
+
+// notesMedian is the number of pitch frames after an onset whose
+// median is used as the pitch of the note.
+const notesMedian = 6
+
+// Notes detects notes by combining an Onset and a Pitch object. Each
+// onset starts a new note whose pitch is the median of the pitches
+// detected in the frames that follow it. Notes end at the next onset
+// or when the input falls silent.
+type Notes struct {
+	onset      *Onset
+	pitch      *Pitch
+	samplerate uint
+	hopSize    uint
+	silence    float64
+	frame      uint
+	cur        *Note
+	pitches    []float64
+}
+
+// NewNotes constructs a new Notes object.
+// It is the Callers responsibility to call Free on the returned
+// Notes object or leak memory.
+//
+//	n, err := NewNotes(bufSize, blockSize, samplerate)
+//	if err != nil {
+//		// handle error
+//	}
+//	defer n.Free()
+func NewNotes(bufSize, blockSize, samplerate uint) (*Notes, error) {
+	o, err := NewOnset(HFC, bufSize, blockSize, samplerate)
+	if err != nil {
+		return nil, err
+	}
+	p := NewPitch(PitchYinfft, bufSize, blockSize, samplerate)
+	p.SetUnit(PitchOutMidi)
+	return &Notes{
+		onset:      o,
+		pitch:      p,
+		samplerate: samplerate,
+		hopSize:    blockSize,
+		silence:    -70,
+	}, nil
+}
+
+// SetSilence sets the silence threshold in dB used both for onset
+// detection and to end notes.
+func (n *Notes) SetSilence(silence float64) {
+	n.silence = silence
+	n.onset.SetSilence(silence)
+}
+
+// SetThreshold sets the onset detection peak picking threshold.
+func (n *Notes) SetThreshold(threshold float64) {
+	n.onset.SetThreshold(threshold)
+}
+
+// Do runs the note detection on one block of input. It returns the
+// note that ended in this block, if any.
+func (n *Notes) Do(in *SimpleBuffer) *Note {
+	now := float64(n.frame*n.hopSize) / float64(n.samplerate)
+	n.frame++
+	n.onset.Do(in)
+	n.pitch.Do(in)
+	var done *Note
+	switch {
+	case n.onset.Buffer().Slice()[0] != 0:
+		start := n.onset.GetLastOnset()
+		done = n.end(start)
+		n.cur = &Note{
+			Start:    start,
+			Velocity: math.Max(0, math.Min(127, 127+DbSpl(in))),
+		}
+	case n.cur != nil && SilenceDetection(in, n.silence):
+		return n.end(now)
+	}
+	if n.cur != nil && len(n.pitches) < notesMedian {
+		if p := n.pitch.Buffer().Slice()[0]; p > 0 {
+			n.pitches = append(n.pitches, p)
+		}
+	}
+	return done
+}
+
+// Flush ends the current note, if any, at the end of the input
+// processed so far and returns it.
+func (n *Notes) Flush() *Note {
+	return n.end(float64(n.frame*n.hopSize) / float64(n.samplerate))
+}
+
+// end finishes the current note at t. Notes without a pitch are
+// dropped.
+func (n *Notes) end(t float64) *Note {
+	note := n.cur
+	pitches := n.pitches
+	n.cur = nil
+	n.pitches = nil
+	if note == nil || len(pitches) == 0 {
+		return nil
+	}
+	sort.Float64s(pitches)
+	note.Pitch = pitches[len(pitches)/2]
+	note.End = t
+	return note
+}
+
+// Free frees the Onset and Pitch objects used by Notes.
+// It is safe to call Free more than once.
+func (n *Notes) Free() {
+	n.onset.Free()
+	n.pitch.Free()
+}
+
+// Close frees the Notes object. It always returns nil.
+func (n *Notes) Close() error {
+	n.Free()
+	return nil
+}
@@ -19,6 +19,7 @@ import "C"
 
 import (
 	"fmt"
+	"runtime"
 )
 
 type onsetMode string
@@ -43,6 +44,23 @@ const (
 	SpecFlux onsetMode = "specflux"
 )
 
+var onsetModes = []onsetMode{Energy, HFC, Complex, Phase, SpecDiff, K1, MK1, SpecFlux}
+
+// OnsetModes returns all of the onset detection functions.
+func OnsetModes() []onsetMode {
+	return append([]onsetMode(nil), onsetModes...)
+}
+
+// ParseOnsetMode returns the onset detection function called name.
+func ParseOnsetMode(name string) (onsetMode, error) {
+	for _, m := range onsetModes {
+		if string(m) == name {
+			return m, nil
+		}
+	}
+	return "", fmt.Errorf("Unknown onset mode %q", name)
+}
+
 // Tempo is a wrapper for the aubio_tempo_t tempo detection object.
 type Onset struct {
 	o   *C.aubio_onset_t
@@ -75,7 +93,9 @@ func NewOnset(
 	if t == nil {
 		return nil, fmt.Errorf("Failure creating Onset object %q", err)
 	}
-	return &Onset{o: t, buf: NewSimpleBuffer(blockSize)}, nil
+	o := &Onset{o: t, buf: NewSimpleBuffer(blockSize)}
+	manage(o, (*Onset).finalize)
+	return o, nil
 }
 
 func (t *Onset) Buffer() *SimpleBuffer {
@@ -89,6 +109,8 @@ func (t *Onset) Do(input *SimpleBuffer) {
 		return
 	}
 	C.aubio_onset_do(t.o, input.vec, t.buf.vec)
+	runtime.KeepAlive(t)
+	runtime.KeepAlive(input)
 }
 
 // SetSilence sets the onset detection silence threshold.
@@ -107,27 +129,50 @@ func (t *Onset) SetThreshold(threshold float64) {
 	C.aubio_onset_set_threshold(t.o, C.smpl_t(threshold))
 }
 
-/* TODO(jwall): Update to the latest version of the aubio library
-// GetLastOnset returns the bpm after running Do on an input Buffer
+// GetLastOnset returns the time in seconds of the last detected onset.
+// After running Do a non-zero first sample in Buffer means the onset
+// returned here was just detected.
 //     t, err := NewOnset(mode, bufSize, blockSize, samplerate)
 //      if err != nil {
 //      }
-//      defer t.Close()
+//      defer t.Free()
 //      t.Do(buf)
-//      fmt.Println("BPM: ", t.GetBpm())
+//      if t.Buffer().Slice()[0] != 0 {
+//          fmt.Println("Onset: ", t.GetLastOnset())
+//      }
 func (t *Onset) GetLastOnset() float64 {
 	if t.o == nil {
 		return 0
 	}
-	return float64(C.aubio_onset_get_last_onset(t.o))
+	return float64(C.aubio_onset_get_last_s(t.o))
 }
-*/
 
-// Free frees the aubio_temp_t object's memory.
+// Free frees the aubio_onset_t object's memory and its output buffer.
+// It is safe to call Free more than once.
 func (t *Onset) Free() {
-	if t.o == nil {
-		return
+	unmanage(t)
+	if t.o != nil {
+		C.del_aubio_onset(t.o)
+		t.o = nil
+	}
+	if t.buf != nil {
+		t.buf.Free()
+		t.buf = nil
+	}
+}
+
+// Close frees the Onset object. It always returns nil.
+func (t *Onset) Close() error {
+	t.Free()
+	return nil
+}
+
+// finalize only frees the aubio_onset_t, the output buffer has
+// a finalizer of its own.
+func (t *Onset) finalize() {
+	leaked(t)
+	if t.o != nil {
+		C.del_aubio_onset(t.o)
+		t.o = nil
 	}
-	C.del_aubio_onset(t.o)
-	t.o = nil
 }
@@ -0,0 +1,113 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package aubio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+type pcmFormat string
+
+const (
+	// Raw PCM sample encodings. All multi-byte encodings are little endian.
+	// Unsigned 8 bit integer samples
+	PCMU8 pcmFormat = "u8"
+	// Signed 16 bit integer samples
+	PCMS16LE pcmFormat = "s16le"
+	// Signed 24 bit integer samples packed in 3 bytes
+	PCMS24LE pcmFormat = "s24le"
+	// Signed 32 bit integer samples
+	PCMS32LE pcmFormat = "s32le"
+	// 32 bit IEEE float samples
+	PCMF32LE pcmFormat = "f32le"
+	// 64 bit IEEE float samples
+	PCMF64LE pcmFormat = "f64le"
+)
+
+// sampleSize returns the number of bytes used by one sample in
+// this format or 0 if the format is unknown.
+func (f pcmFormat) sampleSize() uint {
+	switch f {
+	case PCMU8:
+		return 1
+	case PCMS16LE:
+		return 2
+	case PCMS24LE:
+		return 3
+	case PCMS32LE, PCMF32LE:
+		return 4
+	case PCMF64LE:
+		return 8
+	}
+	return 0
+}
+
+// ParsePCMFormat returns the sample encoding called name, e.g. "s16le".
+func ParsePCMFormat(name string) (pcmFormat, error) {
+	f := pcmFormat(name)
+	if err := f.check(); err != nil {
+		return "", err
+	}
+	return f, nil
+}
+
+func (f pcmFormat) check() error {
+	if f.sampleSize() == 0 {
+		return fmt.Errorf("Unknown pcm format %q", string(f))
+	}
+	return nil
+}
+
+// decode decodes the sample at the start of b into the range [-1, 1].
+// b must hold at least sampleSize bytes.
+func (f pcmFormat) decode(b []byte) float64 {
+	switch f {
+	case PCMU8:
+		return (float64(b[0]) - 128) / 128
+	case PCMS16LE:
+		return float64(int16(binary.LittleEndian.Uint16(b))) / (1 << 15)
+	case PCMS24LE:
+		v := int32(uint32(b[0])<<8|uint32(b[1])<<16|uint32(b[2])<<24) >> 8
+		return float64(v) / (1 << 23)
+	case PCMS32LE:
+		return float64(int32(binary.LittleEndian.Uint32(b))) / (1 << 31)
+	case PCMF32LE:
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+	case PCMF64LE:
+		return math.Float64frombits(binary.LittleEndian.Uint64(b))
+	}
+	return 0
+}
+
+// encode encodes v into the start of b, clipping integer formats
+// to the range [-1, 1]. b must hold at least sampleSize bytes.
+func (f pcmFormat) encode(b []byte, v float64) {
+	if f != PCMF32LE && f != PCMF64LE {
+		v = math.Max(-1, math.Min(1, v))
+	}
+	switch f {
+	case PCMU8:
+		b[0] = uint8(math.Min(255, math.Round(v*128+128)))
+	case PCMS16LE:
+		binary.LittleEndian.PutUint16(b, uint16(int16(math.Min(math.MaxInt16, math.Round(v*(1<<15))))))
+	case PCMS24LE:
+		i := int32(math.Min(1<<23-1, math.Round(v*(1<<23))))
+		b[0], b[1], b[2] = byte(i), byte(i>>8), byte(i>>16)
+	case PCMS32LE:
+		binary.LittleEndian.PutUint32(b, uint32(int32(math.Min(math.MaxInt32, math.Round(v*(1<<31))))))
+	case PCMF32LE:
+		binary.LittleEndian.PutUint32(b, math.Float32bits(float32(v)))
+	case PCMF64LE:
+		binary.LittleEndian.PutUint64(b, math.Float64bits(v))
+	}
+}
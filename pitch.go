@@ -17,7 +17,9 @@ package aubio
 import "C"
 
 import (
+	"fmt"
 	"log"
+	"runtime"
 )
 
 type pitchMode string
@@ -45,6 +47,24 @@ const (
 	PitchOutDefault = "default"
 )
 
+// ParsePitchMode returns the pitch detection function called name.
+func ParsePitchMode(name string) (pitchMode, error) {
+	switch pitchMode(name) {
+	case PitchDefault, PitchYin, PitchMcomb, PitchSchmitt, PitchFcomb, "yinfft":
+		return pitchMode(name), nil
+	}
+	return "", fmt.Errorf("Unknown pitch mode %q", name)
+}
+
+// ParsePitchUnit returns the pitch output mode called name.
+func ParsePitchUnit(name string) (pitchOutMode, error) {
+	switch name {
+	case PitchOutFreq, PitchOutMidi, PitchOutCent, PitchOutBin, PitchOutDefault:
+		return pitchOutMode(name), nil
+	}
+	return "", fmt.Errorf("Unknown pitch unit %q", name)
+}
+
 // Pitch is a wrapper for the aubio_pitch_t pitch detection object.
 type Pitch struct {
 	o   *C.aubio_pitch_t
@@ -59,7 +79,7 @@ type Pitch struct {
 //     p := NewPitch(mode, bufSize, blockSize, samplerate)
 //     defer p.Free()
 func NewPitch(mode pitchMode, bufSize, blockSize, sampleRate uint) *Pitch {
-	return &Pitch{
+	p := &Pitch{
 		o: C.new_aubio_pitch(
 			toCharTPtr(string(mode)),
 			C.uint_t(bufSize),
@@ -67,6 +87,27 @@ func NewPitch(mode pitchMode, bufSize, blockSize, sampleRate uint) *Pitch {
 			C.uint_t(sampleRate)),
 		buf: NewSimpleBuffer(blockSize),
 	}
+	manage(p, (*Pitch).finalize)
+	return p
+}
+
+// NewPitchChecked is NewPitch but returns an error rather than a
+// Pitch that detects nothing when aubio does not support mode with
+// these sizes and samplerate.
+//
+//	p, err := NewPitchChecked(mode, bufSize, blockSize, samplerate)
+//	if err != nil {
+//		// handle error
+//	}
+//	defer p.Free()
+func NewPitchChecked(mode pitchMode, bufSize, blockSize, sampleRate uint) (*Pitch, error) {
+	p := NewPitch(mode, bufSize, blockSize, sampleRate)
+	if p.o == nil {
+		p.Free()
+		return nil, fmt.Errorf("Failed to create %s pitch detection with buffer size %d and hop size %d at %dHz",
+			mode, bufSize, blockSize, sampleRate)
+	}
+	return p, nil
 }
 
 func (p *Pitch) Buffer() *SimpleBuffer {
@@ -83,25 +124,30 @@ func (p *Pitch) SetUnit(outMode pitchOutMode) {
 	C.aubio_pitch_set_unit(p.o, toCharTPtr(string(outMode)))
 }
 
-/* TODO(jwall) Update to latest version of aubio and add this in
 // GetConfidence returns the current confidence of the pitch detection
 // algorithm.
 func (p *Pitch) GetConfidence() float64 {
+	if p.o == nil {
+		return 0
+	}
 	return float64(C.aubio_pitch_get_confidence(p.o))
 }
-*/
 
 // Do runs one step of the pitch detection as determined by the bufSize.
 func (p *Pitch) Do(in *SimpleBuffer) {
 	if p.o != nil {
 		C.aubio_pitch_do(p.o, in.vec, p.buf.vec)
+		runtime.KeepAlive(p)
+		runtime.KeepAlive(in)
 	} else {
 		log.Println("Called Do on empty Pitch. Maybe you called Free previously?")
 	}
 }
 
 // Free frees the memory allocated by the aubio library for this object.
+// It is safe to call Free more than once.
 func (p *Pitch) Free() {
+	unmanage(p)
 	if p.o != nil {
 		C.del_aubio_pitch(p.o)
 		p.o = nil
@@ -111,3 +157,19 @@ func (p *Pitch) Free() {
 		p.buf = nil
 	}
 }
+
+// Close frees the Pitch object. It always returns nil.
+func (p *Pitch) Close() error {
+	p.Free()
+	return nil
+}
+
+// finalize only frees the aubio_pitch_t. The output buffer may still
+// be referenced elsewhere and has a finalizer of its own.
+func (p *Pitch) finalize() {
+	leaked(p)
+	if p.o != nil {
+		C.del_aubio_pitch(p.o)
+		p.o = nil
+	}
+}
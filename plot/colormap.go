@@ -0,0 +1,76 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package plot
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+)
+
+// Colormap returns the color of a value between 0 and 1.
+type Colormap func(v float64) color.RGBA
+
+// Gradient returns a Colormap interpolating linearly between evenly
+// spaced stops, the first at 0 and the last at 1.
+func Gradient(stops ...color.RGBA) Colormap {
+	return func(v float64) color.RGBA {
+		if len(stops) == 1 || v <= 0 {
+			return stops[0]
+		}
+		if v >= 1 {
+			return stops[len(stops)-1]
+		}
+		pos := v * float64(len(stops)-1)
+		i := int(pos)
+		f := pos - float64(i)
+		a, b := stops[i], stops[i+1]
+		mix := func(x, y uint8) uint8 {
+			return uint8(math.Round(float64(x) + f*(float64(y)-float64(x))))
+		}
+		return color.RGBA{mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B), mix(a.A, b.A)}
+	}
+}
+
+// hex returns the opaque color written as 0xRRGGBB.
+func hex(c uint32) color.RGBA {
+	return color.RGBA{uint8(c >> 16), uint8(c >> 8), uint8(c), 0xff}
+}
+
+var (
+	// Gray runs from black to white.
+	Gray = Gradient(hex(0x000000), hex(0xffffff))
+	// Viridis runs from dark blue through green to yellow.
+	Viridis = Gradient(hex(0x440154), hex(0x472d7b), hex(0x3b528b), hex(0x2c728e),
+		hex(0x21918c), hex(0x28ae80), hex(0x5ec962), hex(0xaddc30), hex(0xfde725))
+	// Magma runs from black through purple to pale yellow.
+	Magma = Gradient(hex(0x000004), hex(0x1c1044), hex(0x4f127b), hex(0x812581),
+		hex(0xb5367a), hex(0xe55064), hex(0xfb8761), hex(0xfec287), hex(0xfcfdbf))
+	// Inferno runs from black through red to bright yellow.
+	Inferno = Gradient(hex(0x000004), hex(0x1f0c48), hex(0x550f6d), hex(0x88226a),
+		hex(0xba3655), hex(0xe35933), hex(0xf98e09), hex(0xf9cb35), hex(0xfcffa4))
+)
+
+// ParseColormap returns the Colormap called name: gray, viridis, magma
+// or inferno.
+func ParseColormap(name string) (Colormap, error) {
+	switch name {
+	case "gray":
+		return Gray, nil
+	case "viridis":
+		return Viridis, nil
+	case "magma":
+		return Magma, nil
+	case "inferno":
+		return Inferno, nil
+	}
+	return nil, fmt.Errorf("Unknown colormap %q", name)
+}
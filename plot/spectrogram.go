@@ -0,0 +1,164 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+// Package plot renders analysis results as images.
+package plot
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+
+	"go.marzhillstudios.com/pkg/play/aubio"
+)
+
+// DefaultRange is the dB range a spectrogram shows when its options
+// have none.
+const DefaultRange = 80
+
+// SpectrogramOptions configures the rendering of a spectrogram.
+type SpectrogramOptions struct {
+	// Width and Height of the image in pixels. 0 uses one pixel per
+	// frame and per row.
+	Width  int
+	Height int
+	// Colormap colors the magnitudes. Defaults to Viridis.
+	Colormap Colormap
+	// Range is the number of dB below the loudest cell that are
+	// shown. Anything quieter gets the lowest color. Defaults to
+	// DefaultRange.
+	Range float64
+
+	// Onsets and Beats are drawn as vertical lines at their times in
+	// seconds, onsets dashed.
+	Onsets []float64
+	Beats  []float64
+	// Pitches are drawn as dots at their frequency, which must be in
+	// Hz. Frames with no pitch or a confidence below MinConfidence
+	// are left out.
+	Pitches       []aubio.PitchFrame
+	MinConfidence float64
+	// The colors of the overlays. They default to red, white and
+	// cyan.
+	OnsetColor color.Color
+	BeatColor  color.Color
+	PitchColor color.Color
+}
+
+func (o *SpectrogramOptions) defaults(s *aubio.Spectrogram) {
+	if o.Width <= 0 {
+		o.Width = len(s.Frames)
+	}
+	if o.Height <= 0 {
+		o.Height = len(s.Freqs)
+	}
+	if o.Width <= 0 {
+		o.Width = 1
+	}
+	if o.Height <= 0 {
+		o.Height = 1
+	}
+	if o.Colormap == nil {
+		o.Colormap = Viridis
+	}
+	if o.Range <= 0 {
+		o.Range = DefaultRange
+	}
+	if o.OnsetColor == nil {
+		o.OnsetColor = color.RGBA{0xff, 0x30, 0x30, 0xff}
+	}
+	if o.BeatColor == nil {
+		o.BeatColor = color.White
+	}
+	if o.PitchColor == nil {
+		o.PitchColor = color.RGBA{0x00, 0xff, 0xff, 0xff}
+	}
+}
+
+// Spectrogram draws s with time running left to right and frequency
+// bottom to top, with the overlays in opts on top.
+func Spectrogram(s *aubio.Spectrogram, opts SpectrogramOptions) *image.RGBA {
+	opts.defaults(s)
+	w, h := opts.Width, opts.Height
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	frames, rows := len(s.Frames), len(s.Freqs)
+
+	// Convert to dB relative to the loudest cell.
+	db := make([][]float64, frames)
+	max := math.Inf(-1)
+	for i, frame := range s.Frames {
+		db[i] = make([]float64, len(frame))
+		for j, m := range frame {
+			db[i][j] = 20 * math.Log10(m)
+			max = math.Max(max, db[i][j])
+		}
+	}
+	for x := 0; x < w; x++ {
+		for y := 0; y < h; y++ {
+			v := 0.0
+			if frames > 0 && rows > 0 {
+				i, j := x*frames/w, (h-1-y)*rows/h
+				if j < len(db[i]) {
+					v = (db[i][j] - max + opts.Range) / opts.Range
+				}
+			}
+			if math.IsNaN(v) || v < 0 {
+				v = 0
+			}
+			img.SetRGBA(x, y, opts.Colormap(v))
+		}
+	}
+	if frames == 0 {
+		return img
+	}
+
+	// Seconds and rows to pixels.
+	duration := s.Time(frames)
+	column := func(t float64) int {
+		return int(t / duration * float64(w))
+	}
+	for _, t := range opts.Beats {
+		for y := 0; y < h; y++ {
+			img.Set(column(t), y, opts.BeatColor)
+		}
+	}
+	for _, t := range opts.Onsets {
+		for y := 0; y < h; y++ {
+			if y/4%2 == 0 {
+				img.Set(column(t), y, opts.OnsetColor)
+			}
+		}
+	}
+	for _, p := range opts.Pitches {
+		if p.Pitch <= 0 || p.Confidence < opts.MinConfidence {
+			continue
+		}
+		row := s.Row(p.Pitch)
+		if row < 0 {
+			continue
+		}
+		x := column(p.Time)
+		y := h - 1 - int((row+0.5)*float64(h)/float64(rows))
+		for dx := -1; dx <= 1; dx++ {
+			for dy := -1; dy <= 1; dy++ {
+				img.Set(x+dx, y+dy, opts.PitchColor)
+			}
+		}
+	}
+	return img
+}
+
+// WriteSpectrogramPNG draws s as Spectrogram does and writes it to w
+// as a PNG.
+func WriteSpectrogramPNG(w io.Writer, s *aubio.Spectrogram, opts SpectrogramOptions) error {
+	return png.Encode(w, Spectrogram(s, opts))
+}
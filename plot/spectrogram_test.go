@@ -0,0 +1,148 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package plot
+
+import (
+	"image/color"
+	"testing"
+
+	"go.marzhillstudios.com/pkg/play/aubio"
+)
+
+func TestGradient(t *testing.T) {
+	g := Gradient(color.RGBA{0, 0, 0, 255}, color.RGBA{200, 100, 0, 255}, color.RGBA{200, 100, 50, 255})
+	for v, want := range map[float64]color.RGBA{
+		-1:   {0, 0, 0, 255},
+		0:    {0, 0, 0, 255},
+		0.25: {100, 50, 0, 255},
+		0.5:  {200, 100, 0, 255},
+		0.6:  {200, 100, 10, 255},
+		1:    {200, 100, 50, 255},
+		2:    {200, 100, 50, 255},
+	} {
+		if got := g(v); got != want {
+			t.Errorf("Gradient(%g) = %v, want %v", v, got, want)
+		}
+	}
+	one := Gradient(color.RGBA{1, 2, 3, 4})
+	if got := one(0.5); got != (color.RGBA{1, 2, 3, 4}) {
+		t.Errorf("Gradient of one stop = %v, want it", got)
+	}
+}
+
+// spectrogram returns a spectrogram of one frame every 100ms with the
+// given rows.
+func spectrogram(frames ...[]float64) *aubio.Spectrogram {
+	s := &aubio.Spectrogram{Samplerate: 1000, HopSize: 100, Frames: frames}
+	if len(frames) > 0 {
+		for i := range frames[0] {
+			s.Freqs = append(s.Freqs, float64(100*(i+1)))
+		}
+	}
+	return s
+}
+
+func TestSpectrogramRange(t *testing.T) {
+	// 0, -20, -40 and -60dB, lowest frequency at the bottom.
+	s := spectrogram([]float64{1, 0.1, 0.01, 0.001})
+	img := Spectrogram(s, SpectrogramOptions{Colormap: Gray, Range: 40})
+	if b := img.Bounds(); b.Dx() != 1 || b.Dy() != 4 {
+		t.Fatalf("Image is %dx%d, want 1x4", b.Dx(), b.Dy())
+	}
+	for y, want := range []uint8{0, 0, 128, 255} {
+		if got := img.RGBAAt(0, y); got != (color.RGBA{want, want, want, 255}) {
+			t.Errorf("Pixel at y %d = %v, want gray %d", y, got, want)
+		}
+	}
+}
+
+func TestSpectrogramSilent(t *testing.T) {
+	lowest := Viridis(0)
+	for name, s := range map[string]*aubio.Spectrogram{
+		"zeros":     spectrogram([]float64{0, 0, 0}, []float64{0, 0, 0}),
+		"no frames": spectrogram(),
+	} {
+		img := Spectrogram(s, SpectrogramOptions{Width: 4, Height: 3})
+		for y := 0; y < 3; y++ {
+			for x := 0; x < 4; x++ {
+				if got := img.RGBAAt(x, y); got != lowest {
+					t.Fatalf("%s: pixel at %d,%d = %v, want %v", name, x, y, got, lowest)
+				}
+			}
+		}
+	}
+}
+
+func TestSpectrogramOverlays(t *testing.T) {
+	// 10 frames, a second, over 20 pixels.
+	frames := make([][]float64, 10)
+	for i := range frames {
+		frames[i] = []float64{0, 0}
+	}
+	red := color.RGBA{255, 0, 0, 255}
+	white := color.RGBA{255, 255, 255, 255}
+	img := Spectrogram(spectrogram(frames...), SpectrogramOptions{
+		Width:      20,
+		Height:     16,
+		Colormap:   Gray,
+		Beats:      []float64{0.25, 0.5},
+		Onsets:     []float64{0.75},
+		OnsetColor: red,
+		BeatColor:  white,
+	})
+	for x := 0; x < 20; x++ {
+		for y := 0; y < 16; y++ {
+			want := color.RGBA{0, 0, 0, 255}
+			switch {
+			case x == 5 || x == 10:
+				want = white
+			case x == 15 && y/4%2 == 0:
+				// Onsets are dashed 4 pixels on, 4 off.
+				want = red
+			}
+			if got := img.RGBAAt(x, y); got != want {
+				t.Errorf("Pixel at %d,%d = %v, want %v", x, y, got, want)
+			}
+		}
+	}
+}
+
+func TestSpectrogramPitches(t *testing.T) {
+	frames := make([][]float64, 10)
+	for i := range frames {
+		frames[i] = []float64{0, 0, 0, 0}
+	}
+	cyan := color.RGBA{0, 255, 255, 255}
+	img := Spectrogram(spectrogram(frames...), SpectrogramOptions{
+		Colormap: Gray,
+		Pitches: []aubio.PitchFrame{
+			{Time: 0.2, Pitch: 200, Confidence: 1},
+			// Unvoiced, unsure or off the rows.
+			{Time: 0.5, Pitch: 0, Confidence: 1},
+			{Time: 0.6, Pitch: 300, Confidence: 0.1},
+			{Time: 0.8, Pitch: 1000, Confidence: 1},
+		},
+		MinConfidence: 0.5,
+		PitchColor:    cyan,
+	})
+	// 200Hz is row 1 of 4, the third pixel from the top.
+	for x := 0; x < 10; x++ {
+		for y := 0; y < 4; y++ {
+			want := color.RGBA{0, 0, 0, 255}
+			if x >= 1 && x <= 3 && y >= 1 && y <= 3 {
+				want = cyan
+			}
+			if got := img.RGBAAt(x, y); got != want {
+				t.Errorf("Pixel at %d,%d = %v, want %v", x, y, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,235 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package aubio
+
+import (
+	"sync"
+)
+
+// BufferPool keeps freed buffers around, keyed by size, so they can be
+// reused instead of going back through new_fvec and del_fvec.
+// It is safe for concurrent use.
+//
+// Buffers held by the pool are not reported by Leaks. A buffer taken
+// from the pool is tracked again from the point it was taken.
+type BufferPool struct {
+	mu         sync.Mutex
+	maxPerSize int
+	simple     map[uint][]*SimpleBuffer
+	complex    map[uint][]*ComplexBuffer
+	long       map[uint][]*LongSampleBuffer
+}
+
+// NewBufferPool constructs a BufferPool that keeps at most maxPerSize
+// buffers of each type and size. Buffers Put beyond that are freed.
+//
+// The caller is responsible for calling Free on the returned
+// BufferPool to release the buffers it holds.
+//
+//	pool := NewBufferPool(16)
+//	defer pool.Free()
+//	buf := pool.GetSimple(512)
+//	defer pool.PutSimple(buf)
+func NewBufferPool(maxPerSize int) *BufferPool {
+	return &BufferPool{
+		maxPerSize: maxPerSize,
+		simple:     map[uint][]*SimpleBuffer{},
+		complex:    map[uint][]*ComplexBuffer{},
+		long:       map[uint][]*LongSampleBuffer{},
+	}
+}
+
+// GetSimple returns a zeroed SimpleBuffer of the given size.
+func (p *BufferPool) GetSimple(size uint) *SimpleBuffer {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var b *SimpleBuffer
+	if bs := p.simple[size]; len(bs) > 0 {
+		b = bs[len(bs)-1]
+		p.simple[size] = bs[:len(bs)-1]
+		track(b)
+	} else {
+		b = NewSimpleBuffer(size)
+	}
+	b.pool = p
+	return b
+}
+
+// PutSimple zeroes b and returns it to the pool. b must not be
+// used after it has been returned.
+func (p *BufferPool) PutSimple(b *SimpleBuffer) {
+	if b == nil || b.vec == nil {
+		return
+	}
+	b.Zero()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	b.pool = nil
+	if len(p.simple[b.Size()]) >= p.maxPerSize {
+		b.Free()
+		return
+	}
+	untrack(b)
+	p.simple[b.Size()] = append(p.simple[b.Size()], b)
+}
+
+// GetComplex returns a zeroed ComplexBuffer for a window of the
+// given size, as NewComplexBuffer would.
+func (p *BufferPool) GetComplex(size uint) *ComplexBuffer {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// cvec_t only stores the positive half of the spectrum.
+	key := size/2 + 1
+	var cb *ComplexBuffer
+	if cbs := p.complex[key]; len(cbs) > 0 {
+		cb = cbs[len(cbs)-1]
+		p.complex[key] = cbs[:len(cbs)-1]
+		track(cb)
+	} else {
+		cb = NewComplexBuffer(size)
+	}
+	cb.pool = p
+	return cb
+}
+
+// PutComplex zeroes cb and returns it to the pool. cb must not be
+// used after it has been returned.
+func (p *BufferPool) PutComplex(cb *ComplexBuffer) {
+	if cb == nil || cb.data == nil {
+		return
+	}
+	cb.Zero()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	cb.pool = nil
+	if len(p.complex[cb.Size()]) >= p.maxPerSize {
+		cb.Free()
+		return
+	}
+	untrack(cb)
+	p.complex[cb.Size()] = append(p.complex[cb.Size()], cb)
+}
+
+// GetLong returns a zeroed LongSampleBuffer of the given size.
+func (p *BufferPool) GetLong(size uint) *LongSampleBuffer {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if lbs := p.long[size]; len(lbs) > 0 {
+		lb := lbs[len(lbs)-1]
+		p.long[size] = lbs[:len(lbs)-1]
+		track(lb)
+		return lb
+	}
+	return NewLBuffer(size)
+}
+
+// PutLong zeroes lb and returns it to the pool. lb must not be
+// used after it has been returned. Buffers owned by a Filter are
+// never pooled.
+func (p *BufferPool) PutLong(lb *LongSampleBuffer) {
+	if lb == nil || lb.vec == nil || lb.owner != nil {
+		return
+	}
+	lb.Zero()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.long[lb.Size()]) >= p.maxPerSize {
+		lb.Free()
+		return
+	}
+	untrack(lb)
+	p.long[lb.Size()] = append(p.long[lb.Size()], lb)
+}
+
+// Free frees every buffer held by the pool. The pool can still be
+// used afterwards.
+func (p *BufferPool) Free() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for size, bs := range p.simple {
+		for _, b := range bs {
+			b.Free()
+		}
+		delete(p.simple, size)
+	}
+	for size, cbs := range p.complex {
+		for _, cb := range cbs {
+			cb.Free()
+		}
+		delete(p.complex, size)
+	}
+	for size, lbs := range p.long {
+		for _, lb := range lbs {
+			lb.Free()
+		}
+		delete(p.long, size)
+	}
+}
+
+// Close frees every buffer held by the pool. It always returns nil.
+func (p *BufferPool) Close() error {
+	p.Free()
+	return nil
+}
+
+var defaultPool struct {
+	sync.RWMutex
+	p *BufferPool
+}
+
+// SetBufferPool makes the pipelines and functions in this package
+// draw their internal working buffers from pool. Each buffer goes
+// back to the pool it was taken from when it is freed, even if the
+// pool has been changed since. Passing nil goes back to allocating
+// every buffer directly, which is the default.
+//
+// Buffers that are handed to callers, such as the output of an
+// analyzer's Buffer or Grain, are never pooled, so they can't be
+// recycled into another analyzer while a caller still holds them.
+//
+//	pool := NewBufferPool(64)
+//	SetBufferPool(pool)
+//	defer pool.Free()
+//	defer SetBufferPool(nil)
+func SetBufferPool(pool *BufferPool) {
+	defaultPool.Lock()
+	defer defaultPool.Unlock()
+	defaultPool.p = pool
+}
+
+func currentPool() *BufferPool {
+	defaultPool.RLock()
+	defer defaultPool.RUnlock()
+	return defaultPool.p
+}
+
+// newBuffer allocates an internal SimpleBuffer, from the pool if one
+// is set. It must only be used for buffers that are never handed to
+// callers.
+func newBuffer(size uint) *SimpleBuffer {
+	if p := currentPool(); p != nil {
+		return p.GetSimple(size)
+	}
+	return NewSimpleBuffer(size)
+}
+
+// freeBuffer releases a buffer allocated by newBuffer, returning it
+// to the pool it was taken from.
+func freeBuffer(b *SimpleBuffer) {
+	if b == nil {
+		return
+	}
+	if b.pool != nil {
+		b.pool.PutSimple(b)
+		return
+	}
+	b.Free()
+}
@@ -0,0 +1,92 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package aubio
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBufferPoolOwner(t *testing.T) {
+	first, second := NewBufferPool(4), NewBufferPool(4)
+	defer first.Free()
+	defer second.Free()
+	SetBufferPool(first)
+	defer SetBufferPool(nil)
+	b := newBuffer(64)
+	SetBufferPool(second)
+	freeBuffer(b)
+	if got := first.GetSimple(64); got != b {
+		t.Errorf("Buffer did not go back to the pool it was taken from")
+	} else {
+		first.PutSimple(got)
+	}
+	if n := len(second.simple[64]); n != 0 {
+		t.Errorf("Second pool holds %d buffers, want 0", n)
+	}
+
+	SetBufferPool(nil)
+	b = newBuffer(64)
+	freeBuffer(b)
+	if b.vec != nil {
+		t.Errorf("Buffer allocated without a pool was not freed")
+	}
+}
+
+func TestAnalyzerBuffersNotPooled(t *testing.T) {
+	pool := NewBufferPool(4)
+	defer pool.Free()
+	SetBufferPool(pool)
+	defer SetBufferPool(nil)
+
+	o := OnsetOrDie(HFC, 512, 256, 44100)
+	held := o.Buffer()
+	o.Free()
+	o = OnsetOrDie(HFC, 512, 256, 44100)
+	defer o.Free()
+	if o.Buffer() == held {
+		t.Errorf("Onset reused the output buffer of a freed Onset")
+	}
+	if n := len(pool.simple[256]); n != 0 {
+		t.Errorf("Pool holds %d output buffers, want 0", n)
+	}
+}
+
+func TestBufferPoolLeaks(t *testing.T) {
+	DetectLeaks(true)
+	defer DetectLeaks(false)
+	pool := NewBufferPool(4)
+	b := pool.GetSimple(32)
+	if !hasLeak(b) {
+		t.Errorf("A buffer taken from the pool is not tracked")
+	}
+	pool.PutSimple(b)
+	if hasLeak(b) {
+		t.Errorf("A buffer held by the pool is reported as a leak")
+	}
+	b = pool.GetSimple(32)
+	if !hasLeak(b) {
+		t.Errorf("A buffer taken again from the pool is not tracked")
+	}
+	pool.PutSimple(b)
+	pool.Free()
+	if hasLeak(b) {
+		t.Errorf("A freed buffer is reported as a leak")
+	}
+}
+
+// hasLeak reports whether b is tracked as not freed.
+func hasLeak(b *SimpleBuffer) bool {
+	leakDetector.Lock()
+	defer leakDetector.Unlock()
+	_, ok := leakDetector.live[reflect.ValueOf(b).Pointer()]
+	return ok
+}
@@ -0,0 +1,177 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package results
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// labelOf returns the text used for r in label tracks: its Label if
+// it has one, the value of notes, pitches and tempo estimates and
+// otherwise its Kind.
+func labelOf(r Record) string {
+	if r.Label != "" {
+		return r.Label
+	}
+	switch r.Kind {
+	case Note, Pitch, Tempo:
+		return formatFloat(r.Value)
+	}
+	return r.Kind
+}
+
+// fromLabel fills in r from a label read back from a label track.
+// The label of notes, pitches and tempo estimates is their value.
+func fromLabel(r *Record, label string) {
+	switch r.Kind {
+	case Note, Pitch, Tempo:
+		if v, err := strconv.ParseFloat(label, 64); err == nil {
+			r.Value = v
+			return
+		}
+	}
+	if label != r.Kind {
+		r.Label = label
+	}
+}
+
+type audacityWriter struct {
+	w *bufio.Writer
+}
+
+// Audacity label tracks have one tab separated start, end and label
+// line per label. Instants have the same start and end.
+func (aw *audacityWriter) Write(r Record) error {
+	_, err := fmt.Fprintf(aw.w, "%s\t%s\t%s\n",
+		formatFloat(r.Time), formatFloat(r.Time+r.Duration), labelOf(r))
+	return err
+}
+
+func (aw *audacityWriter) Close() error {
+	return aw.w.Flush()
+}
+
+// ReadAudacityLabels reads an Audacity label track, as written by
+// Audacity's "Export Labels", into records of the given kind. Labels
+// of notes are read back as their pitch. Lines holding the frequency
+// range of spectral labels are skipped.
+func ReadAudacityLabels(r io.Reader, kind string) ([]Record, error) {
+	var recs []Record
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if text == "" || strings.HasPrefix(text, "\\") {
+			continue
+		}
+		fields := strings.SplitN(text, "\t", 3)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("Invalid label on line %d: %q", line, text)
+		}
+		start, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid label start on line %d: %s", line, err)
+		}
+		end, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid label end on line %d: %s", line, err)
+		}
+		rec := Record{Kind: kind, Time: start, Duration: end - start}
+		if len(fields) == 3 {
+			fromLabel(&rec, fields[2])
+		}
+		recs = append(recs, rec)
+	}
+	return recs, scanner.Err()
+}
+
+type sonicCSVWriter struct {
+	w *csv.Writer
+}
+
+// Sonic Visualiser imports "time,label" rows as time instants,
+// "time,value,duration,label" rows as regions and
+// "time,value,duration,level,label" rows as notes.
+func (sw *sonicCSVWriter) Write(r Record) error {
+	var row []string
+	switch {
+	case r.Kind == Note:
+		row = []string{formatFloat(r.Time), formatFloat(r.Value),
+			formatFloat(r.Duration), formatFloat(r.Velocity / 127), labelOf(r)}
+	case r.Duration != 0:
+		row = []string{formatFloat(r.Time), formatFloat(r.Value),
+			formatFloat(r.Duration), labelOf(r)}
+	default:
+		row = []string{formatFloat(r.Time), labelOf(r)}
+	}
+	return sw.w.Write(row)
+}
+
+func (sw *sonicCSVWriter) Close() error {
+	sw.w.Flush()
+	return sw.w.Error()
+}
+
+// ReadSonicVisualiserCSV reads a layer exported from Sonic Visualiser
+// as CSV into records of the given kind. Rows may be time instants
+// (time[,label]), time values (time,value[,label]), regions
+// (time,value,duration,label) or notes (time,value,duration,level,label).
+func ReadSonicVisualiserCSV(r io.Reader, kind string) ([]Record, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	var recs []Record
+	for line := 1; ; line++ {
+		row, err := cr.Read()
+		if err == io.EOF {
+			return recs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		nums := make([]float64, 0, len(row))
+		for _, field := range row {
+			v, err := strconv.ParseFloat(field, 64)
+			if err != nil {
+				break
+			}
+			nums = append(nums, v)
+		}
+		if len(nums) == 0 {
+			return nil, fmt.Errorf("Invalid row on line %d: %q", line, row)
+		}
+		rec := Record{Kind: kind, Time: nums[0]}
+		if len(nums) > 1 {
+			rec.Value = nums[1]
+		}
+		if len(nums) > 2 {
+			rec.Duration = nums[2]
+		}
+		if len(nums) > 3 {
+			rec.Velocity = nums[3] * 127
+		}
+		if len(nums) < len(row) {
+			// The value of a record is its label, so don't let a
+			// numeric label replace it.
+			label := row[len(nums)]
+			if len(nums) == 1 {
+				fromLabel(&rec, label)
+			} else if label != kind {
+				rec.Label = label
+			}
+		}
+		recs = append(recs, rec)
+	}
+}
@@ -0,0 +1,110 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package results
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.marzhillstudios.com/pkg/play/aubio"
+)
+
+// labelRecords returns one record of each shape label tracks handle:
+// an instant, a note, a tempo estimate and a labelled region.
+func labelRecords() []Record {
+	s := Stream{File: "a.wav", Samplerate: 8000, Hop: 256}
+	return []Record{
+		s.Onset(0.5),
+		s.Note(aubio.Note{Start: 1, End: 1.25, Pitch: 60, Velocity: 127}),
+		s.Tempo(2, 120.5, 0.5),
+		s.Chord(aubio.ChordSegment{Start: 3, End: 4.5, Chord: aubio.Chord{Root: 9, Quality: aubio.MinorChord}}),
+	}
+}
+
+func TestAudacityLabels(t *testing.T) {
+	want := "0.5\t0.5\tonset\n" +
+		"1\t1.25\t60\n" +
+		"2\t2\t120.5\n" +
+		"3\t4.5\tAm\n"
+	var b bytes.Buffer
+	if err := WriteAll(&b, Audacity, labelRecords()); err != nil {
+		t.Fatalf("Writing labels failed: %s", err)
+	}
+	if b.String() != want {
+		t.Errorf("Labels =\n%q\nwant\n%q", b.String(), want)
+	}
+}
+
+func TestReadAudacityLabels(t *testing.T) {
+	in := "0.5\t0.5\tonset\n" +
+		"1\t1.25\t60\n" +
+		// The frequency range line of a spectral label.
+		"\\\t100\t2000\n" +
+		"\r\n" +
+		"3\t4.5\n"
+	got, err := ReadAudacityLabels(strings.NewReader(in), Note)
+	if err != nil {
+		t.Fatalf("ReadAudacityLabels failed: %s", err)
+	}
+	want := []Record{
+		{Kind: Note, Time: 0.5, Label: "onset"},
+		{Kind: Note, Time: 1, Duration: 0.25, Value: 60},
+		{Kind: Note, Time: 3, Duration: 1.5},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadAudacityLabels =\n%+v\nwant\n%+v", got, want)
+	}
+
+	for _, bad := range []string{"1\n", "x\t1\tonset\n", "1\tx\tonset\n"} {
+		if _, err := ReadAudacityLabels(strings.NewReader(bad), Onset); err == nil {
+			t.Errorf("ReadAudacityLabels(%q) succeeded, want an error", bad)
+		}
+	}
+}
+
+func TestSonicVisualiserCSV(t *testing.T) {
+	want := "0.5,onset\n" +
+		"1,60,0.25,1,60\n" +
+		"2,120.5\n" +
+		"3,0,1.5,Am\n"
+	var b bytes.Buffer
+	if err := WriteAll(&b, SonicVisualiserCSV, labelRecords()); err != nil {
+		t.Fatalf("Writing Sonic Visualiser CSV failed: %s", err)
+	}
+	if b.String() != want {
+		t.Errorf("CSV =\n%q\nwant\n%q", b.String(), want)
+	}
+}
+
+func TestReadSonicVisualiserCSV(t *testing.T) {
+	in := "0.5\n" +
+		"1,60,0.25,0.5,C4\n" +
+		"2, 3.5\n" +
+		"3,0,1.5,Am\n"
+	got, err := ReadSonicVisualiserCSV(strings.NewReader(in), Note)
+	if err != nil {
+		t.Fatalf("ReadSonicVisualiserCSV failed: %s", err)
+	}
+	want := []Record{
+		{Kind: Note, Time: 0.5},
+		{Kind: Note, Time: 1, Value: 60, Duration: 0.25, Velocity: 63.5, Label: "C4"},
+		{Kind: Note, Time: 2, Value: 3.5},
+		{Kind: Note, Time: 3, Duration: 1.5, Label: "Am"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadSonicVisualiserCSV =\n%+v\nwant\n%+v", got, want)
+	}
+	if _, err := ReadSonicVisualiserCSV(strings.NewReader("onset\n"), Onset); err == nil {
+		t.Errorf("ReadSonicVisualiserCSV of a row without a time succeeded")
+	}
+}
@@ -0,0 +1,161 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+// Package results serialises aubio analysis results to JSON, CSV and
+// JSON Lines using a single, stable record schema.
+package results
+
+import (
+	"go.marzhillstudios.com/pkg/play/aubio"
+)
+
+// Kinds of Record.
+const (
+	Onset = "onset"
+	Beat  = "beat"
+	Tempo = "tempo"
+	Pitch = "pitch"
+	Note  = "note"
+	Info  = "info"
+	Chord = "chord"
+)
+
+// Record is one analysis result. Every format uses the same fields,
+// in this order. Fields that don't apply to a kind are left zero.
+type Record struct {
+	// File is the analysed file.
+	File string `json:"file"`
+	// Samplerate and Hop are the parameters used for the analysis.
+	Samplerate uint `json:"samplerate"`
+	Hop        uint `json:"hop"`
+	// Kind is what the record describes, e.g. "onset" or "mfcc".
+	Kind string `json:"kind"`
+	// Time is the time of the event or the start of the frame in seconds.
+	Time float64 `json:"time"`
+	// Duration is the length of notes and segments in seconds.
+	Duration float64 `json:"duration,omitempty"`
+	// Value is the main value: the pitch of pitch frames and notes,
+	// the bpm of a tempo estimate.
+	Value float64 `json:"value"`
+	// Confidence is the detector's confidence in Value if it has one.
+	Confidence float64 `json:"confidence"`
+	// Velocity is the MIDI velocity of a note.
+	Velocity float64 `json:"velocity,omitempty"`
+	// Label is a textual value such as a file name or a state.
+	Label string `json:"label,omitempty"`
+	// Values holds the values of feature frames such as mfcc.
+	Values []float64 `json:"values,omitempty"`
+	// Channels is the channel count of the file in "info" records.
+	Channels uint `json:"channels,omitempty"`
+}
+
+// Stream describes the analysis a set of records came from and
+// constructs records for it.
+//
+//	s := results.Stream{File: path, Samplerate: 44100, Hop: 256}
+//	w.Write(s.Onset(onset.GetLastOnset()))
+type Stream struct {
+	File       string
+	Samplerate uint
+	Hop        uint
+}
+
+func (s Stream) record(kind string, t float64) Record {
+	return Record{
+		File:       s.File,
+		Samplerate: s.Samplerate,
+		Hop:        s.Hop,
+		Kind:       kind,
+		Time:       t,
+	}
+}
+
+// Onset returns the record for an onset at t seconds.
+func (s Stream) Onset(t float64) Record {
+	return s.record(Onset, t)
+}
+
+// Beat returns the record for a beat at t seconds.
+func (s Stream) Beat(t float64) Record {
+	return s.record(Beat, t)
+}
+
+// Tempo returns the record for a tempo estimate made at t seconds.
+func (s Stream) Tempo(t, bpm, confidence float64) Record {
+	r := s.record(Tempo, t)
+	r.Value = bpm
+	r.Confidence = confidence
+	return r
+}
+
+// Pitch returns the record for a frame of pitch detection.
+func (s Stream) Pitch(f aubio.PitchFrame) Record {
+	r := s.record(Pitch, f.Time)
+	r.Value = f.Pitch
+	r.Confidence = f.Confidence
+	return r
+}
+
+// Note returns the record for a note.
+func (s Stream) Note(n aubio.Note) Record {
+	r := s.record(Note, n.Start)
+	r.Duration = n.End - n.Start
+	r.Value = n.Pitch
+	r.Velocity = n.Velocity
+	return r
+}
+
+// Chord returns the record for a chord segment. Its label is the
+// chord's name.
+func (s Stream) Chord(c aubio.ChordSegment) Record {
+	r := s.record(Chord, c.Start)
+	r.Duration = c.End - c.Start
+	r.Label = c.Chord.String()
+	r.Confidence = c.Confidence
+	return r
+}
+
+// Info returns the record describing a file of the given
+// duration in seconds.
+func (s Stream) Info(duration float64, channels uint) Record {
+	r := s.record(Info, 0)
+	r.Duration = duration
+	r.Channels = channels
+	return r
+}
+
+// Feature returns the record for a frame of a feature such as
+// "mfcc" or "melbands". values is not copied.
+func (s Stream) Feature(kind string, t float64, values []float64) Record {
+	r := s.record(kind, t)
+	r.Values = values
+	return r
+}
+
+// FromFileResult returns the records for everything in r, onsets
+// first, then beats, the tempo estimate and pitches. hop is the hop
+// size r was analysed with.
+func FromFileResult(r *aubio.FileResult, hop uint) []Record {
+	s := Stream{File: r.Path, Samplerate: r.Samplerate, Hop: hop}
+	var recs []Record
+	for _, t := range r.Onsets {
+		recs = append(recs, s.Onset(t))
+	}
+	for _, t := range r.Beats {
+		recs = append(recs, s.Beat(t))
+	}
+	if r.Bpm != 0 {
+		recs = append(recs, s.Tempo(float64(r.Frames)/float64(r.Samplerate), r.Bpm, r.Confidence))
+	}
+	for _, f := range r.Pitches {
+		recs = append(recs, s.Pitch(f))
+	}
+	return recs
+}
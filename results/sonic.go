@@ -0,0 +1,167 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package results
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+)
+
+// svFile is the subset of a Sonic Visualiser layer file (.svl) that
+// holds a single sparse model and the layer displaying it.
+type svFile struct {
+	XMLName xml.Name  `xml:"sonic-visualiser"`
+	Model   svModel   `xml:"data>model"`
+	Dataset svDataset `xml:"data>dataset"`
+	Layer   svLayer   `xml:"display>layer"`
+}
+
+type svModel struct {
+	ID          int     `xml:"id,attr"`
+	Name        string  `xml:"name,attr"`
+	SampleRate  uint    `xml:"sampleRate,attr"`
+	Start       int64   `xml:"start,attr"`
+	End         int64   `xml:"end,attr"`
+	Type        string  `xml:"type,attr"`
+	Dimensions  int     `xml:"dimensions,attr"`
+	Resolution  int     `xml:"resolution,attr"`
+	NotifyOnAdd bool    `xml:"notifyOnAdd,attr"`
+	Dataset     int     `xml:"dataset,attr"`
+	Subtype     string  `xml:"subtype,attr,omitempty"`
+	Minimum     float64 `xml:"minimum,attr,omitempty"`
+	Maximum     float64 `xml:"maximum,attr,omitempty"`
+	Units       string  `xml:"units,attr,omitempty"`
+}
+
+type svDataset struct {
+	ID         int       `xml:"id,attr"`
+	Dimensions int       `xml:"dimensions,attr"`
+	Points     []svPoint `xml:"point"`
+}
+
+type svPoint struct {
+	Frame    int64    `xml:"frame,attr"`
+	Value    *float64 `xml:"value,attr"`
+	Duration *int64   `xml:"duration,attr"`
+	Level    *float64 `xml:"level,attr"`
+	Label    string   `xml:"label,attr"`
+}
+
+type svLayer struct {
+	ID    int    `xml:"id,attr"`
+	Type  string `xml:"type,attr"`
+	Name  string `xml:"name,attr"`
+	Model int    `xml:"model,attr"`
+}
+
+// WriteSonicVisualiserXML writes recs to w as a Sonic Visualiser layer
+// file that can be loaded with "Import Annotation Layer". Notes become
+// a notes layer, records with a duration a regions layer and anything
+// else a time instants layer. samplerate converts times to the sample
+// frames Sonic Visualiser uses and should be that of the audio file.
+func WriteSonicVisualiserXML(w io.Writer, recs []Record, samplerate uint) error {
+	if samplerate == 0 {
+		return fmt.Errorf("Invalid samplerate 0 for Sonic Visualiser layer")
+	}
+	frame := func(t float64) int64 {
+		return int64(math.Floor(t*float64(samplerate) + 0.5))
+	}
+	f := svFile{
+		Model: svModel{
+			ID: 1, SampleRate: samplerate, Type: "sparse",
+			Dimensions: 1, Resolution: 1, NotifyOnAdd: true,
+		},
+		Dataset: svDataset{Dimensions: 1},
+		Layer:   svLayer{ID: 2, Type: "timeinstants", Model: 1},
+	}
+	notes, regions := len(recs) > 0, false
+	for _, r := range recs {
+		notes = notes && r.Kind == Note
+		regions = regions || r.Duration != 0
+	}
+	switch {
+	case notes:
+		f.Model.Dimensions, f.Dataset.Dimensions = 3, 3
+		f.Model.Subtype, f.Model.Units = "note", "MIDI Pitch"
+		f.Layer.Type = "notes"
+	case regions:
+		f.Model.Dimensions, f.Dataset.Dimensions = 3, 3
+		f.Layer.Type = "regions"
+	}
+	if len(recs) > 0 {
+		f.Model.Name, f.Layer.Name = recs[0].Kind, recs[0].Kind
+		f.Model.Minimum, f.Model.Maximum = recs[0].Value, recs[0].Value
+	}
+	for _, r := range recs {
+		p := svPoint{Frame: frame(r.Time), Label: labelOf(r)}
+		if end := frame(r.Time + r.Duration); end > f.Model.End {
+			f.Model.End = end
+		}
+		if f.Dataset.Dimensions == 3 {
+			value, duration := r.Value, frame(r.Time+r.Duration)-p.Frame
+			p.Value, p.Duration = &value, &duration
+			f.Model.Minimum = math.Min(f.Model.Minimum, value)
+			f.Model.Maximum = math.Max(f.Model.Maximum, value)
+		}
+		if notes {
+			level := r.Velocity / 127
+			p.Level = &level
+		}
+		f.Dataset.Points = append(f.Dataset.Points, p)
+	}
+	if _, err := io.WriteString(w, xml.Header+"<!DOCTYPE sonic-visualiser>\n"); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(f); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// ReadSonicVisualiserXML reads the first model of a Sonic Visualiser
+// layer file into records of the given kind. Times are converted from
+// sample frames using the model's sample rate. The level of notes is
+// read back as their velocity.
+func ReadSonicVisualiserXML(r io.Reader, kind string) ([]Record, error) {
+	var f svFile
+	if err := xml.NewDecoder(r).Decode(&f); err != nil {
+		return nil, err
+	}
+	sr := float64(f.Model.SampleRate)
+	if sr == 0 {
+		return nil, fmt.Errorf("Sonic Visualiser layer has no sampleRate")
+	}
+	recs := make([]Record, 0, len(f.Dataset.Points))
+	for _, p := range f.Dataset.Points {
+		rec := Record{Kind: kind, Samplerate: f.Model.SampleRate, Time: float64(p.Frame) / sr}
+		if p.Duration != nil {
+			rec.Duration = float64(*p.Duration) / sr
+		}
+		if p.Level != nil {
+			rec.Velocity = *p.Level * 127
+		}
+		if p.Value != nil {
+			rec.Value = *p.Value
+			if p.Label != kind && p.Label != formatFloat(rec.Value) {
+				rec.Label = p.Label
+			}
+		} else {
+			fromLabel(&rec, p.Label)
+		}
+		recs = append(recs, rec)
+	}
+	return recs, nil
+}
@@ -0,0 +1,113 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package results
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"go.marzhillstudios.com/pkg/play/aubio"
+)
+
+func TestSonicVisualiserXML(t *testing.T) {
+	s := Stream{File: "a.wav", Samplerate: 8000, Hop: 256}
+	tests := []struct {
+		name string
+		recs []Record
+		want string
+	}{
+		{"instants", []Record{s.Onset(0.5), s.Onset(1)}, `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE sonic-visualiser>
+<sonic-visualiser>
+  <data>
+    <model id="1" name="onset" sampleRate="8000" start="0" end="8000" type="sparse" dimensions="1" resolution="1" notifyOnAdd="true" dataset="0"></model>
+    <dataset id="0" dimensions="1">
+      <point frame="4000" label="onset"></point>
+      <point frame="8000" label="onset"></point>
+    </dataset>
+  </data>
+  <display>
+    <layer id="2" type="timeinstants" name="onset" model="1"></layer>
+  </display>
+</sonic-visualiser>
+`},
+		{"notes", []Record{
+			s.Note(aubio.Note{Start: 1, End: 1.25, Pitch: 60, Velocity: 63.5}),
+			s.Note(aubio.Note{Start: 2, End: 2.5, Pitch: 64.5, Velocity: 127}),
+		}, `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE sonic-visualiser>
+<sonic-visualiser>
+  <data>
+    <model id="1" name="note" sampleRate="8000" start="0" end="20000" type="sparse" dimensions="3" resolution="1" notifyOnAdd="true" dataset="0" subtype="note" minimum="60" maximum="64.5" units="MIDI Pitch"></model>
+    <dataset id="0" dimensions="3">
+      <point frame="8000" value="60" duration="2000" level="0.5" label="60"></point>
+      <point frame="16000" value="64.5" duration="4000" level="1" label="64.5"></point>
+    </dataset>
+  </data>
+  <display>
+    <layer id="2" type="notes" name="note" model="1"></layer>
+  </display>
+</sonic-visualiser>
+`},
+		{"regions", []Record{
+			s.Chord(aubio.ChordSegment{Start: 3, End: 4.5, Chord: aubio.Chord{Root: 9, Quality: aubio.MinorChord}}),
+		}, `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE sonic-visualiser>
+<sonic-visualiser>
+  <data>
+    <model id="1" name="chord" sampleRate="8000" start="0" end="36000" type="sparse" dimensions="3" resolution="1" notifyOnAdd="true" dataset="0"></model>
+    <dataset id="0" dimensions="3">
+      <point frame="24000" value="0" duration="12000" label="Am"></point>
+    </dataset>
+  </data>
+  <display>
+    <layer id="2" type="regions" name="chord" model="1"></layer>
+  </display>
+</sonic-visualiser>
+`},
+	}
+	for _, tc := range tests {
+		var b bytes.Buffer
+		if err := WriteSonicVisualiserXML(&b, tc.recs, 8000); err != nil {
+			t.Fatalf("%s: WriteSonicVisualiserXML failed: %s", tc.name, err)
+		}
+		if b.String() != tc.want {
+			t.Errorf("%s: layer =\n%s\nwant\n%s", tc.name, b.String(), tc.want)
+		}
+	}
+	if err := WriteSonicVisualiserXML(&bytes.Buffer{}, nil, 0); err == nil {
+		t.Errorf("WriteSonicVisualiserXML with samplerate 0 succeeded")
+	}
+}
+
+func TestSonicVisualiserXMLRoundTrip(t *testing.T) {
+	s := Stream{File: "a.wav", Samplerate: 8000, Hop: 256}
+	recs := []Record{
+		s.Note(aubio.Note{Start: 1, End: 1.25, Pitch: 60, Velocity: 63.5}),
+		s.Note(aubio.Note{Start: 2, End: 2.5, Pitch: 64.5, Velocity: 127}),
+	}
+	var b bytes.Buffer
+	if err := WriteSonicVisualiserXML(&b, recs, 8000); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadSonicVisualiserXML(&b, Note)
+	if err != nil {
+		t.Fatalf("ReadSonicVisualiserXML failed: %s", err)
+	}
+	want := []Record{
+		{Kind: Note, Samplerate: 8000, Time: 1, Duration: 0.25, Value: 60, Velocity: 63.5},
+		{Kind: Note, Samplerate: 8000, Time: 2, Duration: 0.5, Value: 64.5, Velocity: 127},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ReadSonicVisualiserXML =\n%+v\nwant\n%+v", got, want)
+	}
+}
@@ -0,0 +1,238 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package results
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+type format string
+
+const (
+	// JSON writes a single array of records.
+	JSON format = "json"
+	// JSONLines writes one record object per line.
+	JSONLines format = "jsonl"
+	// CSV writes a header row followed by one row per record. The
+	// values of feature frames are joined with semicolons.
+	CSV format = "csv"
+	// Audacity writes an Audacity label track.
+	Audacity format = "audacity"
+	// SonicVisualiserCSV writes CSV that Sonic Visualiser imports
+	// as a time instants, regions or notes layer.
+	SonicVisualiserCSV format = "svcsv"
+)
+
+// ParseFormat returns the format called name.
+func ParseFormat(name string) (format, error) {
+	switch f := format(name); f {
+	case JSON, JSONLines, CSV, Audacity, SonicVisualiserCSV:
+		return f, nil
+	}
+	return "", fmt.Errorf("Unknown results format %q", name)
+}
+
+// Columns is the CSV header row.
+var Columns = []string{
+	"file", "samplerate", "hop", "kind", "time", "duration",
+	"value", "confidence", "velocity", "label", "values", "channels",
+}
+
+// Writer writes records in one of the formats. Close must be called
+// once all records have been written to complete the output.
+type Writer interface {
+	Write(r Record) error
+	Close() error
+}
+
+// NewWriter returns a Writer encoding records to w in format f.
+// Closing the Writer does not close w.
+//
+//	rw, err := results.NewWriter(os.Stdout, results.JSONLines)
+//	if err != nil {
+//		// handle error
+//	}
+//	defer rw.Close()
+func NewWriter(w io.Writer, f format) (Writer, error) {
+	switch f {
+	case JSON:
+		return &jsonWriter{w: bufio.NewWriter(w)}, nil
+	case JSONLines:
+		bw := bufio.NewWriter(w)
+		return &jsonLinesWriter{w: bw, enc: json.NewEncoder(bw)}, nil
+	case CSV:
+		return &csvWriter{w: csv.NewWriter(w)}, nil
+	case Audacity:
+		return &audacityWriter{w: bufio.NewWriter(w)}, nil
+	case SonicVisualiserCSV:
+		return &sonicCSVWriter{w: csv.NewWriter(w)}, nil
+	}
+	return nil, fmt.Errorf("Unknown results format %q", string(f))
+}
+
+// WriteAll writes recs to w in format f.
+func WriteAll(w io.Writer, f format, recs []Record) error {
+	rw, err := NewWriter(w, f)
+	if err != nil {
+		return err
+	}
+	for _, r := range recs {
+		if err := rw.Write(r); err != nil {
+			return err
+		}
+	}
+	return rw.Close()
+}
+
+// jsonNumber is a float64 that encodes NaN and infinities, which
+// JSON can't represent, as null. Analyzers produce them for silence,
+// e.g. the loudness or level of an empty frame.
+type jsonNumber float64
+
+func (n jsonNumber) MarshalJSON() ([]byte, error) {
+	f := float64(n)
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return []byte("null"), nil
+	}
+	return json.Marshal(f)
+}
+
+// jsonRecord is the JSON encoding of a Record.
+type jsonRecord struct {
+	File       string       `json:"file"`
+	Samplerate uint         `json:"samplerate"`
+	Hop        uint         `json:"hop"`
+	Kind       string       `json:"kind"`
+	Time       jsonNumber   `json:"time"`
+	Duration   jsonNumber   `json:"duration,omitempty"`
+	Value      jsonNumber   `json:"value"`
+	Confidence jsonNumber   `json:"confidence"`
+	Velocity   jsonNumber   `json:"velocity,omitempty"`
+	Label      string       `json:"label,omitempty"`
+	Values     []jsonNumber `json:"values,omitempty"`
+	Channels   uint         `json:"channels,omitempty"`
+}
+
+// MarshalJSON encodes r with NaN and infinite values as null.
+func (r Record) MarshalJSON() ([]byte, error) {
+	jr := jsonRecord{
+		File:       r.File,
+		Samplerate: r.Samplerate,
+		Hop:        r.Hop,
+		Kind:       r.Kind,
+		Time:       jsonNumber(r.Time),
+		Duration:   jsonNumber(r.Duration),
+		Value:      jsonNumber(r.Value),
+		Confidence: jsonNumber(r.Confidence),
+		Velocity:   jsonNumber(r.Velocity),
+		Label:      r.Label,
+		Channels:   r.Channels,
+	}
+	if r.Values != nil {
+		jr.Values = make([]jsonNumber, len(r.Values))
+		for i, v := range r.Values {
+			jr.Values[i] = jsonNumber(v)
+		}
+	}
+	return json.Marshal(jr)
+}
+
+type jsonWriter struct {
+	w     *bufio.Writer
+	count int
+}
+
+func (jw *jsonWriter) Write(r Record) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	sep := ",\n"
+	if jw.count == 0 {
+		sep = "[\n"
+	}
+	jw.count++
+	jw.w.WriteString(sep)
+	_, err = jw.w.Write(b)
+	return err
+}
+
+func (jw *jsonWriter) Close() error {
+	if jw.count == 0 {
+		jw.w.WriteString("[")
+	}
+	jw.w.WriteString("\n]\n")
+	return jw.w.Flush()
+}
+
+type jsonLinesWriter struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func (jw *jsonLinesWriter) Write(r Record) error {
+	return jw.enc.Encode(r)
+}
+
+func (jw *jsonLinesWriter) Close() error {
+	return jw.w.Flush()
+}
+
+type csvWriter struct {
+	w           *csv.Writer
+	wroteHeader bool
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func (cw *csvWriter) Write(r Record) error {
+	if !cw.wroteHeader {
+		if err := cw.w.Write(Columns); err != nil {
+			return err
+		}
+		cw.wroteHeader = true
+	}
+	values := make([]string, len(r.Values))
+	for i, v := range r.Values {
+		values[i] = formatFloat(v)
+	}
+	return cw.w.Write([]string{
+		r.File,
+		strconv.FormatUint(uint64(r.Samplerate), 10),
+		strconv.FormatUint(uint64(r.Hop), 10),
+		r.Kind,
+		formatFloat(r.Time),
+		formatFloat(r.Duration),
+		formatFloat(r.Value),
+		formatFloat(r.Confidence),
+		formatFloat(r.Velocity),
+		r.Label,
+		strings.Join(values, ";"),
+		strconv.FormatUint(uint64(r.Channels), 10),
+	})
+}
+
+func (cw *csvWriter) Close() error {
+	if !cw.wroteHeader {
+		cw.w.Write(Columns)
+	}
+	cw.w.Flush()
+	return cw.w.Error()
+}
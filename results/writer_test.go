@@ -0,0 +1,70 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package results
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"testing"
+
+	"go.marzhillstudios.com/pkg/play/aubio"
+)
+
+func TestJSONNonFinite(t *testing.T) {
+	s := Stream{File: "a.wav", Samplerate: 8000, Hop: 256}
+	r := s.Feature("loudness", 0.5, []float64{math.Inf(-1), 1.5, math.NaN()})
+	r.Value = math.NaN()
+	r.Confidence = math.Inf(1)
+	recs := []Record{r, s.Onset(1)}
+
+	want := `[
+{"file":"a.wav","samplerate":8000,"hop":256,"kind":"loudness","time":0.5,"value":null,"confidence":null,"values":[null,1.5,null]},
+{"file":"a.wav","samplerate":8000,"hop":256,"kind":"onset","time":1,"value":0,"confidence":0}
+]
+`
+	var b bytes.Buffer
+	if err := WriteAll(&b, JSON, recs); err != nil {
+		t.Fatalf("Writing JSON failed: %s", err)
+	}
+	if b.String() != want {
+		t.Errorf("JSON =\n%s\nwant\n%s", b.String(), want)
+	}
+	var decoded []map[string]interface{}
+	if err := json.Unmarshal(b.Bytes(), &decoded); err != nil {
+		t.Errorf("Output is not valid JSON: %s", err)
+	}
+
+	b.Reset()
+	if err := WriteAll(&b, JSONLines, recs); err != nil {
+		t.Fatalf("Writing JSON Lines failed: %s", err)
+	}
+	for _, line := range bytes.Split(bytes.TrimSpace(b.Bytes()), []byte("\n")) {
+		if !json.Valid(line) {
+			t.Errorf("Line is not valid JSON: %s", line)
+		}
+	}
+}
+
+func TestCSV(t *testing.T) {
+	s := Stream{File: "a.wav", Samplerate: 8000, Hop: 256}
+	n := s.Note(aubio.Note{Start: 0.25, End: 0.75, Pitch: 60, Velocity: 100})
+	want := "file,samplerate,hop,kind,time,duration,value,confidence,velocity,label,values,channels\n" +
+		"a.wav,8000,256,note,0.25,0.5,60,0,100,,,0\n" +
+		"a.wav,8000,256,mfcc,1,0,0,0,0,,1;-2.5;NaN,0\n"
+	var b bytes.Buffer
+	if err := WriteAll(&b, CSV, []Record{n, s.Feature("mfcc", 1, []float64{1, -2.5, math.NaN()})}); err != nil {
+		t.Fatalf("Writing CSV failed: %s", err)
+	}
+	if b.String() != want {
+		t.Errorf("CSV =\n%s\nwant\n%s", b.String(), want)
+	}
+}
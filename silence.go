@@ -0,0 +1,176 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package aubio
+
+import (
+	"io"
+)
+
+// Region is a span of a source that is either quiet or not.
+type Region struct {
+	// Start and End are the first sample of the region and the
+	// sample after its last.
+	Start uint
+	End   uint
+	// Silent is true if the region is quiet.
+	Silent bool
+}
+
+// QuietRegions reads src to the end in blocks of hopSize samples and
+// returns the consecutive quiet and non-quiet regions covering it. A
+// block is quiet if its level is below threshold dB. Quiet regions
+// shorter than minDuration seconds are joined to the regions around
+// them, at the start and end of src as well, unless src is quiet
+// throughout. src is closed when done.
+//
+//	src, err := OpenSource(path, 0, 256)
+//	if err != nil {
+//		// handle error
+//	}
+//	regions, err := QuietRegions(src, 256, -70, 0.5)
+func QuietRegions(src AudioSource, hopSize uint, threshold, minDuration float64) ([]Region, error) {
+	p := NewSimplePipeline(src, nil, hopSize)
+	defer p.Close()
+	var regions []Region
+	pos := uint(0)
+	n := p.DoAll(func(in *SimpleBuffer) {
+		silent := SilenceDetection(in, threshold)
+		if len(regions) == 0 || regions[len(regions)-1].Silent != silent {
+			regions = append(regions, Region{Start: pos, End: pos, Silent: silent})
+		}
+		// The last block may be short. End is fixed up below.
+		pos += hopSize
+		regions[len(regions)-1].End = pos
+	})
+	if len(regions) > 0 {
+		regions[len(regions)-1].End = n
+	}
+	if err := p.Err(); err != nil {
+		return nil, err
+	}
+	return joinQuiet(regions, uint(minDuration*float64(src.Samplerate()))), nil
+}
+
+// joinQuiet merges quiet regions shorter than min samples into the
+// regions around them. A lone quiet region is left alone.
+func joinQuiet(regions []Region, min uint) []Region {
+	var out []Region
+	for _, r := range regions {
+		if r.Silent && r.End-r.Start < min && len(regions) > 1 {
+			r.Silent = false
+		}
+		if len(out) > 0 && out[len(out)-1].Silent == r.Silent {
+			out[len(out)-1].End = r.End
+			continue
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// Trim copies src to sink leaving out the quiet blocks at its start
+// and end. A block is quiet if its level is below threshold dB. As
+// with QuietRegions, quiet at the start or end shorter than
+// minDuration seconds is kept, and a src that is quiet throughout is
+// left out whatever its length. Quiet blocks inside the audio are held
+// in memory until the next block that isn't quiet, so very long
+// pauses use a lot of memory. It returns the region of src that was
+// written. src and sink are closed when done.
+//
+//	region, err := Trim(src, sink, 256, -70, 0.5)
+func Trim(src AudioSource, sink AudioSink, hopSize uint, threshold, minDuration float64) (Region, error) {
+	if c, ok := src.(io.Closer); ok {
+		defer c.Close()
+	}
+	closeSink := func() error {
+		if c, ok := sink.(io.Closer); ok {
+			return c.Close()
+		}
+		return nil
+	}
+	buf := newBuffer(hopSize)
+	defer freeBuffer(buf)
+
+	min := uint(minDuration * float64(src.Samplerate()))
+	var region Region
+	var held []float64
+	started, trimmed := false, false
+	pos := uint(0)
+	for {
+		n, rerr := src.Read(buf)
+		if n > 0 {
+			block := buf.Slice()[:n]
+			silent := SilenceDetection(buf, threshold)
+			switch {
+			case !started && silent && trimmed:
+			case !started && silent:
+				// Once the quiet start is long enough it is left out
+				// whatever follows, so it needn't be held.
+				held = append(held, block...)
+				if uint(len(held)) >= min {
+					held = held[:0]
+					trimmed = true
+				}
+			case silent:
+				held = append(held, block...)
+			default:
+				if !started {
+					started = true
+					region.Start = pos - uint(len(held))
+				}
+				held = append(held, block...)
+				if err := writeSamples(sink, buf, held); err != nil {
+					closeSink()
+					return region, err
+				}
+				held = held[:0]
+				region.End = pos + n
+			}
+			pos += n
+		}
+		if rerr == io.EOF || n == 0 {
+			break
+		}
+		if rerr != nil {
+			closeSink()
+			return region, rerr
+		}
+	}
+	if !started {
+		region.Start = pos
+		region.End = pos
+	} else if len(held) > 0 && uint(len(held)) < min {
+		if err := writeSamples(sink, buf, held); err != nil {
+			closeSink()
+			return region, err
+		}
+		region.End = pos
+	}
+	return region, closeSink()
+}
+
+// writeSamples writes samples to sink through buf, one buffer at a
+// time.
+func writeSamples(sink AudioSink, buf *SimpleBuffer, samples []float64) error {
+	size := buf.Size()
+	for len(samples) > 0 {
+		m := uint(len(samples))
+		if m > size {
+			m = size
+		}
+		buf.SetData(samples[:m])
+		if _, err := sink.Write(buf, m); err != nil {
+			return err
+		}
+		samples = samples[m:]
+	}
+	return nil
+}
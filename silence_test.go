@@ -0,0 +1,103 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package aubio
+
+import (
+	"testing"
+)
+
+// quietLoud returns lead quiet samples, loud loud samples and tail
+// quiet samples.
+func quietLoud(lead, loud, tail int) []float64 {
+	data := make([]float64, lead+loud+tail)
+	for i := lead; i < lead+loud; i++ {
+		data[i] = 0.5
+	}
+	return data
+}
+
+func TestQuietRegions(t *testing.T) {
+	// 100 samples a second, 10 to a block. The 0.2s pauses at the
+	// start and inside are joined to the audio after them, as Trim
+	// keeps them. The 0.4s one at the end is not.
+	data := quietLoud(20, 30, 0)
+	data = append(data, quietLoud(20, 30, 40)...)
+	regions, err := QuietRegions(NewMemorySource(data, 100, 10), 10, -70, 0.3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Region{{0, 100, false}, {100, 140, true}}
+	if len(regions) != len(want) {
+		t.Fatalf("QuietRegions = %v, want %v", regions, want)
+	}
+	for i := range want {
+		if regions[i] != want[i] {
+			t.Errorf("Region %d = %v, want %v", i, regions[i], want[i])
+		}
+	}
+}
+
+func TestQuietRegionsAllQuiet(t *testing.T) {
+	// Like Trim, a short source quiet throughout is all quiet.
+	regions, err := QuietRegions(NewMemorySource(make([]float64, 45), 100, 10), 10, -70, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Region{0, 45, true}); len(regions) != 1 || regions[0] != want {
+		t.Errorf("QuietRegions = %v, want [%v]", regions, want)
+	}
+}
+
+func TestTrim(t *testing.T) {
+	tests := []struct {
+		name        string
+		lead, tail  int
+		minDuration float64
+		want        Region
+	}{
+		{"no minimum", 40, 30, 0, Region{40, 90, false}},
+		{"long edges", 40, 30, 0.3, Region{40, 90, false}},
+		{"short start", 20, 30, 0.3, Region{0, 70, false}},
+		{"short end", 40, 20, 0.3, Region{40, 110, false}},
+		{"short edges", 20, 20, 0.3, Region{0, 90, false}},
+	}
+	for _, tc := range tests {
+		data := quietLoud(tc.lead, 50, tc.tail)
+		sink := NewMemorySink(100)
+		region, err := Trim(NewMemorySource(data, 100, 10), sink, 10, -70, tc.minDuration)
+		if err != nil {
+			t.Fatalf("%s: Trim failed: %s", tc.name, err)
+		}
+		if region != tc.want {
+			t.Errorf("%s: Trim = %v, want %v", tc.name, region, tc.want)
+		}
+		got := sink.Data()
+		if len(got) != int(tc.want.End-tc.want.Start) {
+			t.Fatalf("%s: wrote %d samples, want %d", tc.name, len(got), tc.want.End-tc.want.Start)
+		}
+		for i, v := range got {
+			if v != data[int(tc.want.Start)+i] {
+				t.Fatalf("%s: sample %d = %g, want %g", tc.name, i, v, data[int(tc.want.Start)+i])
+			}
+		}
+	}
+}
+
+func TestTrimAllQuiet(t *testing.T) {
+	sink := NewMemorySink(100)
+	region, err := Trim(NewMemorySource(make([]float64, 45), 100, 10), sink, 10, -70, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if region.Start != region.End || len(sink.Data()) != 0 {
+		t.Errorf("Trim = %v writing %d samples, want nothing", region, len(sink.Data()))
+	}
+}
@@ -0,0 +1,155 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package aubio
+
+import (
+	"fmt"
+	"io"
+)
+
+// writeLen clamps n to the size of buf.
+func writeLen(buf *SimpleBuffer, n uint) uint {
+	if n > buf.Size() {
+		return buf.Size()
+	}
+	return n
+}
+
+// MemorySink is an AudioSink that records everything written to it.
+type MemorySink struct {
+	data       []float64
+	samplerate uint
+}
+
+// NewMemorySink constructs an empty MemorySink.
+//
+//	s := NewMemorySink(44100)
+//	p := NewSimplePipeline(src, s, 256)
+//	p.DoAll()
+//	recorded := s.Data()
+func NewMemorySink(samplerate uint) *MemorySink {
+	return &MemorySink{samplerate: samplerate}
+}
+
+// Write appends n samples from buf to the recording. Like every sink
+// in this package it returns an error rather than panicking when
+// called on a nil sink, such as a typed nil passed as an AudioSink.
+func (s *MemorySink) Write(buf *SimpleBuffer, n uint) (uint, error) {
+	if s == nil {
+		return 0, errNilSink
+	}
+	n = writeLen(buf, n)
+	s.data = append(s.data, buf.Slice()[:n]...)
+	return n, nil
+}
+
+// Data returns the recorded samples. The slice is owned by the
+// MemorySink and is only valid until the next Write or Reset.
+func (s *MemorySink) Data() []float64 {
+	return s.data
+}
+
+// Reset discards the recorded samples.
+func (s *MemorySink) Reset() {
+	s.data = s.data[:0]
+}
+
+// Samplerate returns the samplerate for this MemorySink.
+func (s *MemorySink) Samplerate() uint {
+	return s.samplerate
+}
+
+// WriterSink is an AudioSink that encodes samples as raw interleaved
+// PCM to an io.Writer. The mono input is copied to every channel.
+type WriterSink struct {
+	w          io.Writer
+	format     pcmFormat
+	channels   uint
+	samplerate uint
+	raw        []byte
+}
+
+// NewWriterSink constructs a WriterSink encoding to w in the given format.
+//
+//	s, err := NewWriterSink(os.Stdout, PCMS16LE, 1, 44100)
+//	if err != nil {
+//		// handle error
+//	}
+func NewWriterSink(w io.Writer, format pcmFormat, channels, samplerate uint) (*WriterSink, error) {
+	if err := format.check(); err != nil {
+		return nil, err
+	}
+	if channels == 0 {
+		return nil, fmt.Errorf("Invalid channel count %d", channels)
+	}
+	return &WriterSink{
+		w:          w,
+		format:     format,
+		channels:   channels,
+		samplerate: samplerate,
+	}, nil
+}
+
+// Write encodes n samples from buf to the underlying io.Writer.
+// On a short write the returned count only includes whole frames.
+func (s *WriterSink) Write(buf *SimpleBuffer, n uint) (uint, error) {
+	if s == nil {
+		return 0, errNilSink
+	}
+	n = writeLen(buf, n)
+	ss := s.format.sampleSize()
+	frameSize := s.channels * ss
+	if need := n * frameSize; uint(cap(s.raw)) < need {
+		s.raw = make([]byte, need)
+	}
+	raw := s.raw[:n*frameSize]
+	for i, v := range buf.Slice()[:n] {
+		frame := raw[uint(i)*frameSize:]
+		for c := uint(0); c < s.channels; c++ {
+			s.format.encode(frame[c*ss:], v)
+		}
+	}
+	m, err := s.w.Write(raw)
+	return uint(m) / frameSize, err
+}
+
+// Samplerate returns the samplerate for this WriterSink.
+func (s *WriterSink) Samplerate() uint {
+	return s.samplerate
+}
+
+// Channels returns the number of channels written per frame.
+func (s *WriterSink) Channels() uint {
+	return s.channels
+}
+
+// DiscardSink is an AudioSink that throws away everything written to it.
+type DiscardSink struct {
+	samplerate uint
+}
+
+// NewDiscardSink constructs a DiscardSink.
+func NewDiscardSink(samplerate uint) *DiscardSink {
+	return &DiscardSink{samplerate: samplerate}
+}
+
+// Write reports n samples as written without doing anything.
+func (s *DiscardSink) Write(buf *SimpleBuffer, n uint) (uint, error) {
+	if s == nil {
+		return 0, errNilSink
+	}
+	return writeLen(buf, n), nil
+}
+
+// Samplerate returns the samplerate for this DiscardSink.
+func (s *DiscardSink) Samplerate() uint {
+	return s.samplerate
+}
@@ -0,0 +1,242 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package aubio
+
+import (
+	"fmt"
+	"io"
+)
+
+// blockLen returns how many samples a source with the given
+// blockSize should read into buf.
+func blockLen(blockSize uint, buf *SimpleBuffer) uint {
+	if buf.Size() < blockSize {
+		return buf.Size()
+	}
+	return blockSize
+}
+
+// MemorySource is an AudioSource that reads from a slice of samples.
+type MemorySource struct {
+	data       []float64
+	pos        uint
+	samplerate uint
+	blockSize  uint
+}
+
+// NewMemorySource constructs a MemorySource reading blockSize
+// samples at a time from data. The data is not copied.
+//
+//	s := NewMemorySource(samples, 44100, 256)
+//	p := NewSimplePipeline(s, nil, 256)
+func NewMemorySource(data []float64, samplerate, blockSize uint) *MemorySource {
+	return &MemorySource{
+		data:       data,
+		samplerate: samplerate,
+		blockSize:  blockSize,
+	}
+}
+
+// Read copies the next block of samples into buf.
+func (s *MemorySource) Read(buf *SimpleBuffer) (uint, error) {
+	want := blockLen(s.blockSize, buf)
+	n := uint(len(s.data)) - s.pos
+	if n > want {
+		n = want
+	}
+	buf.SetData(s.data[s.pos : s.pos+n])
+	s.pos += n
+	if n < want || n == 0 {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Reset rewinds the MemorySource to the first sample.
+func (s *MemorySource) Reset() {
+	s.pos = 0
+}
+
+// BlockSize returns the blockSize used by this MemorySource.
+func (s *MemorySource) BlockSize() uint {
+	return s.blockSize
+}
+
+// Samplerate returns the samplerate of this MemorySource.
+func (s *MemorySource) Samplerate() uint {
+	return s.samplerate
+}
+
+// Channels always returns 1.
+func (s *MemorySource) Channels() uint {
+	return 1
+}
+
+// GeneratorSource is an AudioSource that computes its samples
+// with a generator function.
+type GeneratorSource struct {
+	gen        func(i uint) float64
+	length     uint
+	pos        uint
+	samplerate uint
+	blockSize  uint
+}
+
+// NewGeneratorSource constructs a GeneratorSource that produces
+// gen(i) as sample i. It ends after length samples or never if
+// length is 0.
+//
+//	sine := func(i uint) float64 {
+//		return math.Sin(2 * math.Pi * 440 * float64(i) / 44100)
+//	}
+//	s := NewGeneratorSource(sine, 44100*5, 44100, 256)
+func NewGeneratorSource(gen func(i uint) float64, length, samplerate, blockSize uint) *GeneratorSource {
+	return &GeneratorSource{
+		gen:        gen,
+		length:     length,
+		samplerate: samplerate,
+		blockSize:  blockSize,
+	}
+}
+
+// Read generates the next block of samples into buf.
+func (s *GeneratorSource) Read(buf *SimpleBuffer) (uint, error) {
+	want := blockLen(s.blockSize, buf)
+	n := want
+	if s.length > 0 && s.length-s.pos < n {
+		n = s.length - s.pos
+	}
+	block := make([]float64, n)
+	for i := range block {
+		block[i] = s.gen(s.pos + uint(i))
+	}
+	buf.SetData(block)
+	s.pos += n
+	if n < want || n == 0 {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// BlockSize returns the blockSize used by this GeneratorSource.
+func (s *GeneratorSource) BlockSize() uint {
+	return s.blockSize
+}
+
+// Samplerate returns the samplerate of this GeneratorSource.
+func (s *GeneratorSource) Samplerate() uint {
+	return s.samplerate
+}
+
+// Channels always returns 1.
+func (s *GeneratorSource) Channels() uint {
+	return 1
+}
+
+// ReaderSource is an AudioSource that decodes raw interleaved PCM
+// from an io.Reader. Multichannel streams are mixed down to mono by
+// averaging the channels.
+type ReaderSource struct {
+	r          io.Reader
+	format     pcmFormat
+	channels   uint
+	samplerate uint
+	blockSize  uint
+	raw        []byte
+	block      []float64
+}
+
+// NewReaderSource constructs a ReaderSource reading blockSize frames
+// at a time of the given format from r.
+//
+//	s, err := NewReaderSource(conn, PCMS16LE, 2, 44100, 256)
+//	if err != nil {
+//		// handle error
+//	}
+func NewReaderSource(r io.Reader, format pcmFormat, channels, samplerate, blockSize uint) (*ReaderSource, error) {
+	if err := format.check(); err != nil {
+		return nil, err
+	}
+	if channels == 0 {
+		return nil, fmt.Errorf("Invalid channel count %d", channels)
+	}
+	return &ReaderSource{
+		r:          r,
+		format:     format,
+		channels:   channels,
+		samplerate: samplerate,
+		blockSize:  blockSize,
+		raw:        make([]byte, blockSize*channels*format.sampleSize()),
+		block:      make([]float64, blockSize),
+	}, nil
+}
+
+// Read decodes the next block of frames into buf.
+// Trailing bytes that do not make up a whole frame are dropped.
+func (s *ReaderSource) Read(buf *SimpleBuffer) (uint, error) {
+	ss := s.format.sampleSize()
+	frameSize := s.channels * ss
+	n, err := s.readFrames(blockLen(s.blockSize, buf))
+	for i := uint(0); i < n; i++ {
+		frame := s.raw[i*frameSize:]
+		v := 0.0
+		for c := uint(0); c < s.channels; c++ {
+			v += s.format.decode(frame[c*ss:])
+		}
+		s.block[i] = v / float64(s.channels)
+	}
+	buf.SetData(s.block[:n])
+	return n, err
+}
+
+// ReadChannels decodes the next block of frames into bufs, one
+// buffer per channel.
+func (s *ReaderSource) ReadChannels(bufs []*SimpleBuffer) (uint, error) {
+	if uint(len(bufs)) != s.channels {
+		return 0, fmt.Errorf("Got %d buffers for %d channels", len(bufs), s.channels)
+	}
+	ss := s.format.sampleSize()
+	frameSize := s.channels * ss
+	n, err := s.readFrames(blockLen(s.blockSize, bufs[0]))
+	for c, buf := range bufs {
+		for i := uint(0); i < n; i++ {
+			s.block[i] = s.format.decode(s.raw[i*frameSize+uint(c)*ss:])
+		}
+		buf.SetData(s.block[:n])
+	}
+	return n, err
+}
+
+// readFrames reads up to want whole frames into raw and returns the
+// number read.
+func (s *ReaderSource) readFrames(want uint) (uint, error) {
+	frameSize := s.channels * s.format.sampleSize()
+	m, err := io.ReadFull(s.r, s.raw[:want*frameSize])
+	if err == io.ErrUnexpectedEOF {
+		err = io.EOF
+	}
+	return uint(m) / frameSize, err
+}
+
+// BlockSize returns the blockSize used by this ReaderSource.
+func (s *ReaderSource) BlockSize() uint {
+	return s.blockSize
+}
+
+// Samplerate returns the samplerate of this ReaderSource.
+func (s *ReaderSource) Samplerate() uint {
+	return s.samplerate
+}
+
+// Channels returns the number of interleaved channels in the stream.
+func (s *ReaderSource) Channels() uint {
+	return s.channels
+}
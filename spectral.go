@@ -17,7 +17,9 @@ package aubio
 import "C"
 
 import (
+	"fmt"
 	"log"
+	"runtime"
 )
 
 // fft
@@ -29,15 +31,47 @@ type FilterBank struct {
 }
 
 func NewFilterBank(filters uint, win_s uint) *FilterBank {
-	return  &FilterBank {
-		o: C.new_aubio_filterbank(C.uint_t(filters), C.uint_t(win_s)),
+	fb := &FilterBank{
+		o:   C.new_aubio_filterbank(C.uint_t(filters), C.uint_t(win_s)),
 		buf: NewSimpleBuffer(filters),
 	}
+	manage(fb, (*FilterBank).finalize)
+	return fb
+}
+
+// Free frees the aubio_filterbank_t object's memory and its output buffer.
+// It is safe to call Free more than once.
+func (fb *FilterBank) Free() {
+	unmanage(fb)
+	if fb.o != nil {
+		C.del_aubio_filterbank(fb.o)
+		fb.o = nil
+	}
+	if fb.buf != nil {
+		fb.buf.Free()
+		fb.buf = nil
+	}
+}
+
+// Close frees the FilterBank. It always returns nil.
+func (fb *FilterBank) Close() error {
+	fb.Free()
+	return nil
+}
+
+func (fb *FilterBank) finalize() {
+	leaked(fb)
+	if fb.o != nil {
+		C.del_aubio_filterbank(fb.o)
+		fb.o = nil
+	}
 }
 
 func (fb *FilterBank) Do(in *ComplexBuffer) {
 	if fb.o != nil {
 		C.aubio_filterbank_do(fb.o, in.data, fb.buf.vec)
+		runtime.KeepAlive(fb)
+		runtime.KeepAlive(in)
 	} else {
 		log.Println("Called Do on empty FilterBank. Maybe you called Free previously?")
 	}
@@ -47,6 +81,20 @@ func (fb *FilterBank) SetMelCoeffsSlaney(sample uint) {
 	C.aubio_filterbank_set_mel_coeffs_slaney(fb.o, C.smpl_t(sample))
 }
 
+// SetTriangleBands sets the filters to overlapping triangles. Filter i
+// rises from freqs[i] to a peak at freqs[i+1] and falls back to zero
+// at freqs[i+2], all in Hz, so freqs must hold two more frequencies
+// than there are filters.
+func (fb *FilterBank) SetTriangleBands(freqs []float64, samplerate uint) error {
+	v := NewSimpleBufferData(uint(len(freqs)), freqs)
+	defer v.Free()
+	if C.aubio_filterbank_set_triangle_bands(fb.o, v.vec, C.smpl_t(samplerate)) != 0 {
+		return fmt.Errorf("Failure setting %d triangle bands", len(freqs))
+	}
+	runtime.KeepAlive(fb)
+	return nil
+}
+
 func (fb *FilterBank) Buffer() *SimpleBuffer {
 	return fb.buf
 }
@@ -54,6 +102,78 @@ func (fb *FilterBank) Buffer() *SimpleBuffer {
 
 // mfcc
 
+// MFCC is a wrapper for the aubio_mfcc_t object. It computes Mel
+// frequency cepstrum coefficients from the output of a PhaseVoc.
+type MFCC struct {
+	o   *C.aubio_mfcc_t
+	buf *SimpleBuffer
+}
+
+// NewMFCC constructs an MFCC object computing coeffs coefficients
+// from filters mel bands of a bufSize window.
+// It is the Callers responsibility to call Free on the returned
+// MFCC object or leak memory.
+//
+//	m, err := NewMFCC(bufSize, 40, 13, samplerate)
+//	if err != nil {
+//		// handle error
+//	}
+//	defer m.Free()
+func NewMFCC(bufSize, filters, coeffs, samplerate uint) (*MFCC, error) {
+	o, err := C.new_aubio_mfcc(C.uint_t(bufSize), C.uint_t(filters),
+		C.uint_t(coeffs), C.uint_t(samplerate))
+	if o == nil {
+		return nil, fmt.Errorf("Failure creating MFCC object %q", err)
+	}
+	m := &MFCC{o: o, buf: NewSimpleBuffer(coeffs)}
+	manage(m, (*MFCC).finalize)
+	return m, nil
+}
+
+// Do computes the coefficients of a spectrum into the output Buffer.
+func (m *MFCC) Do(in *ComplexBuffer) {
+	if m.o != nil {
+		C.aubio_mfcc_do(m.o, in.data, m.buf.vec)
+		runtime.KeepAlive(m)
+		runtime.KeepAlive(in)
+	} else {
+		log.Println("Called Do on empty MFCC. Maybe you called Free previously?")
+	}
+}
+
+// Buffer returns the output buffer holding the coefficients.
+func (m *MFCC) Buffer() *SimpleBuffer {
+	return m.buf
+}
+
+// Free frees the aubio_mfcc_t object's memory and its output buffer.
+// It is safe to call Free more than once.
+func (m *MFCC) Free() {
+	unmanage(m)
+	if m.o != nil {
+		C.del_aubio_mfcc(m.o)
+		m.o = nil
+	}
+	if m.buf != nil {
+		m.buf.Free()
+		m.buf = nil
+	}
+}
+
+// Close frees the MFCC object. It always returns nil.
+func (m *MFCC) Close() error {
+	m.Free()
+	return nil
+}
+
+func (m *MFCC) finalize() {
+	leaked(m)
+	if m.o != nil {
+		C.del_aubio_mfcc(m.o)
+		m.o = nil
+	}
+}
+
 // phasvoc
 
 type PhaseVoc struct {
@@ -64,15 +184,20 @@ type PhaseVoc struct {
 
 func NewPhaseVoc(bufSize, fftLen uint) (*PhaseVoc, error) {
 	pvoc, err := C.new_aubio_pvoc(C.uint_t(bufSize), C.uint_t(fftLen))
-	if err != nil {
-		return nil, err
+	if pvoc == nil {
+		return nil, fmt.Errorf("Failure creating PhaseVoc object %q", err)
 	}
-	return &PhaseVoc{
-		o: pvoc,
-		grain: NewComplexBuffer(bufSize)}, nil
+	pv := &PhaseVoc{
+		o:     pvoc,
+		grain: NewComplexBuffer(bufSize)}
+	manage(pv, (*PhaseVoc).finalize)
+	return pv, nil
 }
 
+// Free frees the aubio_pvoc_t object's memory and its grain.
+// It is safe to call Free more than once.
 func (pv *PhaseVoc) Free() {
+	unmanage(pv)
 	if pv.o != nil {
 		C.del_aubio_pvoc(pv.o)
 		pv.o = nil
@@ -83,14 +208,30 @@ func (pv *PhaseVoc) Free() {
 	}
 }
 
+// Close frees the PhaseVoc. It always returns nil.
+func (pv *PhaseVoc) Close() error {
+	pv.Free()
+	return nil
+}
+
+func (pv *PhaseVoc) finalize() {
+	leaked(pv)
+	if pv.o != nil {
+		C.del_aubio_pvoc(pv.o)
+		pv.o = nil
+	}
+}
+
 func (pv *PhaseVoc) Grain() *ComplexBuffer {
 	return pv.grain
 }
 
 
 func (pv *PhaseVoc) Do(in *SimpleBuffer) {
-	if pv != nil || pv.o != nil {
+	if pv.o != nil {
 		C.aubio_pvoc_do(pv.o, in.vec, pv.grain.data)
+		runtime.KeepAlive(pv)
+		runtime.KeepAlive(in)
 	} else {
 		log.Println("Called Do on empty PhaseVoc. Maybe you called Free previously?")
 	}
@@ -99,6 +240,8 @@ func (pv *PhaseVoc) Do(in *SimpleBuffer) {
 func (pv *PhaseVoc) ReverseDo(out *SimpleBuffer) {
 	if pv.o != nil {
 		C.aubio_pvoc_rdo(pv.o, pv.grain.data, out.vec)
+		runtime.KeepAlive(pv)
+		runtime.KeepAlive(out)
 	} else {
 		log.Println("Called ReverseDo on empty PhaseVoc. Maybe you called Free previously?")
 	}
@@ -0,0 +1,237 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package aubio
+
+import (
+	"fmt"
+	"math"
+)
+
+type spectrumScale string
+
+const (
+	// Frequency scales of a Spectrogram.
+	// One row per PhaseVoc bin, evenly spaced in Hz
+	LinearScale spectrumScale = "linear"
+	// Triangular bands evenly spaced in log frequency
+	LogScale spectrumScale = "log"
+	// aubio's 40 Slaney mel bands
+	MelScale spectrumScale = "mel"
+)
+
+// ParseSpectrumScale returns the frequency scale called name.
+func ParseSpectrumScale(name string) (spectrumScale, error) {
+	switch s := spectrumScale(name); s {
+	case LinearScale, LogScale, MelScale:
+		return s, nil
+	}
+	return "", fmt.Errorf("Unknown spectrum scale %q", name)
+}
+
+// melBands is the number of bands aubio's Slaney mel coefficients
+// need.
+const melBands = 40
+
+// SpectrogramConfig configures a Spectrogram.
+type SpectrogramConfig struct {
+	// BufSize is the analysis window size. Defaults to 1024.
+	BufSize uint
+	// HopSize is the number of samples between frames. Defaults to 256.
+	HopSize uint
+	// Scale is the frequency scale of the rows. Defaults to LinearScale.
+	Scale spectrumScale
+	// Bands is the number of rows of a LogScale spectrogram. Defaults
+	// to 96. MelScale always has 40.
+	Bands uint
+	// MinFreq and MaxFreq bound the rows of a LogScale spectrogram
+	// in Hz. They default to 50Hz and the Nyquist frequency.
+	MinFreq float64
+	MaxFreq float64
+}
+
+func (c *SpectrogramConfig) defaults(samplerate uint) {
+	if c.BufSize == 0 {
+		c.BufSize = 1024
+	}
+	if c.HopSize == 0 {
+		c.HopSize = 256
+	}
+	if c.Scale == "" {
+		c.Scale = LinearScale
+	}
+	if c.Bands == 0 {
+		c.Bands = 96
+	}
+	if c.MinFreq == 0 {
+		c.MinFreq = 50
+	}
+	if c.MaxFreq == 0 {
+		c.MaxFreq = float64(samplerate) / 2
+	}
+}
+
+// Spectrogram collects the magnitude spectrum of every block it is
+// given into a time by frequency matrix.
+type Spectrogram struct {
+	// Samplerate and HopSize give the time of each frame: frame i
+	// starts at i*HopSize/Samplerate seconds.
+	Samplerate uint
+	HopSize    uint
+	Scale      spectrumScale
+	// Freqs holds the centre frequency in Hz of each row, lowest
+	// first.
+	Freqs []float64
+	// Frames holds one magnitude per row for every block.
+	Frames [][]float64
+
+	pv *PhaseVoc
+	fb *FilterBank
+}
+
+// NewSpectrogram constructs a Spectrogram for audio at samplerate.
+// It is the Callers responsibility to call Free on the returned
+// Spectrogram once every block has been given to Do.
+//
+//	s, err := NewSpectrogram(SpectrogramConfig{Scale: MelScale}, samplerate)
+//	if err != nil {
+//		// handle error
+//	}
+//	defer s.Free()
+//	p.DoAll(s.Do)
+func NewSpectrogram(config SpectrogramConfig, samplerate uint) (*Spectrogram, error) {
+	config.defaults(samplerate)
+	pv, err := NewPhaseVoc(config.BufSize, config.HopSize)
+	if err != nil {
+		return nil, err
+	}
+	s := &Spectrogram{
+		Samplerate: samplerate,
+		HopSize:    config.HopSize,
+		Scale:      config.Scale,
+		pv:         pv,
+	}
+	switch config.Scale {
+	case LinearScale:
+		s.Freqs = make([]float64, config.BufSize/2+1)
+		for i := range s.Freqs {
+			s.Freqs[i] = float64(i) * float64(samplerate) / float64(config.BufSize)
+		}
+	case LogScale:
+		if config.MinFreq >= config.MaxFreq {
+			pv.Free()
+			return nil, fmt.Errorf("Invalid frequency range %g to %g", config.MinFreq, config.MaxFreq)
+		}
+		edges := make([]float64, config.Bands+2)
+		ratio := math.Log(config.MaxFreq / config.MinFreq)
+		for i := range edges {
+			edges[i] = config.MinFreq * math.Exp(ratio*float64(i)/float64(len(edges)-1))
+		}
+		s.fb = NewFilterBank(config.Bands, config.BufSize)
+		if err := s.fb.SetTriangleBands(edges, samplerate); err != nil {
+			s.Free()
+			return nil, err
+		}
+		s.Freqs = edges[1 : len(edges)-1]
+	case MelScale:
+		s.fb = NewFilterBank(melBands, config.BufSize)
+		s.fb.SetMelCoeffsSlaney(samplerate)
+		s.Freqs = slaneyCentres()
+	default:
+		pv.Free()
+		return nil, fmt.Errorf("Unknown spectrum scale %q", string(config.Scale))
+	}
+	return s, nil
+}
+
+// slaneyCentres returns the peak frequencies of the Slaney mel bands:
+// 66.67Hz apart starting at 200Hz, then a constant ratio apart above
+// 1kHz.
+func slaneyCentres() []float64 {
+	const (
+		lowest    = 133.33333
+		linear    = 66.66667
+		logFactor = 1.0711703
+		linearN   = 13
+	)
+	freqs := make([]float64, melBands)
+	for i := range freqs {
+		if i+1 < linearN {
+			freqs[i] = lowest + linear*float64(i+1)
+		} else {
+			freqs[i] = freqs[i-1] * logFactor
+		}
+	}
+	return freqs
+}
+
+// Do appends the spectrum of the next block to Frames.
+func (s *Spectrogram) Do(in *SimpleBuffer) {
+	s.pv.Do(in)
+	if s.fb == nil {
+		s.Frames = append(s.Frames, s.pv.Grain().Norm())
+		return
+	}
+	s.fb.Do(s.pv.Grain())
+	s.Frames = append(s.Frames, s.fb.Buffer().Slice())
+}
+
+// Time returns the start of frame i in seconds.
+func (s *Spectrogram) Time(i int) float64 {
+	return float64(i) * float64(s.HopSize) / float64(s.Samplerate)
+}
+
+// Row returns the fractional row at freq Hz, interpolating between the
+// centres of the rows around it. Frequencies outside the rows give
+// -1.
+func (s *Spectrogram) Row(freq float64) float64 {
+	for i := 1; i < len(s.Freqs); i++ {
+		lo, hi := s.Freqs[i-1], s.Freqs[i]
+		if freq >= lo && freq <= hi {
+			return float64(i-1) + (freq-lo)/(hi-lo)
+		}
+	}
+	return -1
+}
+
+// Free frees the PhaseVoc and FilterBank used by Do. Frames remain
+// valid. It is safe to call Free more than once.
+func (s *Spectrogram) Free() {
+	if s.pv != nil {
+		s.pv.Free()
+		s.pv = nil
+	}
+	if s.fb != nil {
+		s.fb.Free()
+		s.fb = nil
+	}
+}
+
+// Close frees the Spectrogram. It always returns nil.
+func (s *Spectrogram) Close() error {
+	s.Free()
+	return nil
+}
+
+// ComputeSpectrogram reads src to the end and returns its spectrogram.
+// src is closed when done.
+//
+//	s, err := ComputeSpectrogram(src, SpectrogramConfig{Scale: LogScale})
+func ComputeSpectrogram(src AudioSource, config SpectrogramConfig) (*Spectrogram, error) {
+	s, err := NewSpectrogram(config, src.Samplerate())
+	if err != nil {
+		return nil, err
+	}
+	defer s.Free()
+	p := NewSimplePipeline(src, nil, s.HopSize)
+	defer p.Close()
+	p.DoAll(s.Do)
+	return s, p.Err()
+}
@@ -0,0 +1,77 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package aubio
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSpectrogramRow(t *testing.T) {
+	s := &Spectrogram{Freqs: []float64{100, 200, 400}}
+	for freq, want := range map[float64]float64{
+		100: 0,
+		150: 0.5,
+		200: 1,
+		300: 1.5,
+		400: 2,
+		99:  -1,
+		401: -1,
+	} {
+		if got := s.Row(freq); math.Abs(got-want) > 1e-12 {
+			t.Errorf("Row(%g) = %g, want %g", freq, got, want)
+		}
+	}
+	if got := (&Spectrogram{Freqs: []float64{100}}).Row(100); got != -1 {
+		t.Errorf("Row with a single row = %g, want -1", got)
+	}
+}
+
+func TestSpectrogramSinePeak(t *testing.T) {
+	// 1kHz is bin 128 of a 1024 sample window at 8kHz.
+	const samplerate, freq = 8000, 1000
+	data := make([]float64, 4096)
+	for i := range data {
+		data[i] = math.Sin(2 * math.Pi * freq * float64(i) / samplerate)
+	}
+	for _, config := range []SpectrogramConfig{
+		{Scale: LinearScale},
+		{Scale: LogScale, Bands: 48, MinFreq: 100, MaxFreq: 4000},
+		{Scale: MelScale},
+	} {
+		s, err := ComputeSpectrogram(NewMemorySource(data, samplerate, 256), config)
+		if err != nil {
+			t.Fatalf("%s: %s", config.Scale, err)
+		}
+		if len(s.Frames) != 16 {
+			t.Fatalf("%s: %d frames, want 16", config.Scale, len(s.Frames))
+		}
+		want := s.Row(freq)
+		if config.Scale == LinearScale && want != 128 {
+			t.Errorf("%s: Row(%d) = %g, want 128", config.Scale, freq, want)
+		}
+		// Once the window is full, the loudest row is the one nearest
+		// the sine.
+		frame := s.Frames[len(s.Frames)-1]
+		if len(frame) != len(s.Freqs) {
+			t.Fatalf("%s: %d values per frame for %d rows", config.Scale, len(frame), len(s.Freqs))
+		}
+		peak := 0
+		for i, v := range frame {
+			if v > frame[peak] {
+				peak = i
+			}
+		}
+		if math.Abs(float64(peak)-want) > 0.5 {
+			t.Errorf("%s: peak at row %d (%gHz), want row %g", config.Scale, peak, s.Freqs[peak], want)
+		}
+	}
+}
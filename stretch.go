@@ -0,0 +1,388 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package aubio
+
+import (
+	"fmt"
+	"io"
+	"math"
+)
+
+// sourceBlockSize returns the number of samples src reads at a time,
+// or def if it doesn't say.
+func sourceBlockSize(src AudioSource, def uint) uint {
+	if b, ok := src.(interface {
+		BlockSize() uint
+	}); ok && b.BlockSize() > 0 {
+		return b.BlockSize()
+	}
+	return def
+}
+
+// TimeStretch is an AudioSource that changes the speed of another
+// source without changing its pitch. It analyses the source with one
+// PhaseVoc and resynthesises it with another at a different hop size,
+// advancing the phase of each spectral peak at its measured frequency
+// and locking the bins around each peak to it.
+//
+// The analysis hop is rounded to a whole number of samples so the
+// rate actually used, returned by Rate, can differ slightly from the
+// one requested.
+type TimeStretch struct {
+	src       AudioSource
+	ana       *PhaseVoc
+	syn       *PhaseVoc
+	in        *SimpleBuffer
+	frame     *SimpleBuffer
+	out       *SimpleBuffer
+	winSize   uint
+	anaHop    uint
+	synHop    uint
+	lastPhase []float64
+	synPhase  []float64
+	started   bool
+	queue     []float64
+	pending   []float64
+	read      uint
+	produced  uint
+	skip      uint
+	srcDone   bool
+	done      bool
+	err       error
+	blockSize uint
+}
+
+// NewTimeStretch constructs a TimeStretch reading from src. Rates
+// above 1 speed the audio up and rates below 1 slow it down. bufSize
+// is the window size of the phase vocoders and hopSize both their
+// synthesis hop and the number of samples returned by each Read.
+// Closing the TimeStretch closes src.
+//
+//	ts, err := NewTimeStretch(src, 0.5, 2048, 512)
+//	if err != nil {
+//		// handle error
+//	}
+//	p := NewSimplePipeline(ts, sink, 512)
+//	defer p.Close()
+//	p.DoAll()
+func NewTimeStretch(src AudioSource, rate float64, bufSize, hopSize uint) (*TimeStretch, error) {
+	if hopSize == 0 || bufSize < hopSize {
+		return nil, fmt.Errorf("Invalid hop size %d for buffer size %d", hopSize, bufSize)
+	}
+	anaHop := uint(math.Floor(float64(hopSize)*rate + 0.5))
+	if rate <= 0 || anaHop == 0 || anaHop > bufSize {
+		return nil, fmt.Errorf("Unsupported stretch rate %g for hop size %d and buffer size %d",
+			rate, hopSize, bufSize)
+	}
+	ana, err := NewPhaseVoc(bufSize, anaHop)
+	if err != nil {
+		return nil, err
+	}
+	syn, err := NewPhaseVoc(bufSize, hopSize)
+	if err != nil {
+		ana.Free()
+		return nil, err
+	}
+	bins := bufSize/2 + 1
+	// Line the output up with the input: the window centres of the
+	// first frames are half a window into the vocoders.
+	skip := float64(bufSize)/2 + float64(bufSize)/2*float64(hopSize)/float64(anaHop) - float64(hopSize)
+	ts := &TimeStretch{
+		src:       src,
+		ana:       ana,
+		syn:       syn,
+		in:        newBuffer(sourceBlockSize(src, hopSize)),
+		frame:     newBuffer(anaHop),
+		out:       newBuffer(hopSize),
+		winSize:   bufSize,
+		anaHop:    anaHop,
+		synHop:    hopSize,
+		lastPhase: make([]float64, bins),
+		synPhase:  make([]float64, bins),
+		skip:      uint(math.Max(0, math.Floor(skip+0.5))),
+		blockSize: hopSize,
+	}
+	return ts, nil
+}
+
+// Rate returns the rate the TimeStretch actually uses.
+func (ts *TimeStretch) Rate() float64 {
+	return float64(ts.anaHop) / float64(ts.synHop)
+}
+
+// Read reads the next block of stretched audio into buf. It returns
+// io.EOF once the source is exhausted.
+func (ts *TimeStretch) Read(buf *SimpleBuffer) (uint, error) {
+	if ts.ana == nil {
+		return 0, errClosedSource
+	}
+	want := blockLen(ts.blockSize, buf)
+	block := ts.take(want)
+	buf.SetData(block)
+	n := uint(len(block))
+	if n < want || n == 0 {
+		if ts.err != nil {
+			return n, ts.err
+		}
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// take returns up to n samples of output. It returns fewer only at
+// the end of the output.
+func (ts *TimeStretch) take(n uint) []float64 {
+	for uint(len(ts.pending)) < n && !ts.done {
+		ts.step()
+	}
+	if n > uint(len(ts.pending)) {
+		n = uint(len(ts.pending))
+	}
+	block := append([]float64(nil), ts.pending[:n]...)
+	ts.pending = append(ts.pending[:0], ts.pending[n:]...)
+	return block
+}
+
+// step runs one frame through the vocoders, appending its output to
+// pending.
+func (ts *TimeStretch) step() {
+	for uint(len(ts.queue)) < ts.anaHop && !ts.srcDone {
+		n, err := ts.src.Read(ts.in)
+		ts.queue = append(ts.queue, ts.in.Slice()[:n]...)
+		ts.read += n
+		if err != nil || n == 0 {
+			if err != io.EOF {
+				ts.err = err
+			}
+			ts.srcDone = true
+		}
+	}
+	target := uint(math.Floor(float64(ts.read)/ts.Rate() + 0.5))
+	if ts.srcDone && (ts.produced >= target || ts.err != nil) {
+		ts.done = true
+		return
+	}
+	// Past the end of the source the vocoders are fed silence to
+	// flush them.
+	m := ts.anaHop
+	if m > uint(len(ts.queue)) {
+		m = uint(len(ts.queue))
+	}
+	ts.frame.SetData(ts.queue[:m])
+	ts.queue = append(ts.queue[:0], ts.queue[m:]...)
+
+	ts.ana.Do(ts.frame)
+	grain := ts.ana.Grain()
+	norm, phase := grain.Norm(), grain.Phase()
+	if !ts.started {
+		copy(ts.synPhase, phase)
+		ts.started = true
+	} else {
+		ts.propagate(norm, phase)
+	}
+	copy(ts.lastPhase, phase)
+	ts.syn.Grain().SetNorm(norm)
+	ts.syn.Grain().SetPhase(ts.synPhase)
+	ts.syn.ReverseDo(ts.out)
+
+	block := ts.out.Slice()
+	if ts.skip > 0 {
+		s := ts.skip
+		if s > uint(len(block)) {
+			s = uint(len(block))
+		}
+		block = block[s:]
+		ts.skip -= s
+	}
+	if ts.srcDone && ts.produced+uint(len(block)) > target {
+		block = block[:target-ts.produced]
+	}
+	ts.pending = append(ts.pending, block...)
+	ts.produced += uint(len(block))
+}
+
+// propagate advances the synthesis phases by a synthesis hop. The
+// phase of each spectral peak advances at the frequency measured from
+// its change over the analysis hop. The bins around a peak keep their
+// analysed phase relative to it, which keeps the partials coherent.
+func (ts *TimeStretch) propagate(norm, phase []float64) {
+	var peaks []int
+	for k := range norm {
+		if (k == 0 || norm[k] > norm[k-1]) && (k == len(norm)-1 || norm[k] >= norm[k+1]) {
+			peaks = append(peaks, k)
+		}
+	}
+	for _, k := range peaks {
+		omega := 2 * math.Pi * float64(k) / float64(ts.winSize)
+		delta := math.Remainder(phase[k]-ts.lastPhase[k]-omega*float64(ts.anaHop), 2*math.Pi)
+		freq := omega + delta/float64(ts.anaHop)
+		ts.synPhase[k] = math.Remainder(ts.synPhase[k]+freq*float64(ts.synHop), 2*math.Pi)
+	}
+	// Each bin belongs to the peak on its side of the quietest bin
+	// between two peaks.
+	start := 0
+	for i, p := range peaks {
+		end := len(norm)
+		if i+1 < len(peaks) {
+			end = p
+			for k := p; k < peaks[i+1]; k++ {
+				if norm[k] < norm[end] {
+					end = k
+				}
+			}
+			end++
+		}
+		for k := start; k < end; k++ {
+			if k != p {
+				ts.synPhase[k] = ts.synPhase[p] + phase[k] - phase[p]
+			}
+		}
+		start = end
+	}
+}
+
+// Samplerate returns the samplerate of the source.
+func (ts *TimeStretch) Samplerate() uint {
+	return ts.src.Samplerate()
+}
+
+// Channels returns the number of channels of the source.
+func (ts *TimeStretch) Channels() uint {
+	return ts.src.Channels()
+}
+
+// BlockSize returns the number of samples returned by each Read.
+func (ts *TimeStretch) BlockSize() uint {
+	return ts.blockSize
+}
+
+// Close frees the phase vocoders and closes the source.
+// It is safe to call Close more than once.
+func (ts *TimeStretch) Close() error {
+	if ts.ana == nil {
+		return nil
+	}
+	ts.ana.Free()
+	ts.syn.Free()
+	freeBuffer(ts.in)
+	freeBuffer(ts.frame)
+	freeBuffer(ts.out)
+	ts.ana, ts.syn, ts.in, ts.frame, ts.out = nil, nil, nil, nil, nil
+	if c, ok := ts.src.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// PitchShift is an AudioSource that transposes another source without
+// changing its duration. It stretches the source with a TimeStretch
+// and resamples the result back to the original length.
+//
+// Like the rate of a TimeStretch, the ratio actually used, returned
+// by Ratio, can differ slightly from the one requested.
+type PitchShift struct {
+	stretch   *TimeStretch
+	buf       []float64
+	pos       float64
+	produced  uint
+	blockSize uint
+}
+
+// NewPitchShift constructs a PitchShift transposing src by semitones,
+// which may be fractional or negative. bufSize and hopSize are as
+// for NewTimeStretch. Closing the PitchShift closes src.
+//
+//	ps, err := NewPitchShift(src, -3, 2048, 512)
+//	if err != nil {
+//		// handle error
+//	}
+//	defer ps.Close()
+func NewPitchShift(src AudioSource, semitones float64, bufSize, hopSize uint) (*PitchShift, error) {
+	ratio := math.Pow(2, semitones/12)
+	ts, err := NewTimeStretch(src, 1/ratio, bufSize, hopSize)
+	if err != nil {
+		return nil, err
+	}
+	return &PitchShift{stretch: ts, blockSize: hopSize}, nil
+}
+
+// Ratio returns the frequency ratio the PitchShift actually uses.
+func (ps *PitchShift) Ratio() float64 {
+	return 1 / ps.stretch.Rate()
+}
+
+// Read reads the next block of transposed audio into buf. It returns
+// io.EOF once the source is exhausted.
+func (ps *PitchShift) Read(buf *SimpleBuffer) (uint, error) {
+	if ps.stretch.ana == nil {
+		return 0, errClosedSource
+	}
+	want := blockLen(ps.blockSize, buf)
+	step := ps.Ratio()
+	block := make([]float64, 0, want)
+	for uint(len(block)) < want {
+		i := int(ps.pos)
+		for len(ps.buf) < i+2 && !ps.stretch.done {
+			ps.buf = append(ps.buf, ps.stretch.take(ps.stretch.synHop)...)
+		}
+		if ps.stretch.done && ps.produced >= ps.stretch.read {
+			break
+		}
+		// Interpolate linearly, treating samples past the end of the
+		// stretched audio as silence.
+		var a, b float64
+		if i < len(ps.buf) {
+			a = ps.buf[i]
+		}
+		if i+1 < len(ps.buf) {
+			b = ps.buf[i+1]
+		}
+		frac := ps.pos - float64(i)
+		block = append(block, a+(b-a)*frac)
+		ps.produced++
+		ps.pos += step
+	}
+	// Drop the samples that have been passed.
+	if i := int(ps.pos); i > 0 && i <= len(ps.buf) {
+		ps.buf = append(ps.buf[:0], ps.buf[i:]...)
+		ps.pos -= float64(i)
+	}
+	buf.SetData(block)
+	n := uint(len(block))
+	if n < want || n == 0 {
+		if ps.stretch.err != nil {
+			return n, ps.stretch.err
+		}
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Samplerate returns the samplerate of the source.
+func (ps *PitchShift) Samplerate() uint {
+	return ps.stretch.Samplerate()
+}
+
+// Channels returns the number of channels of the source.
+func (ps *PitchShift) Channels() uint {
+	return ps.stretch.Channels()
+}
+
+// BlockSize returns the number of samples returned by each Read.
+func (ps *PitchShift) BlockSize() uint {
+	return ps.blockSize
+}
+
+// Close frees the phase vocoders and closes the source.
+// It is safe to call Close more than once.
+func (ps *PitchShift) Close() error {
+	return ps.stretch.Close()
+}
@@ -0,0 +1,180 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package aubio_test
+
+import (
+	"math"
+	"testing"
+
+	"go.marzhillstudios.com/pkg/play/aubio"
+	"go.marzhillstudios.com/pkg/play/aubio/synth"
+)
+
+const (
+	stretchSamplerate = 8000
+	stretchBufSize    = 1024
+	stretchHopSize    = 256
+)
+
+// readToEnd reads src to the end in blocks of stretchHopSize samples.
+func readToEnd(t *testing.T, src aubio.AudioSource) []float64 {
+	t.Helper()
+	p := aubio.NewSimplePipeline(src, nil, stretchHopSize)
+	var out []float64
+	n := p.DoAll(func(in *aubio.SimpleBuffer) {
+		out = append(out, in.Slice()...)
+	})
+	if err := p.Err(); err != nil {
+		t.Fatal(err)
+	}
+	// The last block is padded.
+	return out[:n]
+}
+
+// crossingFreq estimates the frequency of a sine from its upward zero
+// crossings over the middle half of data, away from the fades of the
+// vocoder windows.
+func crossingFreq(data []float64, samplerate uint) float64 {
+	data = data[len(data)/4 : 3*len(data)/4]
+	first, last, n := -1.0, 0.0, 0
+	for i := 1; i < len(data); i++ {
+		if data[i-1] < 0 && data[i] >= 0 {
+			// Interpolate the crossing between the samples.
+			at := float64(i-1) + data[i-1]/(data[i-1]-data[i])
+			if first < 0 {
+				first = at
+			} else {
+				n++
+			}
+			last = at
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return float64(n) * float64(samplerate) / (last - first)
+}
+
+// sineSource returns a MemorySource of seconds of a 440Hz sine.
+func sineSource(seconds float64) (*aubio.MemorySource, int) {
+	n := synth.Samples(seconds, stretchSamplerate)
+	data := synth.Render(synth.Sine(440, 0.5, stretchSamplerate), n)
+	return aubio.NewMemorySource(data, stretchSamplerate, stretchHopSize), int(n)
+}
+
+func TestTimeStretch(t *testing.T) {
+	for _, rate := range []float64{0.5, 0.8, 1, 1.5, 2} {
+		src, n := sineSource(1)
+		ts, err := aubio.NewTimeStretch(src, rate, stretchBufSize, stretchHopSize)
+		if err != nil {
+			t.Fatalf("Rate %g: %s", rate, err)
+		}
+		out := readToEnd(t, ts)
+		if want := float64(n) / ts.Rate(); math.Abs(float64(len(out))-want) > 1 {
+			t.Errorf("Rate %g: stretched %d samples to %d, want %g", rate, n, len(out), want)
+		}
+		if f := crossingFreq(out, stretchSamplerate); math.Abs(f-440) > 440*0.01 {
+			t.Errorf("Rate %g: a 440Hz sine stretched to %gHz", rate, f)
+		}
+		ts.Close()
+	}
+}
+
+func TestTimeStretchRate(t *testing.T) {
+	src, _ := sineSource(0.1)
+	// 256*0.3 is rounded to an analysis hop of 77 samples.
+	ts, err := aubio.NewTimeStretch(src, 0.3, stretchBufSize, stretchHopSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ts.Close()
+	if want := 77.0 / 256; ts.Rate() != want {
+		t.Errorf("Rate = %g, want %g", ts.Rate(), want)
+	}
+	for _, rate := range []float64{0, -1, 5} {
+		if _, err := aubio.NewTimeStretch(src, rate, stretchBufSize, stretchHopSize); err == nil {
+			t.Errorf("NewTimeStretch with rate %g succeeded", rate)
+		}
+	}
+}
+
+func TestPitchShift(t *testing.T) {
+	for _, semitones := range []float64{-5, -1, 3, 7} {
+		src, n := sineSource(1)
+		ps, err := aubio.NewPitchShift(src, semitones, stretchBufSize, stretchHopSize)
+		if err != nil {
+			t.Fatalf("%g semitones: %s", semitones, err)
+		}
+		if want := math.Pow(2, semitones/12); math.Abs(ps.Ratio()-want) > want*0.01 {
+			t.Errorf("%g semitones: Ratio = %g, want about %g", semitones, ps.Ratio(), want)
+		}
+		out := readToEnd(t, ps)
+		if len(out) != n {
+			t.Errorf("%g semitones: shifted %d samples to %d", semitones, n, len(out))
+		}
+		want := 440 * ps.Ratio()
+		if f := crossingFreq(out, stretchSamplerate); math.Abs(f-want) > want*0.01 {
+			t.Errorf("%g semitones: a 440Hz sine shifted to %gHz, want %gHz", semitones, f, want)
+		}
+		ps.Close()
+	}
+}
+
+// closeCounter is an AudioSource counting the calls to its Close.
+type closeCounter struct {
+	aubio.AudioSource
+	closed int
+}
+
+func (c *closeCounter) Close() error {
+	c.closed++
+	return nil
+}
+
+func TestPitchShiftClose(t *testing.T) {
+	before := map[aubio.Allocation]bool{}
+	for _, l := range aubio.Leaks() {
+		before[l] = true
+	}
+	aubio.DetectLeaks(true)
+	mem, _ := sineSource(0.5)
+	src := &closeCounter{AudioSource: mem}
+	ps, err := aubio.NewPitchShift(src, 2, stretchBufSize, stretchHopSize)
+	aubio.DetectLeaks(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := aubio.NewSimpleBuffer(stretchHopSize)
+	defer buf.Free()
+	if _, err := ps.Read(buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := ps.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// The vocoders and buffers of the stretcher are freed with the
+	// resampler, and the source closed once however often Close is
+	// called.
+	for _, l := range aubio.Leaks() {
+		if !before[l] {
+			t.Errorf("%s was not freed by Close. Allocated at:\n%s", l.Type, l.Stack)
+		}
+	}
+	if err := ps.Close(); err != nil {
+		t.Errorf("Second Close failed: %s", err)
+	}
+	if src.closed != 1 {
+		t.Errorf("Closed the source %d times, want 1", src.closed)
+	}
+	if _, err := ps.Read(buf); err == nil {
+		t.Errorf("Read after Close succeeded")
+	}
+}
@@ -0,0 +1,324 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package aubio
+
+import (
+	"sync"
+)
+
+// The aubio objects wrapped by Pitch, Onset, Tempo, PhaseVoc and the
+// rest of this package keep internal state between calls and are not
+// reentrant, so none of the wrappers are safe for concurrent use.
+// The Sync types below serialise every call with a mutex and return
+// copies of the output so results can't be overwritten by another
+// goroutine before they are read. Input buffers still belong to the
+// caller and must not be shared between goroutines.
+//
+// Once a Sync type has been freed its methods do nothing: Do returns
+// nil, getters return 0 and With does not call f.
+
+// SyncPitch is a Pitch that is safe for concurrent use.
+type SyncPitch struct {
+	mu sync.Mutex
+	p  *Pitch
+}
+
+// NewSyncPitch wraps p. p must not be used directly afterwards.
+// Freeing the SyncPitch frees p.
+//
+//	p := NewSyncPitch(NewPitch(PitchYin, bufSize, blockSize, samplerate))
+//	defer p.Free()
+func NewSyncPitch(p *Pitch) *SyncPitch {
+	return &SyncPitch{p: p}
+}
+
+// Do runs one step of the pitch detection and returns a copy of
+// the output.
+func (s *SyncPitch) Do(in *SimpleBuffer) []float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.p == nil {
+		return nil
+	}
+	s.p.Do(in)
+	return s.p.Buffer().Slice()
+}
+
+// SetTolerance sets the yin or yinfft tolerance threshold.
+func (s *SyncPitch) SetTolerance(tol float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.p == nil {
+		return
+	}
+	s.p.SetTolerance(tol)
+}
+
+// SetUnit sets the output unit.
+func (s *SyncPitch) SetUnit(outMode pitchOutMode) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.p == nil {
+		return
+	}
+	s.p.SetUnit(outMode)
+}
+
+// With calls f with the wrapped Pitch while holding the lock.
+// f must not keep a reference to the Pitch or its buffer.
+func (s *SyncPitch) With(f func(p *Pitch)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.p == nil {
+		return
+	}
+	f(s.p)
+}
+
+// Free frees the wrapped Pitch.
+func (s *SyncPitch) Free() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.p != nil {
+		s.p.Free()
+		s.p = nil
+	}
+}
+
+// Close frees the wrapped Pitch. It always returns nil.
+func (s *SyncPitch) Close() error {
+	s.Free()
+	return nil
+}
+
+// SyncOnset is an Onset that is safe for concurrent use.
+type SyncOnset struct {
+	mu sync.Mutex
+	o  *Onset
+}
+
+// NewSyncOnset wraps o. o must not be used directly afterwards.
+// Freeing the SyncOnset frees o.
+func NewSyncOnset(o *Onset) *SyncOnset {
+	return &SyncOnset{o: o}
+}
+
+// Do executes the onset detection on an input Buffer and returns
+// a copy of the output.
+func (s *SyncOnset) Do(in *SimpleBuffer) []float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.o == nil {
+		return nil
+	}
+	s.o.Do(in)
+	return s.o.Buffer().Slice()
+}
+
+// SetSilence sets the onset detection silence threshold.
+func (s *SyncOnset) SetSilence(silence float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.o == nil {
+		return
+	}
+	s.o.SetSilence(silence)
+}
+
+// SetThreshold sets the onset detection peak picking threshold.
+func (s *SyncOnset) SetThreshold(threshold float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.o == nil {
+		return
+	}
+	s.o.SetThreshold(threshold)
+}
+
+// With calls f with the wrapped Onset while holding the lock.
+// f must not keep a reference to the Onset or its buffer.
+func (s *SyncOnset) With(f func(o *Onset)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.o == nil {
+		return
+	}
+	f(s.o)
+}
+
+// Free frees the wrapped Onset.
+func (s *SyncOnset) Free() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.o != nil {
+		s.o.Free()
+		s.o = nil
+	}
+}
+
+// Close frees the wrapped Onset. It always returns nil.
+func (s *SyncOnset) Close() error {
+	s.Free()
+	return nil
+}
+
+// SyncTempo is a Tempo that is safe for concurrent use.
+type SyncTempo struct {
+	mu sync.Mutex
+	t  *Tempo
+}
+
+// NewSyncTempo wraps t. t must not be used directly afterwards.
+// Freeing the SyncTempo frees t.
+func NewSyncTempo(t *Tempo) *SyncTempo {
+	return &SyncTempo{t: t}
+}
+
+// Do executes the tempo detection on an input Buffer and returns
+// a copy of the output.
+func (s *SyncTempo) Do(in *SimpleBuffer) []float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.t == nil {
+		return nil
+	}
+	s.t.Do(in)
+	return s.t.Buffer().Slice()
+}
+
+// SetSilence sets the tempo detection silence threshold.
+func (s *SyncTempo) SetSilence(silence float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.t == nil {
+		return
+	}
+	s.t.SetSilence(silence)
+}
+
+// SetThreshold sets the tempo detection peak picking threshold.
+func (s *SyncTempo) SetThreshold(threshold float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.t == nil {
+		return
+	}
+	s.t.SetThreshold(threshold)
+}
+
+// GetBpm returns the current bpm estimate.
+func (s *SyncTempo) GetBpm() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.t == nil {
+		return 0
+	}
+	return s.t.GetBpm()
+}
+
+// GetConfidence returns the confidence of the current bpm estimate.
+func (s *SyncTempo) GetConfidence() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.t == nil {
+		return 0
+	}
+	return s.t.GetConfidence()
+}
+
+// With calls f with the wrapped Tempo while holding the lock.
+// f must not keep a reference to the Tempo or its buffer.
+func (s *SyncTempo) With(f func(t *Tempo)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.t == nil {
+		return
+	}
+	f(s.t)
+}
+
+// Free frees the wrapped Tempo.
+func (s *SyncTempo) Free() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.t != nil {
+		s.t.Free()
+		s.t = nil
+	}
+}
+
+// Close frees the wrapped Tempo. It always returns nil.
+func (s *SyncTempo) Close() error {
+	s.Free()
+	return nil
+}
+
+// SyncPhaseVoc is a PhaseVoc that is safe for concurrent use.
+type SyncPhaseVoc struct {
+	mu sync.Mutex
+	pv *PhaseVoc
+}
+
+// NewSyncPhaseVoc wraps pv. pv must not be used directly afterwards.
+// Freeing the SyncPhaseVoc frees pv.
+func NewSyncPhaseVoc(pv *PhaseVoc) *SyncPhaseVoc {
+	return &SyncPhaseVoc{pv: pv}
+}
+
+// Do runs the phase vocoder on an input Buffer and returns copies
+// of the norm and phase of the resulting grain.
+func (s *SyncPhaseVoc) Do(in *SimpleBuffer) (norm, phase []float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pv == nil {
+		return
+	}
+	s.pv.Do(in)
+	return s.pv.Grain().Norm(), s.pv.Grain().Phase()
+}
+
+// ReverseDo resynthesises the current grain into out.
+func (s *SyncPhaseVoc) ReverseDo(out *SimpleBuffer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pv == nil {
+		return
+	}
+	s.pv.ReverseDo(out)
+}
+
+// With calls f with the wrapped PhaseVoc while holding the lock.
+// Use it to analyse, modify the grain and resynthesise as one step.
+// f must not keep a reference to the PhaseVoc or its grain.
+func (s *SyncPhaseVoc) With(f func(pv *PhaseVoc)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pv == nil {
+		return
+	}
+	f(s.pv)
+}
+
+// Free frees the wrapped PhaseVoc.
+func (s *SyncPhaseVoc) Free() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pv != nil {
+		s.pv.Free()
+		s.pv = nil
+	}
+}
+
+// Close frees the wrapped PhaseVoc. It always returns nil.
+func (s *SyncPhaseVoc) Close() error {
+	s.Free()
+	return nil
+}
@@ -0,0 +1,163 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package aubio
+
+import (
+	"math"
+	"sync"
+	"testing"
+)
+
+// These tests are meant to be run under the race detector:
+//
+//	go test -race -run Sync .
+
+const (
+	syncSamplerate = 44100
+	syncHop        = 256
+	syncWin        = 512
+)
+
+// syncWorkers runs work from several goroutines, each with its own
+// input block of a sine and an output block, and waits for them.
+func syncWorkers(t *testing.T, blocks int, work func(in, out *SimpleBuffer)) {
+	t.Helper()
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			freq := 220 * float64(w+1)
+			src := NewGeneratorSource(func(i uint) float64 {
+				return 0.5 * math.Sin(2*math.Pi*freq*float64(i)/syncSamplerate)
+			}, 0, syncSamplerate, syncHop)
+			in := NewSimpleBuffer(syncHop)
+			defer in.Free()
+			out := NewSimpleBuffer(syncHop)
+			defer out.Free()
+			for b := 0; b < blocks; b++ {
+				src.Read(in)
+				work(in, out)
+			}
+		}(w)
+	}
+	wg.Wait()
+}
+
+func TestSyncAnalyzersConcurrent(t *testing.T) {
+	pitch := NewSyncPitch(NewPitch(PitchYin, syncWin, syncHop, syncSamplerate))
+	defer pitch.Free()
+	onset := NewSyncOnset(OnsetOrDie(HFC, syncWin, syncHop, syncSamplerate))
+	defer onset.Free()
+	tempo := NewSyncTempo(TempoOrDie(SpecDiff, syncWin, syncHop, syncSamplerate))
+	defer tempo.Free()
+	pv, err := NewPhaseVoc(syncWin, syncHop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pvoc := NewSyncPhaseVoc(pv)
+	defer pvoc.Free()
+
+	syncWorkers(t, 50, func(in, out *SimpleBuffer) {
+		if got := pitch.Do(in); got == nil {
+			t.Errorf("Pitch returned nil before Free")
+		}
+		pitch.SetTolerance(0.2)
+		onset.Do(in)
+		onset.SetThreshold(0.3)
+		tempo.Do(in)
+		tempo.GetBpm()
+		tempo.GetConfidence()
+		norm, phase := pvoc.Do(in)
+		if len(norm) != syncWin/2+1 || len(phase) != syncWin/2+1 {
+			t.Errorf("PhaseVoc returned %d and %d bins, want %d", len(norm), len(phase), syncWin/2+1)
+		}
+		pvoc.With(func(pv *PhaseVoc) {
+			pv.Do(in)
+			pv.ReverseDo(out)
+		})
+	})
+}
+
+func TestSyncFreedWhileInUse(t *testing.T) {
+	pitch := NewSyncPitch(NewPitch(PitchYin, syncWin, syncHop, syncSamplerate))
+	onset := NewSyncOnset(OnsetOrDie(HFC, syncWin, syncHop, syncSamplerate))
+	tempo := NewSyncTempo(TempoOrDie(SpecDiff, syncWin, syncHop, syncSamplerate))
+	pv, err := NewPhaseVoc(syncWin, syncHop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pvoc := NewSyncPhaseVoc(pv)
+
+	// One goroutine frees the analyzers while the others are still
+	// using them. Calls after the Free must not crash.
+	var once sync.Once
+	calls := 0
+	var mu sync.Mutex
+	syncWorkers(t, 25, func(in, out *SimpleBuffer) {
+		mu.Lock()
+		calls++
+		free := calls == 100
+		mu.Unlock()
+		if free {
+			once.Do(func() {
+				pitch.Free()
+				onset.Free()
+				tempo.Close()
+				pvoc.Close()
+			})
+		}
+		pitch.Do(in)
+		onset.Do(in)
+		tempo.Do(in)
+		tempo.GetBpm()
+		pvoc.Do(in)
+		pvoc.ReverseDo(out)
+		pvoc.With(func(pv *PhaseVoc) { pv.Do(in) })
+	})
+}
+
+func TestSyncAfterFree(t *testing.T) {
+	pitch := NewSyncPitch(NewPitch(PitchYin, syncWin, syncHop, syncSamplerate))
+	onset := NewSyncOnset(OnsetOrDie(HFC, syncWin, syncHop, syncSamplerate))
+	tempo := NewSyncTempo(TempoOrDie(SpecDiff, syncWin, syncHop, syncSamplerate))
+	pv, err := NewPhaseVoc(syncWin, syncHop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pvoc := NewSyncPhaseVoc(pv)
+	for i := 0; i < 2; i++ {
+		pitch.Free()
+		onset.Free()
+		tempo.Free()
+		pvoc.Free()
+	}
+
+	in := NewSimpleBuffer(syncHop)
+	defer in.Free()
+	if got := pitch.Do(in); got != nil {
+		t.Errorf("SyncPitch.Do after Free = %v, want nil", got)
+	}
+	if got := onset.Do(in); got != nil {
+		t.Errorf("SyncOnset.Do after Free = %v, want nil", got)
+	}
+	if got := tempo.Do(in); got != nil {
+		t.Errorf("SyncTempo.Do after Free = %v, want nil", got)
+	}
+	if got := tempo.GetBpm(); got != 0 {
+		t.Errorf("SyncTempo.GetBpm after Free = %g, want 0", got)
+	}
+	if norm, phase := pvoc.Do(in); norm != nil || phase != nil {
+		t.Errorf("SyncPhaseVoc.Do after Free = %v, %v, want nil", norm, phase)
+	}
+	pitch.With(func(*Pitch) { t.Errorf("SyncPitch.With called f after Free") })
+	pvoc.With(func(*PhaseVoc) { t.Errorf("SyncPhaseVoc.With called f after Free") })
+}
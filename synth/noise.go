@@ -0,0 +1,50 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package synth
+
+// pinkRows is the number of octaves of white noise summed to make
+// pink noise.
+const pinkRows = 16
+
+// hash mixes x into a well distributed 64 bit value (splitmix64).
+func hash(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+// uniform returns a value in [-1, 1) determined by seed and i.
+func uniform(seed int64, i uint64) float64 {
+	return float64(hash(uint64(seed)^hash(i))>>11)/(1<<52) - 1
+}
+
+// WhiteNoise returns uniformly distributed white noise with the given
+// amplitude. Noise with the same seed is the same.
+func WhiteNoise(amp float64, seed int64) Signal {
+	return func(i uint) float64 {
+		return amp * uniform(seed, uint64(i))
+	}
+}
+
+// PinkNoise returns noise whose power falls by 3dB per octave, made
+// with the Voss-McCartney algorithm: row r of white noise changes
+// every 2^r samples and the rows are summed. Noise with the same seed
+// is the same.
+func PinkNoise(amp float64, seed int64) Signal {
+	return func(i uint) float64 {
+		v := uniform(seed, uint64(i))
+		for r := uint(1); r < pinkRows; r++ {
+			v += uniform(seed+int64(r), uint64(i)>>r)
+		}
+		return amp * v / pinkRows
+	}
+}
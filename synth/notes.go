@@ -0,0 +1,138 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package synth
+
+import (
+	"math"
+	"sort"
+
+	"go.marzhillstudios.com/pkg/play/aubio"
+)
+
+// BeatTimes returns the times in seconds of the beats at bpm beats
+// per minute in the first duration seconds, starting at offset.
+func BeatTimes(bpm, offset, duration float64) []float64 {
+	var times []float64
+	for t := offset; t < duration; t = offset + float64(len(times))*60/bpm {
+		times = append(times, t)
+	}
+	return times
+}
+
+// Impulses returns a signal with a single sample of amplitude amp at
+// each of times, in seconds, and silence elsewhere.
+func Impulses(times []float64, amp float64, samplerate uint) Signal {
+	at := make(map[uint]bool, len(times))
+	for _, t := range times {
+		at[Samples(t, samplerate)] = true
+	}
+	return func(i uint) float64 {
+		if at[i] {
+			return amp
+		}
+		return 0
+	}
+}
+
+// ImpulseTrain returns impulses of amplitude amp at bpm beats per
+// minute, starting at offset seconds. The beats are at
+// BeatTimes(bpm, offset, duration).
+func ImpulseTrain(bpm, offset, amp float64, samplerate uint) Signal {
+	period := 60 / bpm
+	return func(i uint) float64 {
+		t := float64(i)/float64(samplerate) - offset
+		if t < 0 {
+			return 0
+		}
+		k := math.Floor(t/period + 0.5)
+		if Samples(offset+k*period, samplerate) == i {
+			return amp
+		}
+		return 0
+	}
+}
+
+// MidiToFreq returns the frequency in Hz of a MIDI note number.
+func MidiToFreq(note float64) float64 {
+	return 440 * math.Pow(2, (note-69)/12)
+}
+
+// pluckHarmonics is the number of harmonics in a plucked note.
+const pluckHarmonics = 8
+
+// Pluck returns the sound of a plucked string at the given frequency:
+// harmonics that decay faster the higher they are. decay is the time
+// in seconds the fundamental takes to fall by 60dB.
+func Pluck(freq, amp, decay float64, samplerate uint) Signal {
+	// The harmonics have amplitudes 1/h and are scaled so that the
+	// sum is at most amp.
+	norm := 0.0
+	for h := 1; h <= pluckHarmonics; h++ {
+		norm += 1 / float64(h)
+	}
+	return func(i uint) float64 {
+		t := float64(i) / float64(samplerate)
+		v := 0.0
+		for h := 1; h <= pluckHarmonics; h++ {
+			f := freq * float64(h)
+			if f >= float64(samplerate)/2 {
+				break
+			}
+			env := math.Exp(-6.9 * t * float64(h) / decay)
+			v += math.Sin(2*math.Pi*f*t) * env / float64(h)
+		}
+		return amp * v / norm
+	}
+}
+
+// Notes returns plucked notes. Each note starts at its Start, has the
+// frequency of its MIDI Pitch and an amplitude of Velocity/127, and is
+// silenced at its End. The notes are the answers a transcription of
+// the signal should give.
+func Notes(notes []aubio.Note, samplerate uint) Signal {
+	type voice struct {
+		start, end uint
+		sound      Signal
+	}
+	voices := make([]voice, len(notes))
+	for i, n := range notes {
+		voices[i] = voice{
+			start: Samples(n.Start, samplerate),
+			end:   Samples(n.End, samplerate),
+			sound: Pluck(MidiToFreq(n.Pitch), n.Velocity/127, 1, samplerate),
+		}
+	}
+	sort.Slice(voices, func(i, j int) bool { return voices[i].start < voices[j].start })
+	return func(i uint) float64 {
+		v := 0.0
+		for _, vc := range voices {
+			if vc.start > i {
+				break
+			}
+			if i < vc.end {
+				v += vc.sound(i - vc.start)
+			}
+		}
+		return v
+	}
+}
+
+// Melody returns notes of the given MIDI pitches played one after the
+// other, each lasting duration seconds with a gap of gap seconds
+// before the next, starting at time 0.
+func Melody(pitches []float64, duration, gap, velocity float64) []aubio.Note {
+	notes := make([]aubio.Note, len(pitches))
+	for i, p := range pitches {
+		start := float64(i) * (duration + gap)
+		notes[i] = aubio.Note{Start: start, End: start + duration, Pitch: p, Velocity: velocity}
+	}
+	return notes
+}
@@ -0,0 +1,168 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+// Package synth generates test signals with known properties: tones,
+// chirps, noise, click tracks at a given tempo and sequences of notes
+// with known pitches. Signals can be rendered to slices, SimpleBuffer
+// blocks or in-memory sources so that analyzers can be checked
+// against the answers they should find.
+//
+//	const sr = 44100
+//	s := synth.Source(synth.Sine(440, 0.5, sr), synth.Samples(2, sr), sr, 256)
+//	p := aubio.NewSimplePipeline(s, nil, 256)
+package synth
+
+import (
+	"math"
+
+	"go.marzhillstudios.com/pkg/play/aubio"
+)
+
+// Signal returns sample i of a signal. Signals can be sampled in any
+// order and always return the same value for the same sample.
+type Signal func(i uint) float64
+
+// Samples returns the number of samples in duration seconds.
+func Samples(duration float64, samplerate uint) uint {
+	return uint(math.Floor(duration*float64(samplerate) + 0.5))
+}
+
+// Sine returns a sine wave of the given frequency in Hz and amplitude.
+func Sine(freq, amp float64, samplerate uint) Signal {
+	w := 2 * math.Pi * freq / float64(samplerate)
+	return func(i uint) float64 {
+		return amp * math.Sin(w*float64(i))
+	}
+}
+
+// Square returns a square wave of the given frequency in Hz and
+// amplitude.
+func Square(freq, amp float64, samplerate uint) Signal {
+	return func(i uint) float64 {
+		if phase(freq, i, samplerate) < 0.5 {
+			return amp
+		}
+		return -amp
+	}
+}
+
+// Saw returns a rising sawtooth wave of the given frequency in Hz and
+// amplitude.
+func Saw(freq, amp float64, samplerate uint) Signal {
+	return func(i uint) float64 {
+		return amp * (2*phase(freq, i, samplerate) - 1)
+	}
+}
+
+// phase returns the phase of sample i of a wave of frequency freq as
+// a fraction of a period.
+func phase(freq float64, i, samplerate uint) float64 {
+	p := freq * float64(i) / float64(samplerate)
+	return p - math.Floor(p)
+}
+
+// Chirp returns a sine sweeping linearly from f0 to f1 Hz over
+// duration seconds. It stays at f1 afterwards.
+func Chirp(f0, f1, duration, amp float64, samplerate uint) Signal {
+	k := (f1 - f0) / duration
+	return func(i uint) float64 {
+		t := float64(i) / float64(samplerate)
+		var ph float64
+		if t < duration {
+			ph = f0*t + k*t*t/2
+		} else {
+			ph = f0*duration + k*duration*duration/2 + f1*(t-duration)
+		}
+		return amp * math.Sin(2*math.Pi*ph)
+	}
+}
+
+// ChirpFreq returns the frequency of a Chirp at t seconds.
+func ChirpFreq(f0, f1, duration, t float64) float64 {
+	if t >= duration {
+		return f1
+	}
+	return f0 + (f1-f0)*t/duration
+}
+
+// Silence returns a signal of zeros.
+func Silence() Signal {
+	return func(uint) float64 { return 0 }
+}
+
+// Mix returns the sum of signals.
+func Mix(signals ...Signal) Signal {
+	return func(i uint) float64 {
+		v := 0.0
+		for _, s := range signals {
+			v += s(i)
+		}
+		return v
+	}
+}
+
+// Gain returns s scaled by g.
+func Gain(s Signal, g float64) Signal {
+	return func(i uint) float64 {
+		return g * s(i)
+	}
+}
+
+// Window returns s between samples start and end and silence
+// elsewhere.
+func Window(s Signal, start, end uint) Signal {
+	return func(i uint) float64 {
+		if i < start || i >= end {
+			return 0
+		}
+		return s(i)
+	}
+}
+
+// Render returns the first length samples of s.
+func Render(s Signal, length uint) []float64 {
+	data := make([]float64, length)
+	for i := range data {
+		data[i] = s(uint(i))
+	}
+	return data
+}
+
+// Source returns a source producing the first length samples of s,
+// blockSize samples at a time.
+func Source(s Signal, length, samplerate, blockSize uint) *aubio.GeneratorSource {
+	return aubio.NewGeneratorSource(s, length, samplerate, blockSize)
+}
+
+// MemorySource renders the first length samples of s and returns a
+// source reading them blockSize samples at a time. Unlike Source it
+// can be rewound with Reset.
+func MemorySource(s Signal, length, samplerate, blockSize uint) *aubio.MemorySource {
+	return aubio.NewMemorySource(Render(s, length), samplerate, blockSize)
+}
+
+// Blocks returns the first length samples of s split into buffers of
+// blockSize samples. The last buffer is padded with zeros. The caller
+// is responsible for calling Free on the buffers.
+func Blocks(s Signal, length, blockSize uint) []*aubio.SimpleBuffer {
+	var blocks []*aubio.SimpleBuffer
+	for start := uint(0); start < length; start += blockSize {
+		n := blockSize
+		if length-start < n {
+			n = length - start
+		}
+		data := make([]float64, n)
+		for i := range data {
+			data[i] = s(start + uint(i))
+		}
+		blocks = append(blocks, aubio.NewSimpleBufferData(blockSize, data))
+	}
+	return blocks
+}
@@ -0,0 +1,204 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package synth
+
+import (
+	"math"
+	"testing"
+)
+
+const sr = 8000
+
+// crossingFreq estimates the frequency of a tone from its upward zero
+// crossings in data.
+func crossingFreq(data []float64) float64 {
+	first, last, n := -1.0, 0.0, 0
+	for i := 1; i < len(data); i++ {
+		if data[i-1] < 0 && data[i] >= 0 {
+			at := float64(i-1) + data[i-1]/(data[i-1]-data[i])
+			if first < 0 {
+				first = at
+			} else {
+				n++
+			}
+			last = at
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return float64(n) * sr / (last - first)
+}
+
+// nonZero returns the indices of the samples of data that aren't 0.
+func nonZero(data []float64) []uint {
+	var at []uint
+	for i, v := range data {
+		if v != 0 {
+			at = append(at, uint(i))
+		}
+	}
+	return at
+}
+
+func TestSine(t *testing.T) {
+	for _, freq := range []float64{55, 440, 1234.5} {
+		data := Render(Sine(freq, 0.5, sr), Samples(1, sr))
+		if f := crossingFreq(data); math.Abs(f-freq) > 0.5 {
+			t.Errorf("Sine(%g) has a frequency of %gHz", freq, f)
+		}
+		for i, v := range data {
+			if math.Abs(v) > 0.5 {
+				t.Fatalf("Sine(%g) sample %d = %g, above the amplitude", freq, i, v)
+			}
+		}
+	}
+}
+
+func TestChirp(t *testing.T) {
+	const f0, f1, duration = 200.0, 1800.0, 2.0
+	data := Render(Chirp(f0, f1, duration, 1, sr), Samples(duration+0.5, sr))
+	// Measured over 50ms the frequency is that at the middle of the
+	// window, give or take a period.
+	window := Samples(0.05, sr)
+	for start := uint(0); start+window <= uint(len(data)); start += window {
+		mid := (float64(start) + float64(window)/2) / sr
+		want := ChirpFreq(f0, f1, duration, mid)
+		if f := crossingFreq(data[start : start+window]); math.Abs(f-want) > 20 {
+			t.Errorf("Chirp at %gs has a frequency of %gHz, want %gHz", mid, f, want)
+		}
+	}
+	if f := ChirpFreq(f0, f1, duration, duration+1); f != f1 {
+		t.Errorf("ChirpFreq after the sweep = %g, want %g", f, f1)
+	}
+}
+
+func TestImpulseTrain(t *testing.T) {
+	// A period of 60/97 seconds isn't a whole number of samples, so
+	// rounding errors would build up.
+	const bpm, offset = 97, 0.013
+	length := Samples(60, sr)
+	var want []uint
+	for _, b := range BeatTimes(bpm, offset, 60) {
+		want = append(want, Samples(b, sr))
+	}
+	for name, s := range map[string]Signal{
+		"ImpulseTrain": ImpulseTrain(bpm, offset, 0.8, sr),
+		"Impulses":     Impulses(BeatTimes(bpm, offset, 60), 0.8, sr),
+	} {
+		data := Render(s, length)
+		got := nonZero(data)
+		if len(got) != len(want) {
+			t.Errorf("%s has %d impulses, want %d", name, len(got), len(want))
+			continue
+		}
+		for i := range want {
+			if got[i] != want[i] || data[got[i]] != 0.8 {
+				t.Errorf("%s impulse %d = %g at %d, want 0.8 at %d", name, i, data[got[i]], got[i], want[i])
+			}
+		}
+	}
+}
+
+func TestBeatTimes(t *testing.T) {
+	times := BeatTimes(120, 0.25, 2)
+	want := []float64{0.25, 0.75, 1.25, 1.75}
+	if len(times) != len(want) {
+		t.Fatalf("BeatTimes = %v, want %v", times, want)
+	}
+	for i := range want {
+		if times[i] != want[i] {
+			t.Errorf("BeatTimes = %v, want %v", times, want)
+			break
+		}
+	}
+}
+
+func TestMelody(t *testing.T) {
+	notes := Melody([]float64{60, 64, 67}, 0.2, 0.05, 100)
+	data := Render(Notes(notes, sr), Samples(1, sr))
+	prevEnd := uint(0)
+	for i, n := range notes {
+		if want := float64(i) * 0.25; n.Start != want || n.End != want+0.2 || n.Velocity != 100 {
+			t.Errorf("Note %d = %+v, want %g to %g at velocity 100", i, n, want, want+0.2)
+		}
+		// Each voice is silent until its first sample, which is the
+		// start of its sine, and is silenced at its end.
+		start, end := Samples(n.Start, sr), Samples(n.End, sr)
+		for j := prevEnd; j <= start; j++ {
+			if data[j] != 0 {
+				t.Errorf("Note %d: sample %d before it starts at %d = %g", i, j, start, data[j])
+				break
+			}
+		}
+		if data[start+1] <= 0 {
+			t.Errorf("Note %d: sample %d after it starts = %g, want the rising sine", i, start+1, data[start+1])
+		}
+		if f := crossingFreq(data[start : start+Samples(0.1, sr)]); math.Abs(f-MidiToFreq(n.Pitch)) > 5 {
+			t.Errorf("Note %d has a frequency of %gHz, want %gHz", i, f, MidiToFreq(n.Pitch))
+		}
+		prevEnd = end
+	}
+	for j := prevEnd; j < uint(len(data)); j++ {
+		if data[j] != 0 {
+			t.Fatalf("Sample %d after the last note = %g", j, data[j])
+		}
+	}
+}
+
+func TestNotesOverlap(t *testing.T) {
+	// A later note sounds over an earlier one that hasn't ended.
+	notes := Melody([]float64{57, 69}, 0.2, -0.1, 127)
+	data := Render(Notes(notes, sr), Samples(0.3, sr))
+	first := Render(Pluck(MidiToFreq(57), 1, 1, sr), Samples(0.2, sr))
+	second := Render(Pluck(MidiToFreq(69), 1, 1, sr), Samples(0.2, sr))
+	start := Samples(0.1, sr)
+	for i, v := range data {
+		want := 0.0
+		if i < len(first) {
+			want += first[i]
+		}
+		if uint(i) >= start {
+			want += second[uint(i)-start]
+		}
+		if math.Abs(v-want) > 1e-12 {
+			t.Fatalf("Sample %d = %g, want %g", i, v, want)
+		}
+	}
+}
+
+func TestPinkNoise(t *testing.T) {
+	a := Render(PinkNoise(0.5, 7), 4096)
+	b := Render(PinkNoise(0.5, 7), 4096)
+	c := Render(PinkNoise(0.5, 8), 4096)
+	same := 0
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("Sample %d differs between two renders with the same seed: %g, %g", i, a[i], b[i])
+		}
+		if a[i] == c[i] {
+			same++
+		}
+		if math.Abs(a[i]) > 0.5 {
+			t.Fatalf("Sample %d = %g, above the amplitude", i, a[i])
+		}
+	}
+	if same > 0 {
+		t.Errorf("%d samples are the same with different seeds", same)
+	}
+	// Samples don't depend on the order they are computed in.
+	noise := PinkNoise(0.5, 7)
+	for _, i := range []uint{4095, 17, 2048, 0} {
+		if v := noise(i); v != a[i] {
+			t.Errorf("Sample %d = %g out of order, want %g", i, v, a[i])
+		}
+	}
+}
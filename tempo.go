@@ -18,6 +18,7 @@ import "C"
 
 import (
 	"fmt"
+	"runtime"
 )
 
 // Tempo is a wrapper for the aubio_tempo_t tempo detection object.
@@ -52,7 +53,9 @@ func NewTempo(
 	if t == nil {
 		return nil, fmt.Errorf("Failure creating Tempo object %q", err)
 	}
-	return &Tempo{o: t, buf: NewSimpleBuffer(blockSize)}, nil
+	tempo := &Tempo{o: t, buf: NewSimpleBuffer(blockSize)}
+	manage(tempo, (*Tempo).finalize)
+	return tempo, nil
 }
 
 func (t *Tempo) Buffer() *SimpleBuffer {
@@ -66,6 +69,8 @@ func (t *Tempo) Do(input *SimpleBuffer) {
 		return
 	}
 	C.aubio_tempo_do(t.o, input.vec, t.buf.vec)
+	runtime.KeepAlive(t)
+	runtime.KeepAlive(input)
 }
 
 // SetSilence sets the tempo detection silence threshold.
@@ -112,13 +117,44 @@ func (t *Tempo) GetConfidence() float64 {
 	return float64(C.aubio_tempo_get_confidence(t.o))
 }
 
-// Free frees the aubio_temp_t object's memory.
-func (t *Tempo) Free() {
+// GetLastBeat returns the time in seconds of the last detected beat.
+// After running Do a non-zero first sample in Buffer means the beat
+// returned here was just detected.
+func (t *Tempo) GetLastBeat() float64 {
 	if t.o == nil {
-		return
+		return 0
+	}
+	return float64(C.aubio_tempo_get_last_s(t.o))
+}
+
+// Free frees the aubio_tempo_t object's memory and its output buffer.
+// It is safe to call Free more than once.
+func (t *Tempo) Free() {
+	unmanage(t)
+	if t.o != nil {
+		C.del_aubio_tempo(t.o)
+		t.o = nil
+	}
+	if t.buf != nil {
+		t.buf.Free()
+		t.buf = nil
+	}
+}
+
+// Close frees the Tempo object. It always returns nil.
+func (t *Tempo) Close() error {
+	t.Free()
+	return nil
+}
+
+// finalize only frees the aubio_tempo_t, the output buffer has
+// a finalizer of its own.
+func (t *Tempo) finalize() {
+	leaked(t)
+	if t.o != nil {
+		C.del_aubio_tempo(t.o)
+		t.o = nil
 	}
-	C.del_aubio_tempo(t.o)
-	t.o = nil
 }
 
 /* Only available in AUBIO_UNSTABLE
@@ -16,6 +16,11 @@ package aubio
 */
 import "C"
 
+import (
+	"fmt"
+	"runtime"
+)
+
 // Filter is a wrapper for the aubio_filter_t object.
 type Filter struct {
 	o   *C.aubio_filter_t
@@ -31,11 +36,15 @@ func NewFilter(order, bufSize uint) (*Filter, error) {
 	if f == nil {
 		return nil, err
 	}
-	return &Filter{o: f, buf: NewSimpleBuffer(bufSize)}, nil
+	filter := &Filter{o: f, buf: NewSimpleBuffer(bufSize)}
+	manage(filter, (*Filter).finalize)
+	return filter, nil
 }
 
 // Free frees up the memory allocatd by aubio for this Filter.
+// It is safe to call Free more than once.
 func (f *Filter) Free() {
+	unmanage(f)
 	if f.o != nil {
 		C.del_aubio_filter(f.o)
 		f.o = nil
@@ -46,6 +55,22 @@ func (f *Filter) Free() {
 	}
 }
 
+// Close frees the Filter. It always returns nil.
+func (f *Filter) Close() error {
+	f.Free()
+	return nil
+}
+
+// finalize only frees the aubio_filter_t, the working buffer has
+// a finalizer of its own.
+func (f *Filter) finalize() {
+	leaked(f)
+	if f.o != nil {
+		C.del_aubio_filter(f.o)
+		f.o = nil
+	}
+}
+
 // Reset resets the memory for this Filter.
 func (f *Filter) Reset() {
 	if f.o != nil {
@@ -75,6 +100,8 @@ func (f *Filter) Do(in *SimpleBuffer) {
 	// Filter in-place
 	if f.o != nil {
 		C.aubio_filter_do(f.o, in.vec)
+		runtime.KeepAlive(f)
+		runtime.KeepAlive(in)
 	}
 }
 
@@ -86,6 +113,8 @@ func (f *Filter) Do(in *SimpleBuffer) {
 func (f *Filter) DoOutplace(in *SimpleBuffer) {
 	if f.o != nil {
 		C.aubio_filter_do_outplace(f.o, in.vec, f.buf.vec)
+		runtime.KeepAlive(f)
+		runtime.KeepAlive(in)
 	}
 }
 
@@ -93,24 +122,44 @@ func (f *Filter) DoOutplace(in *SimpleBuffer) {
 // Filter.
 func (f *Filter) DoFwdBack(in *SimpleBuffer, workBufSize uint) {
 	if f.o != nil {
-		tmp := NewSimpleBuffer(workBufSize)
-		defer tmp.Free()
+		tmp := newBuffer(workBufSize)
+		defer freeBuffer(tmp)
 		C.aubio_filter_do_filtfilt(f.o, in.vec, tmp.vec)
+		runtime.KeepAlive(f)
+		runtime.KeepAlive(in)
 	}
 }
 
 // Feedback returns the buffer containing the feedback coefficients.
+// The buffer is owned by the Filter and is only valid until the
+// Filter is freed.
 func (f *Filter) Feedback() *LongSampleBuffer {
 	if f.o != nil {
-		return newLBufferFromVec(C.aubio_filter_get_feedback(f.o))
+		return newLBufferFromVec(C.aubio_filter_get_feedback(f.o), f)
 	}
 	return nil
 }
 
-// Feedback returns the buffer containing the feedforward coefficients.
+// Feedforward returns the buffer containing the feedforward coefficients.
+// The buffer is owned by the Filter and is only valid until the
+// Filter is freed.
 func (f *Filter) Feedforward() *LongSampleBuffer {
 	if f.o != nil {
-		return newLBufferFromVec(C.aubio_filter_get_feedforward(f.o))
+		return newLBufferFromVec(C.aubio_filter_get_feedforward(f.o), f)
+	}
+	return nil
+}
+
+// SetBiquad sets the coefficients of a second order filter, with
+// a0 normalised to 1. The Filter must have been constructed with an
+// order of 3.
+func (f *Filter) SetBiquad(b0, b1, b2, a1, a2 float64) error {
+	if f.o == nil {
+		return fmt.Errorf("SetBiquad called on freed Filter")
+	}
+	if C.aubio_filter_set_biquad(f.o, C.lsmp_t(b0), C.lsmp_t(b1), C.lsmp_t(b2),
+		C.lsmp_t(a1), C.lsmp_t(a2)) != 0 {
+		return fmt.Errorf("Failure setting biquad coefficients on Filter of order %d", f.Order())
 	}
 	return nil
 }
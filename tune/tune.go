@@ -0,0 +1,310 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+// Package tune searches for the onset and tempo detector parameters
+// that score best against annotated examples.
+//
+//	t := &tune.Tuner{
+//		Examples: examples,
+//		Target:   tune.Onsets,
+//		Space: tune.Space{
+//			Modes:      []string{"hfc", "complex", "specflux"},
+//			Thresholds: []float64{0.1, 0.2, 0.3, 0.5},
+//		},
+//		Base: aubio.AnalysisConfig{BufSize: 512, HopSize: 256},
+//	}
+//	report, err := t.Grid()
+//	if err != nil {
+//		// handle error
+//	}
+//	report.WriteTable(os.Stdout)
+package tune
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"runtime"
+	"sort"
+	"sync"
+	"text/tabwriter"
+
+	"go.marzhillstudios.com/pkg/play/aubio"
+	"go.marzhillstudios.com/pkg/play/aubio/eval"
+)
+
+// Target is the detector a Tuner tunes.
+type Target int
+
+const (
+	// Onsets tunes the onset detector, scoring the detected onsets.
+	Onsets Target = iota
+	// Beats tunes the beat tracker, scoring the detected beats.
+	Beats
+)
+
+// Example is an annotated file.
+type Example struct {
+	Path string
+	// Onsets and Beats are the reference times in seconds. Only the
+	// ones for the Tuner's Target are needed.
+	Onsets []float64
+	Beats  []float64
+}
+
+// Space is the set of parameters searched. Every combination of the
+// values is a candidate. A dimension left empty keeps the value of
+// the Tuner's Base configuration.
+type Space struct {
+	// Modes are onset detection function names such as "hfc".
+	Modes      []string
+	Thresholds []float64
+	Silences   []float64
+	BufSizes   []uint
+	HopSizes   []uint
+}
+
+// Trial is the result of evaluating one configuration.
+type Trial struct {
+	Config aubio.AnalysisConfig
+	// Score is the mean of Scores.
+	Score float64
+	// Scores holds the score of each example, in order.
+	Scores []float64
+	// Err is the first error analysing an example. Trials with an
+	// error are ranked last.
+	Err error
+}
+
+// Tuner evaluates configurations against a set of examples.
+type Tuner struct {
+	Examples []Example
+	Target   Target
+	Space    Space
+	// Base is the configuration candidates start from. It sets
+	// everything not searched, such as Samplerate, Open and Timeout.
+	Base aubio.AnalysisConfig
+	// Score scores the analysis of an example. It defaults to the
+	// onset F-measure for Onsets and the beat F-measure for Beats.
+	Score func(ex Example, r *aubio.FileResult) float64
+	// Workers is the number of configurations evaluated at once.
+	// It defaults to runtime.NumCPU().
+	Workers int
+	// Progress, if set, is called as each trial finishes. Calls are
+	// never concurrent.
+	Progress func(done, total int, t *Trial)
+}
+
+// Report holds the trials of a search, best first.
+type Report struct {
+	Trials []*Trial
+}
+
+// Best returns the best trial, or nil if there were none.
+func (r *Report) Best() *Trial {
+	if len(r.Trials) == 0 || r.Trials[0].Err != nil {
+		return nil
+	}
+	return r.Trials[0]
+}
+
+// WriteTable writes the trials to w as an aligned table.
+func (r *Report) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "rank\tscore\tmode\tbuf\thop\tthreshold\tsilence\terror")
+	for i, t := range r.Trials {
+		c := t.Config
+		mode := c.OnsetMode
+		if c.TempoMode != "" {
+			mode = c.TempoMode
+		}
+		errText := ""
+		if t.Err != nil {
+			errText = t.Err.Error()
+		}
+		fmt.Fprintf(tw, "%d\t%.4f\t%s\t%d\t%d\t%g\t%g\t%s\n",
+			i+1, t.Score, mode, c.BufSize, c.HopSize, c.Threshold, c.Silence, errText)
+	}
+	return tw.Flush()
+}
+
+// Candidates returns every configuration in the search space.
+// Combinations with a hop size larger than the buffer size are
+// skipped.
+func (t *Tuner) Candidates() ([]aubio.AnalysisConfig, error) {
+	base := t.Base
+	base.PitchMode = ""
+	if t.Target == Beats {
+		base.OnsetMode = ""
+		if base.TempoMode == "" {
+			base.TempoMode = aubio.SpecDiff
+		}
+	} else {
+		base.TempoMode = ""
+		if base.OnsetMode == "" {
+			base.OnsetMode = aubio.HFC
+		}
+	}
+	configs := []aubio.AnalysisConfig{base}
+	expand := func(n int, set func(c *aubio.AnalysisConfig, i int)) {
+		if n == 0 {
+			return
+		}
+		var out []aubio.AnalysisConfig
+		for _, c := range configs {
+			for i := 0; i < n; i++ {
+				set(&c, i)
+				out = append(out, c)
+			}
+		}
+		configs = out
+	}
+	for _, name := range t.Space.Modes {
+		if _, err := aubio.ParseOnsetMode(name); err != nil {
+			return nil, err
+		}
+	}
+	expand(len(t.Space.Modes), func(c *aubio.AnalysisConfig, i int) {
+		mode, _ := aubio.ParseOnsetMode(t.Space.Modes[i])
+		if t.Target == Beats {
+			c.TempoMode = mode
+		} else {
+			c.OnsetMode = mode
+		}
+	})
+	expand(len(t.Space.BufSizes), func(c *aubio.AnalysisConfig, i int) { c.BufSize = t.Space.BufSizes[i] })
+	expand(len(t.Space.HopSizes), func(c *aubio.AnalysisConfig, i int) { c.HopSize = t.Space.HopSizes[i] })
+	expand(len(t.Space.Thresholds), func(c *aubio.AnalysisConfig, i int) { c.Threshold = t.Space.Thresholds[i] })
+	expand(len(t.Space.Silences), func(c *aubio.AnalysisConfig, i int) { c.Silence = t.Space.Silences[i] })
+
+	valid := configs[:0]
+	for _, c := range configs {
+		if c.HopSize > 0 && c.HopSize <= c.BufSize {
+			valid = append(valid, c)
+		}
+	}
+	if len(valid) == 0 {
+		return nil, fmt.Errorf("No valid configurations in the search space")
+	}
+	return valid, nil
+}
+
+// Grid evaluates every configuration in the search space.
+func (t *Tuner) Grid() (*Report, error) {
+	configs, err := t.Candidates()
+	if err != nil {
+		return nil, err
+	}
+	return t.Run(configs), nil
+}
+
+// Random evaluates n configurations picked at random from the search
+// space, or all of them if there are fewer than n. seed seeds the
+// choice so that searches can be repeated.
+func (t *Tuner) Random(n int, seed int64) (*Report, error) {
+	configs, err := t.Candidates()
+	if err != nil {
+		return nil, err
+	}
+	rand.New(rand.NewSource(seed)).Shuffle(len(configs), func(i, j int) {
+		configs[i], configs[j] = configs[j], configs[i]
+	})
+	if n < len(configs) {
+		configs = configs[:n]
+	}
+	return t.Run(configs), nil
+}
+
+// Run evaluates configs and returns the trials ranked by score.
+func (t *Tuner) Run(configs []aubio.AnalysisConfig) *Report {
+	workers := t.Workers
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
+	todo := make(chan int)
+	done := make(chan int)
+	report := &Report{Trials: make([]*Trial, len(configs))}
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range todo {
+				report.Trials[i] = t.trial(configs[i])
+				done <- i
+			}
+		}()
+	}
+	go func() {
+		for i := range configs {
+			todo <- i
+		}
+		close(todo)
+		wg.Wait()
+		close(done)
+	}()
+	n := 0
+	for i := range done {
+		n++
+		if t.Progress != nil {
+			t.Progress(n, len(configs), report.Trials[i])
+		}
+	}
+	// Ties keep the order of configs.
+	sort.SliceStable(report.Trials, func(i, j int) bool {
+		a, b := report.Trials[i], report.Trials[j]
+		if (a.Err == nil) != (b.Err == nil) {
+			return a.Err == nil
+		}
+		return a.Score > b.Score
+	})
+	return report
+}
+
+// trial analyses every example with config and scores it.
+func (t *Tuner) trial(config aubio.AnalysisConfig) *Trial {
+	score := t.Score
+	if score == nil {
+		score = t.defaultScore
+	}
+	paths := make([]string, len(t.Examples))
+	index := make(map[string][]int)
+	for i, ex := range t.Examples {
+		paths[i] = ex.Path
+		index[ex.Path] = append(index[ex.Path], i)
+	}
+	trial := &Trial{Config: config, Scores: make([]float64, len(t.Examples))}
+	for r := range aubio.AnalyzeFiles(paths, config, 1) {
+		if r.Err != nil && trial.Err == nil {
+			trial.Err = r.Err
+		}
+		// The same file may be listed more than once.
+		i := index[r.Path][0]
+		index[r.Path] = index[r.Path][1:]
+		trial.Scores[i] = score(t.Examples[i], r)
+	}
+	if trial.Err != nil {
+		return trial
+	}
+	for _, s := range trial.Scores {
+		trial.Score += s
+	}
+	if len(trial.Scores) > 0 {
+		trial.Score /= float64(len(trial.Scores))
+	}
+	return trial
+}
+
+func (t *Tuner) defaultScore(ex Example, r *aubio.FileResult) float64 {
+	if t.Target == Beats {
+		return eval.Beats(ex.Beats, r.Beats).FMeasure
+	}
+	return eval.Onsets(ex.Onsets, r.Onsets, 0).FMeasure
+}
@@ -0,0 +1,169 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package tune
+
+import (
+	"fmt"
+	"testing"
+
+	"go.marzhillstudios.com/pkg/play/aubio"
+)
+
+func TestCandidates(t *testing.T) {
+	tuner := &Tuner{
+		Space: Space{
+			Modes:    []string{"hfc", "complex"},
+			BufSizes: []uint{512, 1024},
+			HopSizes: []uint{256, 1024},
+		},
+		Base: aubio.AnalysisConfig{Threshold: 0.3, PitchMode: aubio.PitchYin, TempoMode: aubio.SpecFlux},
+	}
+	configs, err := tuner.Candidates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The hop size of 1024 doesn't fit a buffer of 512.
+	want := []struct {
+		mode     string
+		buf, hop uint
+	}{
+		{"hfc", 512, 256}, {"hfc", 1024, 256}, {"hfc", 1024, 1024},
+		{"complex", 512, 256}, {"complex", 1024, 256}, {"complex", 1024, 1024},
+	}
+	if len(configs) != len(want) {
+		t.Fatalf("Candidates returned %d configurations, want %d", len(configs), len(want))
+	}
+	for i, c := range configs {
+		w := want[i]
+		if string(c.OnsetMode) != w.mode || c.BufSize != w.buf || c.HopSize != w.hop {
+			t.Errorf("Candidate %d = %s %d/%d, want %s %d/%d", i, c.OnsetMode, c.BufSize, c.HopSize, w.mode, w.buf, w.hop)
+		}
+		// Only the onset detector is run, with the rest of Base.
+		if c.TempoMode != "" || c.PitchMode != "" || c.Threshold != 0.3 {
+			t.Errorf("Candidate %d = %+v, want only the onset mode set and Threshold 0.3", i, c)
+		}
+	}
+}
+
+func TestCandidatesBeats(t *testing.T) {
+	tuner := &Tuner{
+		Target: Beats,
+		Space:  Space{Modes: []string{"specflux"}, Thresholds: []float64{0.1, 0.2}},
+		Base:   aubio.AnalysisConfig{BufSize: 1024, HopSize: 512, OnsetMode: aubio.HFC},
+	}
+	configs, err := tuner.Candidates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(configs) != 2 {
+		t.Fatalf("Candidates returned %d configurations, want 2", len(configs))
+	}
+	for i, c := range configs {
+		if c.TempoMode != aubio.SpecFlux || c.OnsetMode != "" || c.Threshold != 0.1*float64(i+1) {
+			t.Errorf("Candidate %d = %+v, want the specflux tempo mode at threshold %g", i, c, 0.1*float64(i+1))
+		}
+	}
+
+	// Without modes to search the default detection functions are
+	// used.
+	for target, want := range map[Target]aubio.AnalysisConfig{
+		Onsets: {BufSize: 1024, HopSize: 512, OnsetMode: aubio.HFC},
+		Beats:  {BufSize: 1024, HopSize: 512, TempoMode: aubio.SpecDiff},
+	} {
+		tuner := &Tuner{Target: target, Base: aubio.AnalysisConfig{BufSize: 1024, HopSize: 512}}
+		configs, err := tuner.Candidates()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(configs) != 1 || configs[0].OnsetMode != want.OnsetMode || configs[0].TempoMode != want.TempoMode {
+			t.Errorf("Target %d: Candidates = %+v, want [%+v]", target, configs, want)
+		}
+	}
+}
+
+func TestCandidatesErrors(t *testing.T) {
+	for name, tuner := range map[string]*Tuner{
+		"unknown mode": {
+			Space: Space{Modes: []string{"hfc", "bogus"}},
+			Base:  aubio.AnalysisConfig{BufSize: 1024, HopSize: 512},
+		},
+		"hop too large": {
+			Space: Space{HopSizes: []uint{1024, 2048}},
+			Base:  aubio.AnalysisConfig{BufSize: 512},
+		},
+		"no hop": {
+			Base: aubio.AnalysisConfig{BufSize: 512},
+		},
+	} {
+		if configs, err := tuner.Candidates(); err == nil {
+			t.Errorf("%s: Candidates = %+v, want an error", name, configs)
+		}
+	}
+}
+
+func TestRunRanking(t *testing.T) {
+	// Each configuration opens a silent source of its own length, or
+	// fails to, and is scored by the number of samples read.
+	lengths := []uint{1000, 0, 3000, 1000, 0, 3000}
+	configs := make([]aubio.AnalysisConfig, len(lengths))
+	for i, n := range lengths {
+		n := n
+		configs[i] = aubio.AnalysisConfig{
+			BufSize:   512,
+			HopSize:   256,
+			OnsetMode: aubio.HFC,
+			Threshold: float64(i),
+			Open: func(path string, samplerate, hopSize uint) (aubio.AudioSource, error) {
+				if n == 0 {
+					return nil, fmt.Errorf("Failed to open %s", path)
+				}
+				return aubio.NewMemorySource(make([]float64, n), 8000, hopSize), nil
+			},
+		}
+	}
+	done := 0
+	tuner := &Tuner{
+		Examples: []Example{{Path: "a.wav"}, {Path: "b.wav"}},
+		Score: func(ex Example, r *aubio.FileResult) float64 {
+			return float64(r.Frames)
+		},
+		Workers: 3,
+		Progress: func(n, total int, trial *Trial) {
+			done++
+			if n != done || total != len(configs) {
+				t.Errorf("Progress(%d, %d), want (%d, %d)", n, total, done, len(configs))
+			}
+		},
+	}
+	report := tuner.Run(configs)
+	if done != len(configs) {
+		t.Errorf("Progress called %d times, want %d", done, len(configs))
+	}
+	// Ties, including the failed trials, keep their order.
+	want := []float64{2, 5, 0, 3, 1, 4}
+	if len(report.Trials) != len(want) {
+		t.Fatalf("Run returned %d trials, want %d", len(report.Trials), len(want))
+	}
+	for i, trial := range report.Trials {
+		if trial.Config.Threshold != want[i] {
+			t.Errorf("Trial %d is configuration %g, want %g", i, trial.Config.Threshold, want[i])
+		}
+		if failed := lengths[int(trial.Config.Threshold)] == 0; failed != (trial.Err != nil) {
+			t.Errorf("Trial %d has error %v", i, trial.Err)
+		}
+	}
+	if best := report.Best(); best != report.Trials[0] || best.Score != 3000 {
+		t.Errorf("Best = %+v, want the first trial scoring 3000", best)
+	}
+	if got := (&Report{Trials: report.Trials[4:]}).Best(); got != nil {
+		t.Errorf("Best of failed trials = %+v, want nil", got)
+	}
+}
@@ -0,0 +1,351 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package aubio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+// Pure Go WAV support for systems where libaubio was built without
+// sndfile or libav.
+
+const (
+	wavFormatPCM        = 0x0001
+	wavFormatFloat      = 0x0003
+	wavFormatExtensible = 0xFFFE
+)
+
+// wavGUIDTail is the tail shared by the KSDATAFORMAT_SUBTYPE GUIDs used
+// in WAVE_FORMAT_EXTENSIBLE headers. The first two bytes are the format tag.
+var wavGUIDTail = []byte{
+	0x00, 0x00, 0x00, 0x00, 0x10, 0x00, 0x80, 0x00,
+	0x00, 0xAA, 0x00, 0x38, 0x9B, 0x71}
+
+var errNotWav = errors.New("Not a RIFF WAVE stream")
+
+// wavFormat maps a WAV format tag and sample size onto a pcmFormat.
+func wavFormat(tag, bits uint16) (pcmFormat, error) {
+	switch {
+	case tag == wavFormatPCM && bits == 8:
+		return PCMU8, nil
+	case tag == wavFormatPCM && bits == 16:
+		return PCMS16LE, nil
+	case tag == wavFormatPCM && bits == 24:
+		return PCMS24LE, nil
+	case tag == wavFormatPCM && bits == 32:
+		return PCMS32LE, nil
+	case tag == wavFormatFloat && bits == 32:
+		return PCMF32LE, nil
+	case tag == wavFormatFloat && bits == 64:
+		return PCMF64LE, nil
+	}
+	return "", fmt.Errorf("Unsupported WAV format tag 0x%04x with %d bits", tag, bits)
+}
+
+// WavSource is an AudioSource decoding a WAV file without using aubio.
+type WavSource struct {
+	*ReaderSource
+	r      io.Reader
+	frames uint
+}
+
+// OpenWavSource opens the WAV file at path. As with OpenSource a
+// samplerate of 0 uses the file's samplerate. WavSource does not
+// resample so any other samplerate must match the file.
+//
+// The caller is responsible for calling Close on the
+// returned WavSource.
+//
+//	s, err := OpenWavSource(path, 0, 256)
+//	if err != nil {
+//		// handle error
+//	}
+//	defer s.Close()
+func OpenWavSource(path string, samplerate, hopSize uint) (*WavSource, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	s, err := NewWavSource(f, hopSize)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("Failed to open wav %q: %s", path, err)
+	}
+	if samplerate != 0 && samplerate != s.Samplerate() {
+		f.Close()
+		return nil, fmt.Errorf("Wav %q has samplerate %d, want %d",
+			path, s.Samplerate(), samplerate)
+	}
+	return s, nil
+}
+
+// NewWavSource reads a WAV header from r and returns a WavSource
+// that decodes hopSize frames per Read. If r is an io.Closer it is
+// closed when the WavSource is closed.
+func NewWavSource(r io.Reader, hopSize uint) (*WavSource, error) {
+	var riff [12]byte
+	if _, err := io.ReadFull(r, riff[:]); err != nil {
+		return nil, err
+	}
+	if string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return nil, errNotWav
+	}
+	var (
+		format         pcmFormat
+		channels, rate uint
+		haveFmt        bool
+		chunkHdr       [8]byte
+		formatErr      error
+	)
+	for {
+		if _, err := io.ReadFull(r, chunkHdr[:]); err != nil {
+			if err == io.EOF {
+				err = errors.New("Missing data chunk")
+			}
+			return nil, err
+		}
+		id := string(chunkHdr[0:4])
+		size := binary.LittleEndian.Uint32(chunkHdr[4:8])
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return nil, errors.New("Short fmt chunk")
+			}
+			body := make([]byte, size+size%2)
+			if _, err := io.ReadFull(r, body); err != nil {
+				return nil, err
+			}
+			tag := binary.LittleEndian.Uint16(body[0:2])
+			channels = uint(binary.LittleEndian.Uint16(body[2:4]))
+			rate = uint(binary.LittleEndian.Uint32(body[4:8]))
+			if channels == 0 {
+				return nil, errors.New("Invalid channel count 0")
+			}
+			blockAlign := binary.LittleEndian.Uint16(body[12:14])
+			bits := binary.LittleEndian.Uint16(body[14:16])
+			if tag == wavFormatExtensible {
+				if size < 40 || !bytes.Equal(body[26:40], wavGUIDTail) {
+					return nil, errors.New("Unsupported WAVE_FORMAT_EXTENSIBLE subformat")
+				}
+				tag = binary.LittleEndian.Uint16(body[24:26])
+			}
+			// The container size wins over the valid bits per sample.
+			bits = blockAlign / uint16(channels) * 8
+			format, formatErr = wavFormat(tag, bits)
+			haveFmt = true
+		case "data":
+			if !haveFmt {
+				return nil, errors.New("Data chunk before fmt chunk")
+			}
+			if formatErr != nil {
+				return nil, formatErr
+			}
+			data := r
+			frameSize := channels * format.sampleSize()
+			frames := uint(0)
+			// Streamed files may leave the size unset.
+			if size != 0 && size != 0xFFFFFFFF {
+				data = io.LimitReader(r, int64(size))
+				frames = uint(size) / frameSize
+			}
+			rs, err := NewReaderSource(data, format, channels, rate, hopSize)
+			if err != nil {
+				return nil, err
+			}
+			return &WavSource{ReaderSource: rs, r: r, frames: frames}, nil
+		default:
+			if _, err := io.CopyN(io.Discard, r, int64(size)+int64(size%2)); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// Do reads from the source into a buffer.
+// It returns the amount of data read.
+func (s *WavSource) Do(buf *SimpleBuffer) uint {
+	n, err := s.Read(buf)
+	if err != nil && err != io.EOF {
+		log.Printf("Error reading wav: %s", err)
+	}
+	return n
+}
+
+// Duration returns the length of the WAV data in frames or 0 if
+// the header does not say.
+func (s *WavSource) Duration() uint {
+	return s.frames
+}
+
+// Close closes the underlying reader if it is an io.Closer.
+// It is safe to call Close more than once.
+func (s *WavSource) Close() error {
+	r := s.r
+	s.r = nil
+	if c, ok := r.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// WavSink is an AudioSink encoding a WAV file without using aubio.
+type WavSink struct {
+	*WriterSink
+	w      io.Writer
+	frames uint
+	closed bool
+}
+
+// OpenWavSink creates a 16 bit mono WAV file at path.
+//
+// The caller is responsible for calling Close on the returned
+// WavSink so the header gets the final data size.
+//
+//	s, err := OpenWavSink(path, 44100)
+//	if err != nil {
+//		// handle error
+//	}
+//	defer s.Close()
+func OpenWavSink(path string, samplerate uint) (*WavSink, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	s, err := NewWavSink(f, PCMS16LE, 1, samplerate)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// NewWavSink writes a WAV header to w and returns a WavSink that
+// encodes samples in the given format. The mono input is copied to
+// every channel. WAVE_FORMAT_EXTENSIBLE is used for more than two
+// channels or more than 16 bits.
+//
+// If w is an io.WriteSeeker the header is rewritten with the data size
+// on Close, otherwise the sizes are left at their streaming maximum.
+// If w is an io.Closer it is closed by Close.
+func NewWavSink(w io.Writer, format pcmFormat, channels, samplerate uint) (*WavSink, error) {
+	ws, err := NewWriterSink(w, format, channels, samplerate)
+	if err != nil {
+		return nil, err
+	}
+	hdr := wavHeader(format, channels, samplerate, 0xFFFFFFFF)
+	if _, err := w.Write(hdr); err != nil {
+		return nil, err
+	}
+	return &WavSink{WriterSink: ws, w: w}, nil
+}
+
+// wavHeader builds the RIFF, fmt and data chunk headers for dataLen
+// bytes of sample data.
+func wavHeader(format pcmFormat, channels, samplerate uint, dataLen uint32) []byte {
+	bits := uint16(format.sampleSize() * 8)
+	tag := uint16(wavFormatPCM)
+	if format == PCMF32LE || format == PCMF64LE {
+		tag = wavFormatFloat
+	}
+	blockAlign := uint16(channels) * bits / 8
+	ext := channels > 2 || bits > 16
+
+	var fmtChunk bytes.Buffer
+	le := binary.LittleEndian
+	if ext {
+		binary.Write(&fmtChunk, le, uint16(wavFormatExtensible))
+	} else {
+		binary.Write(&fmtChunk, le, tag)
+	}
+	binary.Write(&fmtChunk, le, uint16(channels))
+	binary.Write(&fmtChunk, le, uint32(samplerate))
+	binary.Write(&fmtChunk, le, uint32(samplerate)*uint32(blockAlign))
+	binary.Write(&fmtChunk, le, blockAlign)
+	binary.Write(&fmtChunk, le, bits)
+	if ext {
+		binary.Write(&fmtChunk, le, uint16(22))
+		binary.Write(&fmtChunk, le, bits)
+		binary.Write(&fmtChunk, le, uint32(0))
+		binary.Write(&fmtChunk, le, tag)
+		fmtChunk.Write(wavGUIDTail)
+	}
+
+	riffLen := uint32(4 + 8 + fmtChunk.Len() + 8)
+	if dataLen > 0xFFFFFFFF-riffLen {
+		riffLen = 0xFFFFFFFF
+	} else {
+		riffLen += dataLen + dataLen%2
+	}
+	var hdr bytes.Buffer
+	hdr.WriteString("RIFF")
+	binary.Write(&hdr, le, riffLen)
+	hdr.WriteString("WAVE")
+	hdr.WriteString("fmt ")
+	binary.Write(&hdr, le, uint32(fmtChunk.Len()))
+	hdr.Write(fmtChunk.Bytes())
+	hdr.WriteString("data")
+	binary.Write(&hdr, le, dataLen)
+	return hdr.Bytes()
+}
+
+// Write encodes n samples from buf to the WAV data chunk.
+func (s *WavSink) Write(buf *SimpleBuffer, n uint) (uint, error) {
+	if s == nil {
+		return 0, errNilSink
+	}
+	if s.closed {
+		return 0, errClosedSink
+	}
+	n, err := s.WriterSink.Write(buf, n)
+	s.frames += n
+	return n, err
+}
+
+// Do writes to the sink from the buffer.
+// It returns the amount of data written.
+func (s *WavSink) Do(buf *SimpleBuffer, n uint) uint {
+	n, err := s.Write(buf, n)
+	if err != nil {
+		log.Printf("Error writing wav: %s", err)
+	}
+	return n
+}
+
+// Close pads the data chunk, fixes up the header sizes when possible
+// and closes the underlying writer if it is an io.Closer.
+// It is safe to call Close more than once.
+func (s *WavSink) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	dataLen := uint64(s.frames) * uint64(s.channels*s.format.sampleSize())
+	if dataLen%2 == 1 {
+		s.w.Write([]byte{0})
+	}
+	if ws, ok := s.w.(io.WriteSeeker); ok && dataLen < 0xFFFFFFFF {
+		hdr := wavHeader(s.format, s.channels, s.samplerate, uint32(dataLen))
+		if _, err := ws.Seek(0, io.SeekStart); err == nil {
+			ws.Write(hdr)
+			ws.Seek(0, io.SeekEnd)
+		}
+	}
+	if c, ok := s.w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,305 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package aubio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// wavFile builds a WAV stream by hand, independently of wavHeader,
+// with a data chunk holding data. extensible wraps tag in a
+// WAVE_FORMAT_EXTENSIBLE fmt chunk. extra chunks go before the data.
+func wavFile(tag, channels uint16, rate uint32, bits uint16, extensible bool, extra, data []byte) []byte {
+	le := binary.LittleEndian
+	var fmtChunk bytes.Buffer
+	if extensible {
+		binary.Write(&fmtChunk, le, uint16(wavFormatExtensible))
+	} else {
+		binary.Write(&fmtChunk, le, tag)
+	}
+	binary.Write(&fmtChunk, le, channels)
+	binary.Write(&fmtChunk, le, rate)
+	binary.Write(&fmtChunk, le, rate*uint32(channels*bits/8))
+	binary.Write(&fmtChunk, le, channels*bits/8)
+	binary.Write(&fmtChunk, le, bits)
+	if extensible {
+		binary.Write(&fmtChunk, le, uint16(22))
+		binary.Write(&fmtChunk, le, bits)
+		binary.Write(&fmtChunk, le, uint32(0))
+		binary.Write(&fmtChunk, le, tag)
+		fmtChunk.Write(wavGUIDTail)
+	}
+	var body bytes.Buffer
+	body.WriteString("WAVE")
+	body.WriteString("fmt ")
+	binary.Write(&body, le, uint32(fmtChunk.Len()))
+	body.Write(fmtChunk.Bytes())
+	body.Write(extra)
+	body.WriteString("data")
+	binary.Write(&body, le, uint32(len(data)))
+	body.Write(data)
+	var f bytes.Buffer
+	f.WriteString("RIFF")
+	binary.Write(&f, le, uint32(body.Len()))
+	f.Write(body.Bytes())
+	return f.Bytes()
+}
+
+// le packs values as little endian bytes.
+func le(values ...interface{}) []byte {
+	var b bytes.Buffer
+	for _, v := range values {
+		binary.Write(&b, binary.LittleEndian, v)
+	}
+	return b.Bytes()
+}
+
+// int24 packs values as 3 byte little endian integers.
+func int24(values ...int32) []byte {
+	var b []byte
+	for _, v := range values {
+		b = append(b, byte(v), byte(v>>8), byte(v>>16))
+	}
+	return b
+}
+
+// readSamples reads s to the end hop samples at a time.
+func readSamples(t *testing.T, s AudioSource, hop uint) []float64 {
+	t.Helper()
+	buf := NewSimpleBuffer(hop)
+	defer buf.Free()
+	var samples []float64
+	for {
+		n, err := s.Read(buf)
+		samples = append(samples, buf.Slice()[:n]...)
+		if err == io.EOF {
+			return samples
+		}
+		if err != nil {
+			t.Fatalf("Read failed: %s", err)
+		}
+	}
+}
+
+func TestWavSourceDecode(t *testing.T) {
+	tests := []struct {
+		name       string
+		tag        uint16
+		channels   uint16
+		bits       uint16
+		extensible bool
+		data       []byte
+		want       []float64
+	}{
+		{"u8", wavFormatPCM, 1, 8, false,
+			[]byte{0, 64, 128, 192, 255},
+			[]float64{-1, -0.5, 0, 0.5, 127.0 / 128}},
+		{"s16", wavFormatPCM, 1, 16, false,
+			le(int16(-32768), int16(-16384), int16(0), int16(16384), int16(32767)),
+			[]float64{-1, -0.5, 0, 0.5, 32767.0 / 32768}},
+		{"s24", wavFormatPCM, 1, 24, false,
+			int24(-1<<23, -1<<22, 0, 1<<22, 1<<23-1),
+			[]float64{-1, -0.5, 0, 0.5, float64(1<<23-1) / (1 << 23)}},
+		{"s32", wavFormatPCM, 1, 32, false,
+			le(int32(-1<<31), int32(-1<<30), int32(0), int32(1<<30)),
+			[]float64{-1, -0.5, 0, 0.5}},
+		{"f32", wavFormatFloat, 1, 32, false,
+			le(float32(-1), float32(-0.25), float32(0), float32(0.75)),
+			[]float64{-1, -0.25, 0, 0.75}},
+		{"f64", wavFormatFloat, 1, 64, false,
+			le(float64(-1), float64(-0.25), float64(0), float64(0.75)),
+			[]float64{-1, -0.25, 0, 0.75}},
+		{"s24 extensible", wavFormatPCM, 1, 24, true,
+			int24(1<<22, -1<<22),
+			[]float64{0.5, -0.5}},
+		{"f32 extensible", wavFormatFloat, 1, 32, true,
+			le(float32(0.5), float32(-0.125)),
+			[]float64{0.5, -0.125}},
+		{"s16 stereo", wavFormatPCM, 2, 16, false,
+			le(int16(16384), int16(0), int16(-16384), int16(-16384)),
+			[]float64{0.25, -0.5}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			f := wavFile(tc.tag, tc.channels, 8000, tc.bits, tc.extensible, nil, tc.data)
+			s, err := NewWavSource(bytes.NewReader(f), 2)
+			if err != nil {
+				t.Fatalf("NewWavSource failed: %s", err)
+			}
+			if s.Samplerate() != 8000 {
+				t.Errorf("Samplerate() = %d, want 8000", s.Samplerate())
+			}
+			if s.Channels() != uint(tc.channels) {
+				t.Errorf("Channels() = %d, want %d", s.Channels(), tc.channels)
+			}
+			if s.Duration() != uint(len(tc.want)) {
+				t.Errorf("Duration() = %d, want %d", s.Duration(), len(tc.want))
+			}
+			got := readSamples(t, s, 2)
+			if len(got) != len(tc.want) {
+				t.Fatalf("Read %d samples, want %d", len(got), len(tc.want))
+			}
+			for i := range got {
+				// Samples pass through single precision.
+				if math.Abs(got[i]-tc.want[i]) > 1e-7 {
+					t.Errorf("Sample %d = %g, want %g", i, got[i], tc.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestWavSourceSkipsChunks(t *testing.T) {
+	// An odd sized chunk is padded to an even length.
+	extra := append([]byte("LIST"), le(uint32(3))...)
+	extra = append(extra, 'a', 'b', 'c', 0)
+	f := wavFile(wavFormatPCM, 1, 8000, 16, false, extra, le(int16(16384)))
+	s, err := NewWavSource(bytes.NewReader(f), 4)
+	if err != nil {
+		t.Fatalf("NewWavSource failed: %s", err)
+	}
+	if got := readSamples(t, s, 4); len(got) != 1 || got[0] != 0.5 {
+		t.Errorf("Read %v, want [0.5]", got)
+	}
+}
+
+func TestWavSourceErrors(t *testing.T) {
+	valid := wavFile(wavFormatPCM, 1, 8000, 16, false, nil, le(int16(0)))
+	noData := valid[:len(valid)-10]
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"not riff", append([]byte("RIFX"), valid[4:]...)},
+		{"missing data", noData},
+		{"adpcm", wavFile(0x0002, 1, 8000, 4, false, nil, []byte{0})},
+		{"16 bit float", wavFile(wavFormatFloat, 1, 8000, 16, false, nil, []byte{0, 0})},
+		{"zero channels", wavFile(wavFormatPCM, 0, 8000, 16, false, nil, nil)},
+	}
+	for _, tc := range tests {
+		if _, err := NewWavSource(bytes.NewReader(tc.data), 4); err == nil {
+			t.Errorf("%s: NewWavSource succeeded, want an error", tc.name)
+		}
+	}
+}
+
+func TestWavRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	signal := make([]float64, 1001)
+	for i := range signal {
+		signal[i] = math.Sin(float64(i)/10) * 0.9
+	}
+	in := NewSimpleBufferData(uint(len(signal)), signal)
+	defer in.Free()
+	formats := []pcmFormat{PCMU8, PCMS16LE, PCMS24LE, PCMS32LE, PCMF32LE, PCMF64LE}
+	for _, format := range formats {
+		for _, channels := range []uint{1, 2, 6} {
+			path := filepath.Join(dir, string(format)+".wav")
+			f, err := os.Create(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sink, err := NewWavSink(f, format, channels, 22050)
+			if err != nil {
+				t.Fatalf("%s: NewWavSink failed: %s", format, err)
+			}
+			if n, err := sink.Write(in, in.Size()); err != nil || n != in.Size() {
+				t.Fatalf("%s: Write = %d, %v", format, n, err)
+			}
+			if err := sink.Close(); err != nil {
+				t.Fatalf("%s: Close failed: %s", format, err)
+			}
+
+			src, err := OpenWavSource(path, 22050, 256)
+			if err != nil {
+				t.Fatalf("%s: OpenWavSource failed: %s", format, err)
+			}
+			if src.Channels() != channels {
+				t.Errorf("%s: Channels() = %d, want %d", format, src.Channels(), channels)
+			}
+			// The header was rewritten with the data size.
+			if src.Duration() != uint(len(signal)) {
+				t.Errorf("%s: Duration() = %d, want %d", format, src.Duration(), len(signal))
+			}
+			got := readSamples(t, src, 256)
+			src.Close()
+			if len(got) != len(signal) {
+				t.Fatalf("%s: read %d samples, want %d", format, len(got), len(signal))
+			}
+			// Integer formats are within a quantisation step.
+			tolerance := 1e-6
+			if format != PCMF32LE && format != PCMF64LE {
+				tolerance = 1 / math.Pow(2, float64(format.sampleSize()*8-1))
+				tolerance = math.Max(tolerance, 1e-6)
+			}
+			for i := range got {
+				if math.Abs(got[i]-signal[i]) > tolerance {
+					t.Fatalf("%s: sample %d = %g, want %g", format, i, got[i], signal[i])
+				}
+			}
+		}
+	}
+}
+
+func TestWavSinkCloseTwice(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "twice.wav")
+	sink, err := OpenWavSink(path, 8000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := NewSimpleBufferData(3, []float64{0.5, 0, -0.5})
+	defer buf.Free()
+	sink.Write(buf, 3)
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close failed: %s", err)
+	}
+	if err := sink.Close(); err != nil {
+		t.Errorf("Second Close = %s, want nil", err)
+	}
+	if _, err := sink.Write(buf, 3); err == nil {
+		t.Errorf("Write after Close succeeded")
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A 44 byte header and three 16 bit samples, written once.
+	if info.Size() != 44+6 {
+		t.Errorf("File is %d bytes, want %d", info.Size(), 44+6)
+	}
+
+	src, err := OpenWavSource(path, 0, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := src.Close(); err != nil {
+		t.Fatalf("Close failed: %s", err)
+	}
+	if err := src.Close(); err != nil {
+		t.Errorf("Second Close = %s, want nil", err)
+	}
+}
+
+func TestWavSinkNil(t *testing.T) {
+	var sink *WavSink
+	buf := NewSimpleBuffer(1)
+	defer buf.Free()
+	if _, err := sink.Write(buf, 1); err == nil {
+		t.Errorf("Write on nil WavSink succeeded")
+	}
+}
@@ -0,0 +1,211 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package waveform
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+)
+
+// version is the audiowaveform format version written.
+const version = 2
+
+// flag8Bit is set in the binary header flags when the data is 8 bit.
+const flag8Bit = 1
+
+// jsonData is the audiowaveform JSON layout. RMS is not part of the
+// format, readers that don't know it ignore it.
+type jsonData struct {
+	Version         int   `json:"version"`
+	Channels        int   `json:"channels"`
+	SampleRate      uint  `json:"sample_rate"`
+	SamplesPerPixel uint  `json:"samples_per_pixel"`
+	Bits            int   `json:"bits"`
+	Length          int   `json:"length"`
+	Data            []int `json:"data"`
+	RMS             []int `json:"rms,omitempty"`
+}
+
+// scale returns the largest quantised value for bits, which must be
+// 8 or 16.
+func scale(bits int) (float64, error) {
+	switch bits {
+	case 8:
+		return math.MaxInt8, nil
+	case 16:
+		return math.MaxInt16, nil
+	}
+	return 0, fmt.Errorf("Unsupported bit depth %d, want 8 or 16", bits)
+}
+
+// quantise converts v from [-1, 1] to an integer of the given scale.
+func quantise(v, scale float64) int {
+	q := math.Round(v * scale)
+	return int(math.Max(-scale-1, math.Min(scale, q)))
+}
+
+// WriteJSON writes l to w in the audiowaveform JSON format with
+// samples of bits bits, 8 or 16. The RMS of each pixel is written
+// too, in an "rms" array the format doesn't define.
+func (l *Level) WriteJSON(w io.Writer, bits int) error {
+	s, err := scale(bits)
+	if err != nil {
+		return err
+	}
+	d := jsonData{
+		Version:         version,
+		Channels:        1,
+		SampleRate:      l.Samplerate,
+		SamplesPerPixel: l.SamplesPerPixel,
+		Bits:            bits,
+		Length:          l.Len(),
+		Data:            make([]int, 0, 2*l.Len()),
+		RMS:             make([]int, 0, l.Len()),
+	}
+	for i := 0; i < l.Len(); i++ {
+		d.Data = append(d.Data, quantise(l.Min[i], s), quantise(l.Max[i], s))
+		d.RMS = append(d.RMS, quantise(l.RMS[i], s))
+	}
+	return json.NewEncoder(w).Encode(d)
+}
+
+// ReadJSON reads a Level from r in the audiowaveform JSON format.
+// Only mono data can be read. RMS is only set if the data has it.
+func ReadJSON(r io.Reader) (*Level, error) {
+	var d jsonData
+	if err := json.NewDecoder(r).Decode(&d); err != nil {
+		return nil, err
+	}
+	if d.Channels > 1 {
+		return nil, fmt.Errorf("Unsupported channel count %d", d.Channels)
+	}
+	s, err := scale(d.Bits)
+	if err != nil {
+		return nil, err
+	}
+	if len(d.Data) != 2*d.Length {
+		return nil, fmt.Errorf("Waveform has %d values for %d pixels", len(d.Data), d.Length)
+	}
+	l := &Level{Samplerate: d.SampleRate, SamplesPerPixel: d.SamplesPerPixel}
+	for i := 0; i < d.Length; i++ {
+		l.Min = append(l.Min, float64(d.Data[2*i])/s)
+		l.Max = append(l.Max, float64(d.Data[2*i+1])/s)
+	}
+	if len(d.RMS) == d.Length {
+		for _, v := range d.RMS {
+			l.RMS = append(l.RMS, float64(v)/s)
+		}
+	}
+	return l, nil
+}
+
+// binaryHeader is the header of the audiowaveform binary format,
+// version 2. All fields are little endian.
+type binaryHeader struct {
+	Version         int32
+	Flags           uint32
+	SampleRate      int32
+	SamplesPerPixel int32
+	Length          uint32
+	Channels        int32
+}
+
+// WriteBinary writes l to w in the audiowaveform binary format with
+// samples of bits bits, 8 or 16. The format has no room for RMS.
+func (l *Level) WriteBinary(w io.Writer, bits int) error {
+	s, err := scale(bits)
+	if err != nil {
+		return err
+	}
+	h := binaryHeader{
+		Version:         version,
+		SampleRate:      int32(l.Samplerate),
+		SamplesPerPixel: int32(l.SamplesPerPixel),
+		Length:          uint32(l.Len()),
+		Channels:        1,
+	}
+	if bits == 8 {
+		h.Flags = flag8Bit
+	}
+	bw := bufio.NewWriter(w)
+	if err := binary.Write(bw, binary.LittleEndian, h); err != nil {
+		return err
+	}
+	for i := 0; i < l.Len(); i++ {
+		min, max := quantise(l.Min[i], s), quantise(l.Max[i], s)
+		if bits == 8 {
+			bw.Write([]byte{byte(int8(min)), byte(int8(max))})
+			continue
+		}
+		var b [4]byte
+		binary.LittleEndian.PutUint16(b[0:], uint16(int16(min)))
+		binary.LittleEndian.PutUint16(b[2:], uint16(int16(max)))
+		bw.Write(b[:])
+	}
+	return bw.Flush()
+}
+
+// ReadBinary reads a Level from r in the audiowaveform binary format,
+// version 1 or 2. Only mono data can be read.
+func ReadBinary(r io.Reader) (*Level, error) {
+	var h binaryHeader
+	// Version 1 has no channel count.
+	if err := binary.Read(r, binary.LittleEndian, &h.Version); err != nil {
+		return nil, err
+	}
+	fields := []interface{}{&h.Flags, &h.SampleRate, &h.SamplesPerPixel, &h.Length}
+	switch h.Version {
+	case 1:
+		h.Channels = 1
+	case 2:
+		fields = append(fields, &h.Channels)
+	default:
+		return nil, fmt.Errorf("Unsupported waveform version %d", h.Version)
+	}
+	for _, f := range fields {
+		if err := binary.Read(r, binary.LittleEndian, f); err != nil {
+			return nil, err
+		}
+	}
+	if h.Channels != 1 {
+		return nil, fmt.Errorf("Unsupported channel count %d", h.Channels)
+	}
+	bits := 16
+	if h.Flags&flag8Bit != 0 {
+		bits = 8
+	}
+	s, _ := scale(bits)
+	l := &Level{Samplerate: uint(h.SampleRate), SamplesPerPixel: uint(h.SamplesPerPixel)}
+	br := bufio.NewReader(r)
+	for i := uint32(0); i < h.Length; i++ {
+		var min, max int
+		if bits == 8 {
+			var b [2]int8
+			if err := binary.Read(br, binary.LittleEndian, &b); err != nil {
+				return nil, err
+			}
+			min, max = int(b[0]), int(b[1])
+		} else {
+			var b [2]int16
+			if err := binary.Read(br, binary.LittleEndian, &b); err != nil {
+				return nil, err
+			}
+			min, max = int(b[0]), int(b[1])
+		}
+		l.Min = append(l.Min, float64(min)/s)
+		l.Max = append(l.Max, float64(max)/s)
+	}
+	return l, nil
+}
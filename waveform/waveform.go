@@ -0,0 +1,158 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+// Package waveform computes the peaks front ends need to draw
+// zoomable waveforms without decoding audio, and reads and writes
+// them in the JSON and binary formats of BBC's audiowaveform.
+package waveform
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+
+	"go.marzhillstudios.com/pkg/play/aubio"
+)
+
+// Level holds the peaks of a source at one zoom level: the minimum,
+// maximum and RMS of every SamplesPerPixel samples. The values are
+// between -1 and 1.
+type Level struct {
+	Samplerate      uint
+	SamplesPerPixel uint
+	Min             []float64
+	Max             []float64
+	RMS             []float64
+}
+
+// Len returns the number of pixels in l.
+func (l *Level) Len() int {
+	return len(l.Min)
+}
+
+// Duration returns the length in seconds covered by l.
+func (l *Level) Duration() float64 {
+	return float64(l.Len()) * float64(l.SamplesPerPixel) / float64(l.Samplerate)
+}
+
+// pixel accumulates the samples of one pixel.
+type pixel struct {
+	min, max, squares float64
+	n                 uint
+}
+
+func (p *pixel) add(v float64) {
+	if p.n == 0 || v < p.min {
+		p.min = v
+	}
+	if p.n == 0 || v > p.max {
+		p.max = v
+	}
+	p.squares += v * v
+	p.n++
+}
+
+func (l *Level) append(p pixel) {
+	l.Min = append(l.Min, p.min)
+	l.Max = append(l.Max, p.max)
+	l.RMS = append(l.RMS, math.Sqrt(p.squares/float64(p.n)))
+}
+
+// Peaks reads src to the end once and returns its peaks at each of
+// the zoom levels, given in samples per pixel. Every zoom level must
+// be a multiple of the smallest, which is computed from the samples
+// while the others are merged from it. The levels are returned from
+// the smallest up, once each. src is closed when done.
+//
+//	levels, err := Peaks(src, 256, 1024, 4096)
+//	if err != nil {
+//		// handle error
+//	}
+//	for _, l := range levels {
+//		// write l
+//	}
+func Peaks(src aubio.AudioSource, samplesPerPixel ...uint) ([]*Level, error) {
+	if c, ok := src.(io.Closer); ok {
+		defer c.Close()
+	}
+	zooms := append([]uint(nil), samplesPerPixel...)
+	sort.Slice(zooms, func(i, j int) bool { return zooms[i] < zooms[j] })
+	if len(zooms) == 0 || zooms[0] == 0 {
+		return nil, fmt.Errorf("Invalid zoom levels %v", samplesPerPixel)
+	}
+	// Each level is only computed once, however often it's given.
+	unique := zooms[:1]
+	for _, z := range zooms[1:] {
+		if z%zooms[0] != 0 {
+			return nil, fmt.Errorf("Zoom level %d is not a multiple of %d", z, zooms[0])
+		}
+		if z != unique[len(unique)-1] {
+			unique = append(unique, z)
+		}
+	}
+	zooms = unique
+
+	size := uint(1024)
+	if b, ok := src.(interface{ BlockSize() uint }); ok && b.BlockSize() > 0 {
+		size = b.BlockSize()
+	}
+	buf := aubio.NewSimpleBuffer(size)
+	defer buf.Free()
+
+	base := &Level{Samplerate: src.Samplerate(), SamplesPerPixel: zooms[0]}
+	var p pixel
+	for {
+		n, err := src.Read(buf)
+		for _, v := range buf.Slice()[:n] {
+			p.add(v)
+			if p.n == base.SamplesPerPixel {
+				base.append(p)
+				p = pixel{}
+			}
+		}
+		if err == io.EOF || n == 0 {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if p.n > 0 {
+		base.append(p)
+	}
+
+	levels := []*Level{base}
+	for _, z := range zooms[1:] {
+		levels = append(levels, base.Downsample(z/zooms[0]))
+	}
+	return levels, nil
+}
+
+// Downsample returns l zoomed out by factor, merging factor pixels
+// into one.
+func (l *Level) Downsample(factor uint) *Level {
+	out := &Level{Samplerate: l.Samplerate, SamplesPerPixel: l.SamplesPerPixel * factor}
+	for start := 0; start < l.Len(); start += int(factor) {
+		end := start + int(factor)
+		if end > l.Len() {
+			end = l.Len()
+		}
+		p := pixel{min: l.Min[start], max: l.Max[start]}
+		for i := start; i < end; i++ {
+			p.min = math.Min(p.min, l.Min[i])
+			p.max = math.Max(p.max, l.Max[i])
+			p.squares += l.RMS[i] * l.RMS[i]
+			p.n++
+		}
+		out.append(p)
+	}
+	return out
+}
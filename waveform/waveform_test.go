@@ -0,0 +1,215 @@
+/*
+ Copyright 2013 Jeremy Wall (jeremy@marzhillstudios.com)
+
+   Licensed under the Apache License, Version 2.0 (the "License");
+   you may not use this file except in compliance with the License.
+   You may obtain a copy of the License at
+
+       http://www.apache.org/licenses/LICENSE-2.0
+*/
+
+package waveform
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"go.marzhillstudios.com/pkg/play/aubio"
+)
+
+// checkValues compares got with want to within tolerance.
+func checkValues(t *testing.T, name string, got, want []float64, tolerance float64) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Errorf("%s = %v, want %v", name, got, want)
+		return
+	}
+	for i := range want {
+		if math.Abs(got[i]-want[i]) > tolerance {
+			t.Errorf("%s[%d] = %g, want %g", name, i, got[i], want[i])
+		}
+	}
+}
+
+func TestPeaks(t *testing.T) {
+	data := []float64{0.5, -0.5, 0.5, -0.5, 0.25, 0, 0, 0, 1, -1}
+	// Blocks of 3 samples don't line up with the pixels. The
+	// repeated zoom levels are computed once.
+	src := aubio.NewMemorySource(data, 8000, 3)
+	levels, err := Peaks(src, 8, 4, 8, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(levels) != 2 {
+		t.Fatalf("Peaks returned %d levels, want 2", len(levels))
+	}
+	base, zoomed := levels[0], levels[1]
+	if base.SamplesPerPixel != 4 || zoomed.SamplesPerPixel != 8 {
+		t.Errorf("Samples per pixel = %d, %d, want 4, 8", base.SamplesPerPixel, zoomed.SamplesPerPixel)
+	}
+	if base.Samplerate != 8000 || zoomed.Samplerate != 8000 {
+		t.Errorf("Samplerates = %d, %d, want 8000", base.Samplerate, zoomed.Samplerate)
+	}
+	// The last pixel only has 2 samples.
+	checkValues(t, "Min", base.Min, []float64{-0.5, 0, -1}, 1e-9)
+	checkValues(t, "Max", base.Max, []float64{0.5, 0.25, 1}, 1e-9)
+	checkValues(t, "RMS", base.RMS, []float64{0.5, 0.125, 1}, 1e-9)
+	checkValues(t, "zoomed Min", zoomed.Min, []float64{-0.5, -1}, 1e-9)
+	checkValues(t, "zoomed Max", zoomed.Max, []float64{0.5, 1}, 1e-9)
+	checkValues(t, "zoomed RMS", zoomed.RMS, []float64{math.Sqrt((0.25 + 0.125*0.125) / 2), 1}, 1e-9)
+	if d := base.Duration(); d != 12.0/8000 {
+		t.Errorf("Duration = %g, want %g", d, 12.0/8000)
+	}
+}
+
+func TestPeaksErrors(t *testing.T) {
+	for _, zooms := range [][]uint{nil, {0, 256}, {256, 384}} {
+		src := aubio.NewMemorySource(make([]float64, 10), 8000, 4)
+		if _, err := Peaks(src, zooms...); err == nil {
+			t.Errorf("Peaks with zoom levels %v succeeded", zooms)
+		}
+	}
+}
+
+// testLevel returns a level with full scale, silent and in between
+// values. The ones in between are exact at 8 bits only.
+func testLevel() *Level {
+	return &Level{
+		Samplerate:      44100,
+		SamplesPerPixel: 256,
+		Min:             []float64{-1, -64.0 / 127, 0},
+		Max:             []float64{1, 3.0 / 127, 0},
+		RMS:             []float64{0.5, 10.0 / 127, 0},
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	for _, bits := range []int{8, 16} {
+		want := testLevel()
+		var b bytes.Buffer
+		if err := want.WriteJSON(&b, bits); err != nil {
+			t.Fatalf("%d bits: WriteJSON failed: %s", bits, err)
+		}
+		got, err := ReadJSON(&b)
+		if err != nil {
+			t.Fatalf("%d bits: ReadJSON failed: %s", bits, err)
+		}
+		if got.Samplerate != want.Samplerate || got.SamplesPerPixel != want.SamplesPerPixel {
+			t.Errorf("%d bits: read %d Hz at %d samples per pixel, want %d at %d", bits,
+				got.Samplerate, got.SamplesPerPixel, want.Samplerate, want.SamplesPerPixel)
+		}
+		// -1 is stored as -127 or -32767.
+		tolerance := 0.5/math.MaxInt8 + 1e-9
+		if bits == 16 {
+			tolerance = 0.5/math.MaxInt16 + 1e-9
+		}
+		checkValues(t, "Min", got.Min, want.Min, tolerance)
+		checkValues(t, "Max", got.Max, want.Max, tolerance)
+		checkValues(t, "RMS", got.RMS, want.RMS, tolerance)
+	}
+}
+
+func TestJSONFormat(t *testing.T) {
+	l := &Level{Samplerate: 8000, SamplesPerPixel: 4, Min: []float64{-1}, Max: []float64{0.5}, RMS: []float64{0.25}}
+	var b bytes.Buffer
+	if err := l.WriteJSON(&b, 8); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"version":2,"channels":1,"sample_rate":8000,"samples_per_pixel":4,"bits":8,"length":1,"data":[-127,64],"rms":[32]}` + "\n"
+	if b.String() != want {
+		t.Errorf("WriteJSON wrote %s, want %s", b.String(), want)
+	}
+}
+
+func TestReadJSONErrors(t *testing.T) {
+	for _, data := range []string{
+		`{"version":2,"channels":2,"sample_rate":8000,"samples_per_pixel":4,"bits":8,"length":1,"data":[0,0,0,0]}`,
+		`{"version":2,"channels":1,"sample_rate":8000,"samples_per_pixel":4,"bits":12,"length":1,"data":[0,0]}`,
+		`{"version":2,"channels":1,"sample_rate":8000,"samples_per_pixel":4,"bits":8,"length":2,"data":[0,0]}`,
+		`{"version":`,
+	} {
+		if _, err := ReadJSON(bytes.NewBufferString(data)); err == nil {
+			t.Errorf("ReadJSON(%s) succeeded", data)
+		}
+	}
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	for _, bits := range []int{8, 16} {
+		want := testLevel()
+		var b bytes.Buffer
+		if err := want.WriteBinary(&b, bits); err != nil {
+			t.Fatalf("%d bits: WriteBinary failed: %s", bits, err)
+		}
+		// A 24 byte header and a minimum and maximum per pixel.
+		if size := 24 + 3*2*bits/8; b.Len() != size {
+			t.Errorf("%d bits: wrote %d bytes, want %d", bits, b.Len(), size)
+		}
+		got, err := ReadBinary(&b)
+		if err != nil {
+			t.Fatalf("%d bits: ReadBinary failed: %s", bits, err)
+		}
+		if got.Samplerate != want.Samplerate || got.SamplesPerPixel != want.SamplesPerPixel {
+			t.Errorf("%d bits: read %d Hz at %d samples per pixel, want %d at %d", bits,
+				got.Samplerate, got.SamplesPerPixel, want.Samplerate, want.SamplesPerPixel)
+		}
+		tolerance := 0.5/math.MaxInt8 + 1e-9
+		if bits == 16 {
+			tolerance = 0.5/math.MaxInt16 + 1e-9
+		}
+		checkValues(t, "Min", got.Min, want.Min, tolerance)
+		checkValues(t, "Max", got.Max, want.Max, tolerance)
+		if got.RMS != nil {
+			t.Errorf("%d bits: read RMS %v from a format without it", bits, got.RMS)
+		}
+	}
+}
+
+func TestReadBinaryVersion1(t *testing.T) {
+	var b bytes.Buffer
+	for _, v := range []interface{}{int32(1), uint32(flag8Bit), int32(8000), int32(64), uint32(2), []int8{-127, 127, 0, 64}} {
+		binary.Write(&b, binary.LittleEndian, v)
+	}
+	l, err := ReadBinary(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Samplerate != 8000 || l.SamplesPerPixel != 64 {
+		t.Errorf("Read %d Hz at %d samples per pixel, want 8000 at 64", l.Samplerate, l.SamplesPerPixel)
+	}
+	checkValues(t, "Min", l.Min, []float64{-1, 0}, 1e-9)
+	checkValues(t, "Max", l.Max, []float64{1, 64.0 / 127}, 1e-9)
+}
+
+func TestReadBinaryErrors(t *testing.T) {
+	header := func(version int32, channels int32) []byte {
+		var b bytes.Buffer
+		for _, v := range []interface{}{version, uint32(0), int32(8000), int32(64), uint32(1), channels} {
+			binary.Write(&b, binary.LittleEndian, v)
+		}
+		return b.Bytes()
+	}
+	for name, data := range map[string][]byte{
+		"version 3": header(3, 1),
+		"stereo":    header(2, 2),
+		"truncated": header(2, 1),
+		"empty":     nil,
+	} {
+		if _, err := ReadBinary(bytes.NewReader(data)); err == nil {
+			t.Errorf("%s: ReadBinary succeeded", name)
+		}
+	}
+}
+
+func TestWriteBadBits(t *testing.T) {
+	l := testLevel()
+	var b bytes.Buffer
+	if err := l.WriteJSON(&b, 24); err == nil {
+		t.Errorf("WriteJSON with 24 bits succeeded")
+	}
+	if err := l.WriteBinary(&b, 24); err == nil {
+		t.Errorf("WriteBinary with 24 bits succeeded")
+	}
+}